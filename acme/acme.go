@@ -0,0 +1,57 @@
+/*
+Package acme provides Let's Encrypt certificates for the mux.Server using the
+ACME protocol. It lives in its own module so the mux package doesn't depend on
+golang.org/x/crypto.
+*/
+package acme
+
+import (
+	"net/http"
+
+	"github.com/kevinfalting/mux"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// WithLetsEncrypt will return a server option that serves the Mux over TLS on
+// the HTTPS port, with certificates for the domains obtained from Let's
+// Encrypt and cached in cacheDir. The HTTP port answers the HTTP-01 challenge
+// and redirects every other request to HTTPS. Pass an empty address to
+// mux.NewServer so these are the only listeners.
+func WithLetsEncrypt(cacheDir string, domains ...string) func(*mux.Server) {
+	if len(domains) == 0 {
+		panic("domains must not be empty")
+	}
+
+	if len(cacheDir) == 0 {
+		panic("cache directory must not be empty")
+	}
+
+	return WithManager(&autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+	})
+}
+
+// WithManager will return a server option like WithLetsEncrypt, using the
+// provided manager for the certificates, such as one with a different ACME
+// directory or cache.
+func WithManager(m *autocert.Manager) func(*mux.Server) {
+	if m == nil {
+		panic("manager must not be nil")
+	}
+
+	return func(s *mux.Server) {
+		mux.WithTLSConfig(m.TLSConfig())(s)
+		mux.WithTLSListener("tcp", ":https")(s)
+		mux.WithListener("tcp", ":http", challenge(m))(s)
+	}
+}
+
+// challenge will return middleware that answers the ACME HTTP-01 challenge,
+// and redirects every other request to HTTPS instead of passing it on.
+func challenge(m *autocert.Manager) mux.Middleware {
+	return func(http.Handler) http.Handler {
+		return m.HTTPHandler(nil)
+	}
+}
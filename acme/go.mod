@@ -0,0 +1,13 @@
+module github.com/kevinfalting/mux/acme
+
+go 1.22
+
+require github.com/kevinfalting/mux v0.0.0
+
+require (
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
+
+replace github.com/kevinfalting/mux => ../
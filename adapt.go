@@ -0,0 +1,73 @@
+package mux
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+)
+
+// FromChi will convert middleware with the func(http.Handler) http.Handler
+// signature, used by chi, gorilla, and alice, into Middleware. A single one
+// can be used as Middleware as is, this converts a stack of them, such as
+// chi.Middlewares, so it can be passed to Use or Handle.
+func FromChi(mws ...func(http.Handler) http.Handler) []Middleware {
+	converted := make([]Middleware, 0, len(mws))
+	for _, mw := range mws {
+		if mw == nil {
+			panic("middleware must not be nil")
+		}
+		converted = append(converted, Named(funcName(mw), mw))
+	}
+
+	return converted
+}
+
+// NegroniHandler is the middleware interface of negroni, satisfied by both
+// negroni.Handler and negroni.HandlerFunc. The middleware calls next to
+// continue the chain.
+type NegroniHandler interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+}
+
+// FromNegroni will convert negroni middleware into Middleware. Routes report
+// it by the name of its type.
+func FromNegroni(h NegroniHandler) Middleware {
+	if h == nil {
+		panic("negroni handler must not be nil")
+	}
+
+	return Named(typeName(h), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r, next.ServeHTTP)
+		})
+	})
+}
+
+// FromNegroniFunc will convert a function with the negroni middleware
+// signature into Middleware. Routes report it by the name of the function.
+func FromNegroniFunc(f func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)) Middleware {
+	if f == nil {
+		panic("negroni func must not be nil")
+	}
+
+	return Named(funcName(f), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f(w, r, next.ServeHTTP)
+		})
+	})
+}
+
+// typeName will return the name of the value's type qualified like funcName,
+// such as "negroni.Logger".
+func typeName(v any) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Name() == "" {
+		return t.String()
+	}
+
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
@@ -0,0 +1,76 @@
+package mux
+
+import (
+	"net/http"
+)
+
+// NewAdminMux will return a Mux for the admin plane of the public Mux, to be
+// served on its own listener with WithAdmin, so the operational endpoints are
+// never reachable on the public one. It serves:
+//
+//   - "GET /healthz", a 200 while the process is serving at all.
+//   - "GET /readyz", a 200 while the public Mux accepts requests, and a 503
+//     while it's draining or in maintenance, for load balancers.
+//   - "/debug/", the routes of AttachDebug describing the public Mux.
+//   - "/debug/pprof/", the routes of AttachProfiling.
+//
+// Every route is wrapped in the middleware, such as authentication of the
+// engineers. Register more on it, such as a metrics exporter.
+func NewAdminMux(public *Mux, mw ...Middleware) *Mux {
+	if public == nil {
+		panic("public mux must not be nil")
+	}
+
+	admin := New(mw...)
+	admin.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+
+	admin.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		if !public.Ready() {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte("ok\n"))
+	})
+
+	attachDebug(admin, public, "/debug/", nil)
+	AttachProfiling(admin, "/debug/pprof/")
+
+	return admin
+}
+
+// Ready reports whether the Mux accepts requests, being neither drained nor in
+// maintenance.
+func (m *Mux) Ready() bool {
+	return !m.draining.Load() && m.maintenance.Load() == nil
+}
+
+// WithAdmin will additionally serve the admin Mux, such as one returned by
+// NewAdminMux, on the network address, wrapped in the provided middleware.
+// The Mux of the Server is never served on it, nor the admin Mux on any other
+// listener. The admin Mux is validated along with the Server's, but isn't
+// drained by Shutdown, so health checks are answered until the listeners
+// close.
+func WithAdmin(network, address string, admin *Mux, mw ...Middleware) serverOption {
+	if len(network) == 0 || len(address) == 0 {
+		panic("network and address must not be empty")
+	}
+
+	if admin == nil {
+		panic("admin mux must not be nil")
+	}
+
+	return func(s *Server) {
+		s.admins = append(s.admins, admin)
+		s.listeners = append(s.listeners, &serverListener{
+			network: network,
+			address: address,
+			handler: WrapMiddleware(mw, admin),
+			admin:   true,
+		})
+	}
+}
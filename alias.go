@@ -0,0 +1,153 @@
+package mux
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type aliasOption func(*alias)
+
+type alias struct {
+	mux      *Mux
+	segments []string
+	serve    bool
+	mw       []Middleware
+}
+
+// Alias will register the old pattern as an alias of the canonical one, such
+// as "GET /users/{id}" for "GET /people/{id}", for paths kept working after
+// they're renamed. Requests for the old pattern are redirected to the path of
+// the canonical one, with its wildcards set to the values of those of the
+// same name in the old, and the query kept. GET and HEAD requests are
+// redirected with a 301, and the others with a 308 so their method and body
+// are kept.
+//
+// Aliases are reported by Routes with the canonical pattern as their AliasOf,
+// so they can be found and removed once nothing uses them. Every
+// wildcard of the canonical pattern must be one of the old, and it must not
+// have a host, or the alias is reported by Validate.
+func (m *Mux) Alias(old, canonical string, options ...aliasOption) {
+	a := &alias{mux: m}
+	for _, opt := range options {
+		opt(a)
+	}
+
+	_, path := splitMethod(canonical)
+	if !strings.HasPrefix(path, "/") {
+		m.problem(old, "canonical pattern %q must begin with a slash, after any method", canonical)
+		return
+	}
+
+	_, oldPath := splitMethod(old)
+	names := wildcards(oldPath)
+	a.segments = strings.Split(path, "/")
+	for _, name := range wildcards(path) {
+		if !contains(names, name) {
+			m.problem(old, "canonical pattern %q has the wildcard %q the alias hasn't", canonical, name)
+			return
+		}
+	}
+
+	mw := chain([]Middleware{option(func(rt *route) {
+		rt.aliasOf = canonical
+	})}, a.mw)
+	m.Handle(old, a, mw...)
+}
+
+// WithServedAlias will serve the requests for the alias as if they were for
+// the canonical path, instead of redirecting them, such as for clients that
+// don't follow redirects.
+func WithServedAlias() aliasOption {
+	return func(a *alias) {
+		a.serve = true
+	}
+}
+
+// WithAliasMiddleware will wrap the alias in the middleware, such as
+// Deprecated to tell clients to stop using it.
+func WithAliasMiddleware(mw ...Middleware) aliasOption {
+	return func(a *alias) {
+		a.mw = append(a.mw, mw...)
+	}
+}
+
+func (a *alias) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := a.path(r)
+	if a.serve {
+		path, err := url.PathUnescape(target)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		// The alias is dispatched again by its canonical path, rather than
+		// to the canonical route's handler, so the prefixes of its groups
+		// are stripped and its path values are set as they'd be.
+		r2 := r.Clone(r.Context())
+		r2.URL.Path, r2.URL.RawPath = path, target
+		r2.RequestURI = r2.URL.RequestURI()
+		a.mux.mux.ServeHTTP(w, r2)
+		return
+	}
+
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	code := http.StatusPermanentRedirect
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		code = http.StatusMovedPermanently
+	}
+
+	http.Redirect(w, r, target, code)
+}
+
+// path will return the escaped canonical path of the request, with the
+// wildcards of the canonical pattern set to the request's path values.
+func (a *alias) path(r *http.Request) string {
+	segments := make([]string, len(a.segments))
+	for i, seg := range a.segments {
+		name, ok := wildcard(seg)
+		switch {
+		case !ok:
+			segments[i] = url.PathEscape(seg)
+		case name == "$":
+			segments[i] = ""
+		case strings.HasSuffix(seg, "...}"):
+			// The remainder keeps its slashes, only its segments are
+			// escaped.
+			rest := strings.Split(r.PathValue(name), "/")
+			for j := range rest {
+				rest[j] = url.PathEscape(rest[j])
+			}
+			segments[i] = strings.Join(rest, "/")
+		default:
+			segments[i] = url.PathEscape(r.PathValue(name))
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// wildcards will return the names of the wildcards of the path of a pattern.
+func wildcards(path string) []string {
+	var names []string
+	for _, seg := range strings.Split(path, "/") {
+		if name, ok := wildcard(seg); ok && name != "$" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// wildcard will return the name of the wildcard of the segment of a pattern,
+// without any "...", reporting false if it isn't one.
+func wildcard(seg string) (string, bool) {
+	if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+		return "", false
+	}
+
+	return strings.TrimSuffix(seg[1:len(seg)-1], "..."), true
+}
@@ -0,0 +1,146 @@
+package mux
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type anonymousOption func(*anonymous)
+
+type anonymous struct {
+	key      []byte
+	cookie   string
+	ttl      time.Duration
+	insecure bool
+}
+
+// Anonymous will return middleware giving the requests without an Identity an
+// anonymous one, so what's keyed by IdentityKey, such as a Lockout or an
+// Experiment, works for clients that aren't logged in too. Its Subject is a
+// random UUID kept in a cookie named "anonymous" for a year, signed with the
+// key so it can't be chosen by the client, and it's set Anonymous.
+//
+// Register it after the authentication middleware, which it leaves the
+// requests they identified to. Authorize treats an anonymous Identity like
+// none at all.
+func Anonymous(key []byte, options ...anonymousOption) Middleware {
+	if len(key) < 16 {
+		panic("key must be at least 16 bytes")
+	}
+
+	a := &anonymous{key: key, cookie: "anonymous", ttl: 365 * 24 * time.Hour}
+	for _, opt := range options {
+		opt(a)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := IdentityOf(r); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			id, ok := a.verify(r)
+			if !ok {
+				var err error
+				if id, err = newUUID(); err != nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+				a.setCookie(w, id)
+			}
+
+			next.ServeHTTP(w, WithIdentity(r, Identity{Subject: id, Anonymous: true}))
+		})
+	}
+}
+
+// WithAnonymousCookie will keep the anonymous identity in the cookie of the
+// name.
+func WithAnonymousCookie(name string) anonymousOption {
+	if len(name) == 0 {
+		panic("cookie name must not be empty")
+	}
+
+	return func(a *anonymous) {
+		a.cookie = name
+	}
+}
+
+// WithAnonymousTTL will keep the anonymous identity for the duration after
+// it's assigned, instead of a year.
+func WithAnonymousTTL(d time.Duration) anonymousOption {
+	if d <= 0 {
+		panic("ttl must be positive")
+	}
+
+	return func(a *anonymous) {
+		a.ttl = d
+	}
+}
+
+// WithInsecureAnonymousCookie will send the anonymous cookie over plain HTTP
+// too, such as for local development. It's only sent over HTTPS otherwise.
+func WithInsecureAnonymousCookie() anonymousOption {
+	return func(a *anonymous) {
+		a.insecure = true
+	}
+}
+
+// verify will return the anonymous identity of the request's cookie,
+// reporting false if it has none or its signature doesn't match.
+func (a *anonymous) verify(r *http.Request) (string, bool) {
+	c, err := r.Cookie(a.cookie)
+	if err != nil {
+		return "", false
+	}
+
+	id, sig, ok := strings.Cut(c.Value, ".")
+	if !ok {
+		return "", false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(b, a.sign(id)) {
+		return "", false
+	}
+
+	return id, true
+}
+
+func (a *anonymous) sign(id string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(id))
+	return mac.Sum(nil)
+}
+
+func (a *anonymous) setCookie(w http.ResponseWriter, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.cookie,
+		Value:    id + "." + base64.RawURLEncoding.EncodeToString(a.sign(id)),
+		Path:     "/",
+		MaxAge:   int(a.ttl / time.Second),
+		Secure:   !a.insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// newUUID will return a random, version 4 UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
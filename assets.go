@@ -0,0 +1,299 @@
+package mux
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Assets serves the files of an fs.FS under fingerprinted names, with a hash
+// of their content in the name, such as "app.3f9ab2c1.js" for "app.js". A
+// fingerprinted name changes whenever the content does, so it's served with
+// immutable cache headers and browsers never revalidate it. The logical names
+// are still served, revalidated on every request, for links that can't be
+// fingerprinted.
+//
+// Register it on a Group at its prefix, so it's served the paths relative to
+// it:
+//
+//	assets, err := mux.NewAssets(os.DirFS("static"), "/assets/")
+//	m.Group("/assets/", assets)
+//
+// Templates link to the fingerprinted names with the "asset" function of
+// FuncMap, such as {{asset "app.js"}}.
+type Assets struct {
+	fsys   fs.FS
+	prefix string
+
+	// urls maps the logical names to the fingerprinted ones, and files the
+	// names served to the files.
+	urls  map[string]string
+	files map[string]*assetFile
+}
+
+type assetFile struct {
+	name        string
+	etag        string
+	modTime     time.Time
+	fingerprint bool
+
+	// encoded are the pre-compressed siblings of the file, in the order
+	// they're preferred.
+	encoded []assetEncoding
+}
+
+// assetEncoding is a pre-compressed sibling of a file, such as "app.js.br" of
+// "app.js".
+type assetEncoding struct {
+	encoding string
+	name     string
+	etag     string
+}
+
+// assetEncodings are the extensions of the pre-compressed siblings served, in
+// the order they're preferred when the client accepts several equally.
+var assetEncodings = []struct{ ext, encoding string }{
+	{".br", "br"},
+	{".zst", "zstd"},
+	{".gz", "gzip"},
+}
+
+// NewAssets will return the Assets of the files in fsys, linked to under the
+// prefix. Every file is read and hashed once, so the manifest is built at
+// startup. It returns the error of walking or reading fsys.
+//
+// A file with a pre-compressed sibling, such as "app.js.br" or "app.js.gz"
+// next to "app.js", is served from the sibling to clients accepting its
+// encoding, with the Content-Type of the file. The siblings aren't served by
+// their own names. Clients accepting none get the file as is, which Compress
+// may still compress.
+func NewAssets(fsys fs.FS, prefix string) (*Assets, error) {
+	if fsys == nil {
+		panic("fsys must not be nil")
+	}
+
+	if !strings.HasPrefix(prefix, "/") || !strings.HasSuffix(prefix, "/") {
+		panic("prefix must start and end with a slash")
+	}
+
+	a := &Assets{
+		fsys:   fsys,
+		prefix: prefix,
+		urls:   map[string]string{},
+		files:  map[string]*assetFile{},
+	}
+
+	entries := map[string]fs.DirEntry{}
+	var names []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		entries[name] = d
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		if isSibling(name, entries) {
+			continue
+		}
+
+		if err := a.add(name, entries[name], entries); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// isSibling reports whether the file is the pre-compressed sibling of another.
+func isSibling(name string, entries map[string]fs.DirEntry) bool {
+	for _, e := range assetEncodings {
+		if base, ok := strings.CutSuffix(name, e.ext); ok {
+			if _, ok := entries[base]; ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// add will hash the file, and add its logical and fingerprinted names with its
+// pre-compressed siblings. The ETag of a sibling is that of the file with its
+// encoding, since its bytes differ.
+func (a *Assets) add(name string, d fs.DirEntry, entries map[string]fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+
+	sum, err := a.hash(name)
+	if err != nil {
+		return err
+	}
+
+	file := assetFile{name: name, etag: `"` + sum[:16] + `"`, modTime: info.ModTime()}
+	for _, e := range assetEncodings {
+		if _, ok := entries[name+e.ext]; ok {
+			file.encoded = append(file.encoded, assetEncoding{
+				encoding: e.encoding,
+				name:     name + e.ext,
+				etag:     `"` + sum[:16] + "-" + e.encoding + `"`,
+			})
+		}
+	}
+
+	hashed := fingerprint(name, sum[:8])
+	a.urls[name] = hashed
+	logical := file
+	a.files[name] = &logical
+	file.fingerprint = true
+	a.files[hashed] = &file
+
+	return nil
+}
+
+// hash will return the hex SHA-256 of the file's content.
+func (a *Assets) hash(name string) (string, error) {
+	f, err := a.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fingerprint will return the name with the hash inserted before its
+// extension.
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// URL will return the fingerprinted URL of the file with the logical name,
+// such as "/assets/app.3f9ab2c1.js" for "app.js". A name that isn't in the
+// manifest is returned under the prefix as is, so a missing file shows up as a
+// 404 rather than a broken template.
+func (a *Assets) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if hashed, ok := a.urls[name]; ok {
+		return a.prefix + hashed
+	}
+
+	return a.prefix + name
+}
+
+// FuncMap will return the template functions linking to the assets, for the
+// Funcs method of html/template and text/template: "asset" is URL.
+func (a *Assets) FuncMap() map[string]any {
+	return map[string]any{"asset": a.URL}
+}
+
+// Manifest will return the fingerprinted names of the files by their logical
+// names, such as for handing to a frontend build.
+func (a *Assets) Manifest() map[string]string {
+	manifest := make(map[string]string, len(a.urls))
+	for name, hashed := range a.urls {
+		manifest[name] = hashed
+	}
+
+	return manifest
+}
+
+// ServeHTTP satisfies the handler interface, serving the file the path names,
+// relative to the prefix. Fingerprinted names are cached for a year as
+// immutable, and logical names must be revalidated with their ETag. Ranges
+// and conditional requests are answered as by http.ServeContent.
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, a.prefix)
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	file, ok := a.files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	h := w.Header()
+	served, etag := file.name, file.etag
+	if len(file.encoded) > 0 {
+		Vary(h, "Accept-Encoding")
+		if enc, ok := file.negotiate(r.Header.Values("Accept-Encoding")); ok {
+			served, etag = enc.name, enc.etag
+			h.Set("Content-Encoding", enc.encoding)
+		}
+	}
+
+	// The type is that of the file, not of its sibling.
+	if ctype := mime.TypeByExtension(path.Ext(file.name)); ctype != "" {
+		h.Set("Content-Type", ctype)
+	}
+
+	f, err := a.fsys.Open(served)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	h.Set("ETag", etag)
+	if file.fingerprint {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, file.name, file.modTime, rs)
+		return
+	}
+
+	// Files of an fs.FS aren't required to seek, so those that can't are
+	// read whole.
+	b, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, file.name, file.modTime, bytes.NewReader(b))
+}
+
+// negotiate will return the pre-compressed sibling the client prefers by its
+// Accept-Encoding header, if it accepts any.
+func (file *assetFile) negotiate(accept []string) (assetEncoding, bool) {
+	qs := encodingQualities(accept)
+	var best assetEncoding
+	bestQ := 0.0
+	for _, enc := range file.encoded {
+		if q := qualityOf(qs, enc.encoding); q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best, bestQ > 0
+}
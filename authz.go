@@ -0,0 +1,244 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ErrUnauthenticated is the error Authorize responds with when a route
+// requires roles or permissions and the request has no Identity.
+var ErrUnauthenticated = errors.New("mux: unauthenticated")
+
+// ErrForbidden is the error Authorize responds with when the Identity of the
+// request lacks what the route requires.
+var ErrForbidden = errors.New("mux: forbidden")
+
+// ErrInsufficientScope is the error Authorize responds with when the Identity
+// of the request lacks the OAuth scopes the route requires. It wraps
+// ErrForbidden.
+var ErrInsufficientScope = fmt.Errorf("%w: insufficient scope", ErrForbidden)
+
+// Authorizer decides whether a request may be served, for plugging a policy
+// engine such as Casbin, or OPA over HTTP, into Authorize. The identity is the
+// zero Identity when the request has none. It returns nil to allow the
+// request, and otherwise the error to respond with: one returned by Error sets
+// the status, and any other is responded to with a 403 of ErrForbidden.
+type Authorizer interface {
+	Authorize(ctx context.Context, id Identity, route RouteInfo, r *http.Request) error
+}
+
+// AuthorizerFunc is an Authorizer implemented by a function.
+type AuthorizerFunc func(ctx context.Context, id Identity, route RouteInfo, r *http.Request) error
+
+// Authorize satisfies the Authorizer interface.
+func (f AuthorizerFunc) Authorize(ctx context.Context, id Identity, route RouteInfo, r *http.Request) error {
+	return f(ctx, id, route, r)
+}
+
+type authorizeOption func(*authorizeHandler)
+
+// RequireRole will require the identity of requests to the route to hold at
+// least one of the roles, once Authorize is in its chain. It costs nothing per
+// request by itself. The roles of a route replace those of its groups.
+func RequireRole(roles ...string) Middleware {
+	if len(roles) == 0 {
+		panic("roles must not be empty")
+	}
+
+	return option(func(rt *route) {
+		rt.roles = append([]string(nil), roles...)
+	})
+}
+
+// RequirePermission will require the identity of requests to the route to
+// have been granted every one of the permissions, once Authorize is in its
+// chain. It costs nothing per request by itself. The permissions of a route
+// replace those of its groups.
+func RequirePermission(permissions ...string) Middleware {
+	if len(permissions) == 0 {
+		panic("permissions must not be empty")
+	}
+
+	return option(func(rt *route) {
+		rt.permissions = append([]string(nil), permissions...)
+	})
+}
+
+// RequireScope will require the identity of requests to the route to have been
+// granted every one of the OAuth scopes, once Authorize is in its chain. It
+// costs nothing per request by itself. The scopes of a route replace those of
+// its groups, and are documented by the openapi module.
+func RequireScope(scopes ...string) Middleware {
+	if len(scopes) == 0 {
+		panic("scopes must not be empty")
+	}
+
+	return option(func(rt *route) {
+		rt.scopes = append([]string(nil), scopes...)
+	})
+}
+
+// Authorize will return middleware enforcing the roles, permissions and scopes
+// the routes it wraps require, against the Identity placed in the request's
+// context by the authentication middleware before it. A request without an
+// Identity, or with an anonymous one, is responded to with a 401 of
+// ErrUnauthenticated, and one lacking what's required with a 403 of
+// ErrForbidden, by the route's ErrorHandler.
+// Lacking scopes are responded to with ErrInsufficientScope and a
+// WWW-Authenticate header listing them, as by RFC 6750. Routes requiring
+// nothing still require an Identity that isn't anonymous. Outside of a route,
+// such as with WrapMiddleware, what's required isn't known and every request
+// is responded to with a 403 of ErrForbidden.
+//
+// Register it after the authentication middleware, such as on the mux with
+// Use. Registering a route requiring roles, permissions or scopes without
+// Authorize in its chain panics.
+func Authorize(options ...authorizeOption) Middleware {
+	return func(next http.Handler) http.Handler {
+		h := &authorizeHandler{next: next}
+		for _, opt := range options {
+			opt(h)
+		}
+
+		return h
+	}
+}
+
+// WithAuthorizer will also ask the Authorizer about every request, once what
+// its route requires is satisfied. It's given the RouteInfo
+// of the route as it was registered, without its current Hits.
+func WithAuthorizer(a Authorizer) authorizeOption {
+	if a == nil {
+		panic("authorizer must not be nil")
+	}
+
+	return func(h *authorizeHandler) {
+		h.authorizer = a
+	}
+}
+
+type authorizeHandler struct {
+	next       http.Handler
+	authorizer Authorizer
+	rt         *route
+	eh         *ErrorHandler
+
+	// info is the RouteInfo given to the authorizer, built on the first
+	// request once the route is fully configured.
+	infoOnce sync.Once
+	info     RouteInfo
+}
+
+// configure will keep the route, since the requirements of routes and groups
+// registered inside this middleware are only set after it's configured.
+func (h *authorizeHandler) configure(rt *route) {
+	h.rt = rt
+	h.eh = rt.errorHandler()
+	rt.authorized = true
+}
+
+func (h *authorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	eh := h.eh
+	if eh == nil {
+		eh = DefaultErrorHandler
+	}
+
+	rt := h.rt
+	if rt == nil {
+		eh.respond(w, r, Error(ErrForbidden, http.StatusForbidden, http.StatusText(http.StatusForbidden)))
+		return
+	}
+
+	id, ok := IdentityOf(r)
+	if !ok || id.Anonymous {
+		if len(rt.scopes) > 0 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		eh.respond(w, r, Error(ErrUnauthenticated, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized)))
+		return
+	}
+
+	if !rt.permits(id) {
+		eh.respond(w, r, Error(ErrForbidden, http.StatusForbidden, http.StatusText(http.StatusForbidden)))
+		return
+	}
+
+	if !rt.scoped(id) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(rt.scopes, " ")+`"`)
+		eh.respond(w, r, Error(ErrInsufficientScope, http.StatusForbidden, http.StatusText(http.StatusForbidden)))
+		return
+	}
+
+	if h.authorizer != nil {
+		if err := h.authorizer.Authorize(r.Context(), id, h.routeInfo(), r); err != nil {
+			var e interface{ StatusMsg() (int, string) }
+			if !errors.As(err, &e) {
+				err = Error(fmt.Errorf("%w: %w", ErrForbidden, err), http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			}
+
+			eh.respond(w, r, err)
+			return
+		}
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+func (h *authorizeHandler) routeInfo() RouteInfo {
+	h.infoOnce.Do(func() {
+		if h.rt != nil {
+			h.info = h.rt.info()
+			h.info.Hits = 0
+		}
+	})
+
+	return h.info
+}
+
+// requires reports whether the route requires anything of the Identity of
+// requests.
+func (rt *route) requires() bool {
+	return len(rt.roles) > 0 || len(rt.permissions) > 0 || len(rt.scopes) > 0
+}
+
+// scoped reports whether the identity was granted every one of the route's
+// scopes.
+func (rt *route) scoped(id Identity) bool {
+	for _, scope := range rt.scopes {
+		if !id.HasScope(scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// permits reports whether the identity holds one of the route's roles, and
+// every one of its permissions.
+func (rt *route) permits(id Identity) bool {
+	if len(rt.roles) > 0 {
+		var held bool
+		for _, role := range rt.roles {
+			if id.HasRole(role) {
+				held = true
+				break
+			}
+		}
+
+		if !held {
+			return false
+		}
+	}
+
+	for _, permission := range rt.permissions {
+		if !id.HasPermission(permission) {
+			return false
+		}
+	}
+
+	return true
+}
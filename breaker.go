@@ -0,0 +1,336 @@
+package mux
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error a CircuitBreaker rejects requests with while its
+// circuit is open.
+var ErrCircuitOpen = errors.New("mux: circuit open")
+
+// BreakerState is the state of the circuit of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed passes requests on, counting their failures.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects requests until the cooldown has passed.
+	BreakerOpen
+
+	// BreakerHalfOpen lets a few trial requests through, to decide whether
+	// to close the circuit again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "BreakerState(" + strconv.Itoa(int(s)) + ")"
+	}
+}
+
+type breakerOption func(*breakerConfig)
+
+// breakerConfig holds the configuration of a CircuitBreaker.
+type breakerConfig struct {
+	rate     float64
+	min      int
+	window   time.Duration
+	cooldown time.Duration
+	trials   int
+	key      KeyFunc
+	failed   func(status int) bool
+	hook     func(pattern, key string, state BreakerState)
+}
+
+// CircuitBreaker will return middleware that stops passing requests on to a
+// failing route, giving its upstream time to recover. Every route it wraps
+// has its own breaker, or one per key with WithBreakerKey.
+//
+// While closed, the responses are counted over a window of 10 seconds, and
+// the circuit opens once at least 20 requests were seen and half of them
+// failed with a 5xx status or a panic. While open, requests are rejected for
+// 30 seconds with ErrCircuitOpen as a 503 by the ErrorHandler of the route,
+// with a Retry-After header. Then a single trial request is let through, and
+// the circuit closes if it succeeds or opens again if it fails.
+func CircuitBreaker(options ...breakerOption) Middleware {
+	cfg := &breakerConfig{
+		rate:     0.5,
+		min:      20,
+		window:   10 * time.Second,
+		cooldown: 30 * time.Second,
+		trials:   1,
+		failed: func(status int) bool {
+			return status >= http.StatusInternalServerError
+		},
+	}
+	for _, opt := range options {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return &breakerHandler{
+			next:     next,
+			cfg:      cfg,
+			eh:       DefaultErrorHandler,
+			breakers: map[string]*breaker{},
+		}
+	}
+}
+
+// WithFailureRate will open the circuit once the rate of failed requests, from
+// 0 to 1, is reached over a window with at least the minimum requests.
+func WithFailureRate(rate float64, minRequests int) breakerOption {
+	if rate <= 0 || rate > 1 {
+		panic("failure rate must be greater than 0 and at most 1")
+	}
+
+	if minRequests < 1 {
+		panic("minimum requests must be at least 1")
+	}
+
+	return func(c *breakerConfig) {
+		c.rate = rate
+		c.min = minRequests
+	}
+}
+
+// WithBreakerWindow will count the failures over windows of the duration.
+func WithBreakerWindow(d time.Duration) breakerOption {
+	if d <= 0 {
+		panic("window must be positive")
+	}
+
+	return func(c *breakerConfig) {
+		c.window = d
+	}
+}
+
+// WithBreakerCooldown will keep the circuit open for the duration before
+// letting trial requests through.
+func WithBreakerCooldown(d time.Duration) breakerOption {
+	if d <= 0 {
+		panic("cooldown must be positive")
+	}
+
+	return func(c *breakerConfig) {
+		c.cooldown = d
+	}
+}
+
+// WithBreakerTrials will let the number of trial requests through while the
+// circuit is half-open. The circuit closes once all of them succeed.
+func WithBreakerTrials(n int) breakerOption {
+	if n < 1 {
+		panic("trials must be at least 1")
+	}
+
+	return func(c *breakerConfig) {
+		c.trials = n
+	}
+}
+
+// WithBreakerKey will keep a breaker per key of a route instead of one, such as
+// per upstream or tenant, so one failing doesn't reject the requests of the
+// others. The keys should be few, their breakers are never removed.
+func WithBreakerKey(key KeyFunc) breakerOption {
+	if key == nil {
+		panic("key must not be nil")
+	}
+
+	return func(c *breakerConfig) {
+		c.key = key
+	}
+}
+
+// WithBreakerFailure will count the responses with the statuses it reports as
+// failures, instead of those of 500 and above. Panics are always failures.
+func WithBreakerFailure(failed func(status int) bool) breakerOption {
+	if failed == nil {
+		panic("failure func must not be nil")
+	}
+
+	return func(c *breakerConfig) {
+		c.failed = failed
+	}
+}
+
+// WithBreakerHook will call the hook whenever a circuit changes state, with
+// the pattern of its route and its key, such as for exporting metrics. It's
+// called while the breaker is locked, so it must not block.
+func WithBreakerHook(hook func(pattern, key string, state BreakerState)) breakerOption {
+	return func(c *breakerConfig) {
+		c.hook = hook
+	}
+}
+
+type breakerHandler struct {
+	next    http.Handler
+	cfg     *breakerConfig
+	pattern string
+	eh      *ErrorHandler
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+func (h *breakerHandler) configure(rt *route) {
+	h.pattern = rt.pattern
+	h.eh = rt.errorHandler()
+}
+
+func (h *breakerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var key string
+	if h.cfg.key != nil {
+		key = h.cfg.key(r)
+	}
+	b := h.breaker(key)
+
+	gen, wait, ok := b.allow(time.Now())
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		h.eh.respond(w, r, Error(ErrCircuitOpen, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable)))
+		return
+	}
+
+	rw := WrapResponseWriter(w)
+	failed := true
+	defer func() {
+		status := rw.Status()
+		ReleaseResponseWriter(rw)
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		b.record(time.Now(), gen, failed || h.cfg.failed(status))
+	}()
+
+	h.next.ServeHTTP(rw, r)
+	failed = false
+}
+
+func (h *breakerHandler) breaker(key string) *breaker {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b, ok := h.breakers[key]
+	if !ok {
+		b = &breaker{cfg: h.cfg}
+		if h.cfg.hook != nil {
+			pattern, hook := h.pattern, h.cfg.hook
+			b.notify = func(state BreakerState) {
+				hook(pattern, key, state)
+			}
+		}
+		h.breakers[key] = b
+	}
+
+	return b
+}
+
+// breaker is the circuit of a route, or of a key of it.
+type breaker struct {
+	cfg    *breakerConfig
+	notify func(BreakerState)
+
+	mu    sync.Mutex
+	state BreakerState
+
+	// gen counts the state changes, so the outcome of a request let through
+	// before the state changed isn't counted in the new one.
+	gen uint64
+
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	openedAt  time.Time
+	trials    int
+	successes int
+}
+
+// allow reports whether a request may be passed on, and the generation to
+// record its outcome with. If not, it returns how long the circuit stays open.
+func (b *breaker) allow(now time.Time) (uint64, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.cfg.cooldown - now.Sub(b.openedAt); wait > 0 {
+			return 0, wait, false
+		}
+		b.transition(BreakerHalfOpen, now)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.trials >= b.cfg.trials {
+			return 0, time.Second, false
+		}
+		b.trials++
+	}
+
+	return b.gen, 0, true
+}
+
+// record will count the outcome of a request let through in the generation.
+func (b *breaker) record(now time.Time, gen uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if gen != b.gen {
+		return
+	}
+
+	switch b.state {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.cfg.window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+
+		b.requests++
+		if failed {
+			b.failures++
+		}
+
+		if b.requests >= b.cfg.min && float64(b.failures) >= b.cfg.rate*float64(b.requests) {
+			b.transition(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.transition(BreakerOpen, now)
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.cfg.trials {
+			b.transition(BreakerClosed, now)
+		}
+	}
+}
+
+// transition will change the state of the circuit, resetting its counts.
+func (b *breaker) transition(state BreakerState, now time.Time) {
+	b.state = state
+	b.gen++
+	b.windowStart, b.requests, b.failures = now, 0, 0
+	b.trials, b.successes = 0, 0
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+
+	if b.notify != nil {
+		b.notify(state)
+	}
+}
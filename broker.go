@@ -0,0 +1,389 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBrokerClosed is the error Broker.Publish returns once the Broker is
+// closed.
+var ErrBrokerClosed = errors.New("mux: broker closed")
+
+// Backlog keeps the events published on a Broker, so the clients reconnecting
+// with a Last-Event-ID receive those they missed.
+type Backlog interface {
+	// Append will keep the event published on the topic.
+	Append(ctx context.Context, topic string, e Event) error
+
+	// Since will return the events of the topics published after the event
+	// of the ID, in the order they were published. If the ID isn't known,
+	// such as when it's no longer kept, every event kept of the topics is
+	// returned.
+	Since(ctx context.Context, lastID string, topics []string) ([]Event, error)
+}
+
+type brokerOption func(*Broker)
+
+// Broker fans out server-sent events published on named topics to the
+// streams subscribed to them. A client reconnecting with the Last-Event-ID
+// header is first sent the events it missed from the Broker's Backlog.
+//
+// A stream that falls behind by more than its buffer is closed, so a slow
+// client can't hold up the others, and its EventSource reconnects to catch up
+// from the Backlog.
+//
+// Clients that can't stream, such as those behind proxies buffering the
+// responses, can long-poll the same topics with Poll.
+type Broker struct {
+	backlog   Backlog
+	buffer    int
+	heartbeat time.Duration
+	seq       atomic.Uint64
+
+	mu     sync.Mutex
+	topics map[string]map[*subscriber]struct{}
+	last   string
+	closed bool
+	done   chan struct{}
+}
+
+type subscriber struct {
+	events chan Event
+	once   sync.Once
+	gone   chan struct{}
+
+	// replayed are the IDs of the events sent from the backlog, which may
+	// also have been published to the subscriber.
+	replayed map[string]bool
+
+	// last is the ID of the event published last when it subscribed.
+	last string
+}
+
+// sent reports whether the event was already sent from the backlog.
+func (s *subscriber) sent(e Event) bool {
+	if !s.replayed[e.ID] {
+		return false
+	}
+
+	delete(s.replayed, e.ID)
+	return true
+}
+
+// drop will end the subscriber's stream, such as when it falls behind.
+func (s *subscriber) drop() {
+	s.once.Do(func() { close(s.gone) })
+}
+
+// NewBroker will return a Broker keeping the last 1000 events in a
+// MemoryBacklog, buffering 64 events per stream, and sending a heartbeat
+// comment every 15 seconds so idle streams aren't cut by proxies.
+func NewBroker(options ...brokerOption) *Broker {
+	b := &Broker{
+		buffer:    64,
+		heartbeat: 15 * time.Second,
+		topics:    map[string]map[*subscriber]struct{}{},
+		done:      make(chan struct{}),
+	}
+	for _, opt := range options {
+		opt(b)
+	}
+
+	if b.backlog == nil {
+		b.backlog = NewMemoryBacklog(1000)
+	}
+
+	return b
+}
+
+// WithBrokerBacklog will keep the events in the backlog, such as one shared by
+// the replicas of a service, instead of a MemoryBacklog of 1000.
+func WithBrokerBacklog(backlog Backlog) brokerOption {
+	if backlog == nil {
+		panic("backlog must not be nil")
+	}
+
+	return func(b *Broker) {
+		b.backlog = backlog
+	}
+}
+
+// WithBrokerBuffer will buffer n events per stream, instead of 64, before it's
+// closed for falling behind.
+func WithBrokerBuffer(n int) brokerOption {
+	if n < 1 {
+		panic("buffer must be at least 1")
+	}
+
+	return func(b *Broker) {
+		b.buffer = n
+	}
+}
+
+// WithBrokerHeartbeat will send a heartbeat comment on idle streams every
+// duration, instead of 15 seconds.
+func WithBrokerHeartbeat(d time.Duration) brokerOption {
+	if d <= 0 {
+		panic("heartbeat must be positive")
+	}
+
+	return func(b *Broker) {
+		b.heartbeat = d
+	}
+}
+
+// Publish will send the event to the streams subscribed to the topic, after
+// keeping it in the Backlog. An event without an ID is given one increasing
+// with every event of the Broker, so the IDs of a Backlog shared by several
+// Brokers must be given by the publisher instead. The events published
+// concurrently are kept and sent in the same order, so a client reconnecting
+// after the last event it received misses none before it.
+func (b *Broker) Publish(ctx context.Context, topic string, e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(b.seq.Add(1), 10)
+	}
+
+	if err := b.backlog.Append(ctx, topic, e); err != nil {
+		return err
+	}
+
+	b.last = e.ID
+	for s := range b.topics[topic] {
+		select {
+		case s.events <- e:
+		default:
+			s.drop()
+		}
+	}
+
+	return nil
+}
+
+// Handler will return a handler streaming the events of the topics to every
+// request, such as for "GET /events".
+func (b *Broker) Handler(topics ...string) http.Handler {
+	if len(topics) == 0 {
+		panic("topics must not be empty")
+	}
+
+	return ErrHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return b.Subscribe(w, r, topics...)
+	})
+}
+
+// Subscribe will stream the events of the topics in response to the request,
+// until the client goes away or the Broker is closed, for handlers choosing
+// the topics by the request, such as by its Identity. The events the client
+// missed since its Last-Event-ID are sent first. It returns an error without
+// responding if the Backlog fails, and ErrBrokerClosed if it's closed.
+func (b *Broker) Subscribe(w http.ResponseWriter, r *http.Request, topics ...string) error {
+	s, missed, err := b.open(r.Context(), r.Header.Get("Last-Event-ID"), topics)
+	if err != nil {
+		return err
+	}
+	defer b.remove(s, topics)
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, e := range missed {
+		if err := WriteEvent(w, e); err != nil {
+			return nil
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(b.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case e := <-s.events:
+			if s.sent(e) {
+				continue
+			}
+			err = WriteEvent(w, e)
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ":\n\n")
+		case <-s.gone:
+			return nil
+		case <-b.done:
+			return nil
+		case <-r.Context().Done():
+			return nil
+		}
+
+		// The client being gone isn't an error of the handler.
+		if err != nil || rc.Flush() != nil {
+			return nil
+		}
+	}
+}
+
+// open will subscribe to the topics, and return the events published since
+// the lastID, if it's not empty. Subscribing before reading the backlog means
+// no event is missed, and those in both are only sent once. The subscriber
+// must be removed once it's done.
+func (b *Broker) open(ctx context.Context, lastID string, topics []string) (*subscriber, []Event, error) {
+	s := &subscriber{events: make(chan Event, b.buffer), gone: make(chan struct{})}
+	if !b.add(s, topics) {
+		return nil, nil, Error(ErrBrokerClosed, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+	}
+
+	if lastID == "" {
+		return s, nil, nil
+	}
+
+	missed, err := b.backlog.Since(ctx, lastID, topics)
+	if err != nil {
+		b.remove(s, topics)
+		return nil, nil, Error(err, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable))
+	}
+
+	s.replayed = make(map[string]bool, len(missed))
+	for _, e := range missed {
+		s.replayed[e.ID] = true
+	}
+
+	return s, missed, nil
+}
+
+func (b *Broker) add(s *subscriber, topics []string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return false
+	}
+
+	s.last = b.last
+	for _, topic := range topics {
+		if b.topics[topic] == nil {
+			b.topics[topic] = map[*subscriber]struct{}{}
+		}
+		b.topics[topic][s] = struct{}{}
+	}
+
+	return true
+}
+
+func (b *Broker) remove(s *subscriber, topics []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, topic := range topics {
+		delete(b.topics[topic], s)
+		if len(b.topics[topic]) == 0 {
+			delete(b.topics, topic)
+		}
+	}
+}
+
+// Subscribers will return the number of streams subscribed to the topic.
+func (b *Broker) Subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.topics[topic])
+}
+
+// Close will end every stream, each finishing its response cleanly, and
+// reject the events published afterward. Register it with Mux.OnDrain, so the
+// streams don't hold up draining the Mux:
+//
+//	m.OnDrain(broker.Close)
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+}
+
+// MemoryBacklog is a Backlog keeping the last events published, of every
+// topic, in memory.
+type MemoryBacklog struct {
+	mu     sync.Mutex
+	events []backlogEvent
+	next   int
+	full   bool
+}
+
+type backlogEvent struct {
+	topic string
+	event Event
+}
+
+// NewMemoryBacklog will return a MemoryBacklog keeping the last n events.
+func NewMemoryBacklog(n int) *MemoryBacklog {
+	if n < 1 {
+		panic("n must be at least 1")
+	}
+
+	return &MemoryBacklog{events: make([]backlogEvent, n)}
+}
+
+// Append satisfies the Backlog interface.
+func (mb *MemoryBacklog) Append(_ context.Context, topic string, e Event) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.events[mb.next] = backlogEvent{topic: topic, event: e}
+	mb.next = (mb.next + 1) % len(mb.events)
+	if mb.next == 0 {
+		mb.full = true
+	}
+
+	return nil
+}
+
+// Since satisfies the Backlog interface.
+func (mb *MemoryBacklog) Since(_ context.Context, lastID string, topics []string) ([]Event, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	kept := mb.events[:mb.next]
+	if mb.full {
+		kept = append(append([]backlogEvent(nil), mb.events[mb.next:]...), mb.events[:mb.next]...)
+	}
+
+	for i := len(kept) - 1; i >= 0; i-- {
+		if kept[i].event.ID == lastID {
+			kept = kept[i+1:]
+			break
+		}
+	}
+
+	var events []Event
+	for _, be := range kept {
+		if contains(topics, be.topic) {
+			events = append(events, be.event)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,178 @@
+package mux
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBulkheadFull is the error a Bulkhead rejects requests with when it has no
+// capacity left.
+var ErrBulkheadFull = errors.New("mux: bulkhead full")
+
+type bulkheadOption func(*Bulkhead)
+
+// Bulkhead limits the requests served at once by the routes it wraps, so a
+// slow group of routes, such as "/reports/", can't use up the capacity needed
+// by another, such as "/checkout/". Register its Middleware on a Group, or on
+// any routes that should share the capacity. Requests beyond the limit are
+// rejected with ErrBulkheadFull as a 503 by the ErrorHandler of the route,
+// unless they can be queued with WithBulkheadQueue.
+type Bulkhead struct {
+	name    string
+	slots   atomic.Pointer[chan struct{}]
+	queue   int64
+	wait    time.Duration
+	reject  func(r *http.Request)
+	waiting atomic.Int64
+
+	served   atomic.Uint64
+	rejected atomic.Uint64
+}
+
+// BulkheadStats is a snapshot of the use of a Bulkhead, such as for exporting
+// metrics.
+type BulkheadStats struct {
+	Name     string `json:"name"`
+	Limit    int    `json:"limit"`
+	Active   int    `json:"active"`
+	Waiting  int    `json:"waiting"`
+	Served   uint64 `json:"served"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// NewBulkhead will return a Bulkhead serving at most limit requests at once.
+// The name identifies it in its stats.
+func NewBulkhead(name string, limit int, options ...bulkheadOption) *Bulkhead {
+	if limit < 1 {
+		panic("bulkhead limit must be at least 1")
+	}
+
+	b := &Bulkhead{name: name}
+	b.SetLimit(limit)
+	for _, opt := range options {
+		opt(b)
+	}
+
+	return b
+}
+
+// WithBulkheadQueue will let up to size requests wait, for at most the
+// duration, for the capacity to serve them before they're rejected. A request
+// stops waiting if its context ends.
+func WithBulkheadQueue(size int, wait time.Duration) bulkheadOption {
+	if size < 1 {
+		panic("bulkhead queue size must be at least 1")
+	}
+
+	if wait <= 0 {
+		panic("bulkhead queue wait must be positive")
+	}
+
+	return func(b *Bulkhead) {
+		b.queue = int64(size)
+		b.wait = wait
+	}
+}
+
+// WithBulkheadReject will call the function with every request the Bulkhead
+// rejects, such as for counting them by route.
+func WithBulkheadReject(reject func(r *http.Request)) bulkheadOption {
+	return func(b *Bulkhead) {
+		b.reject = reject
+	}
+}
+
+// Middleware will limit the requests of the routes it wraps to the capacity of
+// the Bulkhead.
+func (b *Bulkhead) Middleware(next http.Handler) http.Handler {
+	return &bulkheadHandler{bulkhead: b, next: next, eh: DefaultErrorHandler}
+}
+
+// SetLimit will change the limit of the Bulkhead while it's serving, such as
+// to tighten it during an attack. The requests being served when it changes
+// don't count against the new limit, so it holds once they're done.
+func (b *Bulkhead) SetLimit(limit int) {
+	if limit < 1 {
+		panic("bulkhead limit must be at least 1")
+	}
+
+	slots := make(chan struct{}, limit)
+	b.slots.Store(&slots)
+}
+
+// Stats will return the current use of the Bulkhead.
+func (b *Bulkhead) Stats() BulkheadStats {
+	slots := *b.slots.Load()
+	return BulkheadStats{
+		Name:     b.name,
+		Limit:    cap(slots),
+		Active:   len(slots),
+		Waiting:  int(b.waiting.Load()),
+		Served:   b.served.Load(),
+		Rejected: b.rejected.Load(),
+	}
+}
+
+// acquire will take a slot for the request, waiting in the queue if there's
+// room, and return the slots to release it to. It reports false if the
+// request has to be rejected.
+func (b *Bulkhead) acquire(r *http.Request) (chan struct{}, bool) {
+	slots := *b.slots.Load()
+	select {
+	case slots <- struct{}{}:
+		return slots, true
+	default:
+	}
+
+	if b.queue == 0 {
+		return nil, false
+	}
+
+	if b.waiting.Add(1) > b.queue {
+		b.waiting.Add(-1)
+		return nil, false
+	}
+	defer b.waiting.Add(-1)
+
+	timer := time.NewTimer(b.wait)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return slots, true
+	case <-timer.C:
+		return nil, false
+	case <-r.Context().Done():
+		return nil, false
+	}
+}
+
+type bulkheadHandler struct {
+	bulkhead *Bulkhead
+	next     http.Handler
+	eh       *ErrorHandler
+}
+
+func (h *bulkheadHandler) configure(rt *route) {
+	h.eh = rt.errorHandler()
+}
+
+func (h *bulkheadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b := h.bulkhead
+	slots, ok := b.acquire(r)
+	if !ok {
+		b.rejected.Add(1)
+		if b.reject != nil {
+			b.reject(r)
+		}
+
+		h.eh.respond(w, r, Error(ErrBulkheadFull, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable)))
+		return
+	}
+	defer func() { <-slots }()
+
+	b.served.Add(1)
+	h.next.ServeHTTP(w, r)
+}
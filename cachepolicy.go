@@ -0,0 +1,271 @@
+package mux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type cachePolicyOption func(*cachePolicy)
+
+// cachePolicy holds the configuration of a CachePolicy.
+type cachePolicy struct {
+	maxAge time.Duration
+
+	public         bool
+	private        bool
+	noStore        bool
+	immutable      bool
+	mustRevalidate bool
+	noTransform    bool
+
+	sharedMaxAge         time.Duration
+	hasSharedMaxAge      bool
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+
+	surrogate       bool
+	surrogateMaxAge time.Duration
+	surrogateKeys   []string
+
+	// The header values are built once all options are applied.
+	cacheControl     string
+	surrogateControl string
+}
+
+// CachePolicy will return middleware setting the Cache-Control and Expires
+// headers of the successful responses to GET and HEAD requests, so the
+// caching of a route or group is declared where it's registered instead of in
+// its handlers. A maxAge of 0 lets the response be stored but revalidated
+// every time, with "no-cache". The headers aren't set on responses with a
+// status of 400 or above, or when the handler set Cache-Control itself.
+func CachePolicy(maxAge time.Duration, options ...cachePolicyOption) Middleware {
+	if maxAge < 0 {
+		panic("max age must not be negative")
+	}
+
+	p := &cachePolicy{maxAge: maxAge}
+	for _, opt := range options {
+		opt(p)
+	}
+	p.cacheControl = p.buildCacheControl()
+	if p.surrogate {
+		p.surrogateControl = "max-age=" + seconds(p.surrogateMaxAge)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &cacheWriter{ResponseWriter: w, policy: p}
+			next.ServeHTTP(cw, r)
+
+			// The http.Server responds with a 200 if nothing was written.
+			if !cw.wroteHeader {
+				p.apply(w.Header(), http.StatusOK)
+			}
+		})
+	}
+}
+
+// WithCachePublic will let shared caches, such as CDNs, store the response even
+// if it would otherwise be private, such as one to a request with an
+// Authorization header.
+func WithCachePublic() cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.public = true
+	}
+}
+
+// WithCachePrivate will only let the client's own cache store the response.
+func WithCachePrivate() cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.private = true
+	}
+}
+
+// WithNoStore will forbid every cache from storing the response, overriding
+// the other options.
+func WithNoStore() cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.noStore = true
+	}
+}
+
+// WithSharedMaxAge will keep the response fresh in shared caches for the
+// duration instead of the max age, with s-maxage.
+func WithSharedMaxAge(d time.Duration) cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.sharedMaxAge, p.hasSharedMaxAge = d, true
+	}
+}
+
+// WithStaleWhileRevalidate will let caches serve the response for the duration
+// after it's stale while they revalidate it in the background.
+func WithStaleWhileRevalidate(d time.Duration) cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.staleWhileRevalidate = d
+	}
+}
+
+// WithStaleIfError will let caches serve the response for the duration after
+// it's stale when revalidating it fails.
+func WithStaleIfError(d time.Duration) cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.staleIfError = d
+	}
+}
+
+// WithImmutable will tell caches the response never changes while fresh, so
+// they don't revalidate it on reload, such as for fingerprinted assets.
+func WithImmutable() cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.immutable = true
+	}
+}
+
+// WithMustRevalidate will forbid caches from serving the response once it's
+// stale without revalidating it.
+func WithMustRevalidate() cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.mustRevalidate = true
+	}
+}
+
+// WithNoTransform will forbid proxies from changing the response, such as by
+// recompressing images.
+func WithNoTransform() cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.noTransform = true
+	}
+}
+
+// WithSurrogateControl will set the Surrogate-Control header, keeping the
+// response in surrogates such as a CDN for the duration, separately from the
+// caching of browsers. Surrogates remove it before passing the response on.
+func WithSurrogateControl(maxAge time.Duration) cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.surrogateMaxAge, p.surrogate = maxAge, true
+	}
+}
+
+// WithSurrogateKeys will set the Surrogate-Key header, tagging the response so
+// it can be purged from a CDN by its keys.
+func WithSurrogateKeys(keys ...string) cachePolicyOption {
+	return func(p *cachePolicy) {
+		p.surrogateKeys = append(p.surrogateKeys, keys...)
+	}
+}
+
+func (p *cachePolicy) buildCacheControl() string {
+	if p.noStore {
+		return "no-store"
+	}
+
+	var directives []string
+	switch {
+	case p.private:
+		directives = append(directives, "private")
+	case p.public:
+		directives = append(directives, "public")
+	}
+
+	if p.maxAge == 0 {
+		directives = append(directives, "no-cache")
+	} else {
+		directives = append(directives, "max-age="+seconds(p.maxAge))
+	}
+
+	if p.hasSharedMaxAge && !p.private {
+		directives = append(directives, "s-maxage="+seconds(p.sharedMaxAge))
+	}
+	if p.staleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+seconds(p.staleWhileRevalidate))
+	}
+	if p.staleIfError > 0 {
+		directives = append(directives, "stale-if-error="+seconds(p.staleIfError))
+	}
+	if p.mustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if p.immutable {
+		directives = append(directives, "immutable")
+	}
+	if p.noTransform {
+		directives = append(directives, "no-transform")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// apply will set the headers of the policy, unless the handler set its own.
+func (p *cachePolicy) apply(h http.Header, status int) {
+	if status >= http.StatusBadRequest {
+		return
+	}
+
+	if _, ok := h["Cache-Control"]; ok {
+		return
+	}
+
+	h.Set("Cache-Control", p.cacheControl)
+	if p.noStore || p.maxAge == 0 {
+		h.Set("Expires", "0")
+	} else {
+		h.Set("Expires", time.Now().Add(p.maxAge).UTC().Format(http.TimeFormat))
+	}
+
+	if p.surrogate {
+		h.Set("Surrogate-Control", p.surrogateControl)
+	}
+	if len(p.surrogateKeys) > 0 {
+		h.Set("Surrogate-Key", strings.Join(p.surrogateKeys, " "))
+	}
+}
+
+// seconds will format the duration as whole seconds, for a directive.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// cacheWriter applies a cache policy once the status of the response is known.
+type cacheWriter struct {
+	http.ResponseWriter
+	policy      *cachePolicy
+	wroteHeader bool
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= 200 {
+		w.wroteHeader = true
+		w.policy.apply(w.Header(), code)
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush will send the header along with what was written so far.
+func (w *cacheWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
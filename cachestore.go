@@ -0,0 +1,118 @@
+package mux
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// CacheStore keeps byte payloads by key for a time, for the middleware caching
+// responses. Implementations must be safe for concurrent use. The in-memory
+// MemoryStore is used by default, and a store shared by several replicas, such
+// as that of the redis module, lets them share a cache.
+type CacheStore interface {
+	// Get will return the value of the key, reporting false when it's
+	// missing or has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set will keep the value of the key for the ttl, replacing any value it
+	// had. The store is free to evict it before then.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete will remove the key, if it's present.
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore is a CacheStore keeping its values in memory, evicting the least
+// recently used once it's full.
+type MemoryStore struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryStore will return a MemoryStore keeping at most size values.
+func NewMemoryStore(size int) *MemoryStore {
+	if size < 1 {
+		panic("store size must be at least 1")
+	}
+
+	return &MemoryStore{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Get satisfies the CacheStore interface.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	e := el.Value.(*memoryEntry)
+	if !time.Now().Before(e.expires) {
+		s.remove(el)
+		return nil, false, nil
+	}
+
+	s.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+// Set satisfies the CacheStore interface. The value is kept as is, so it must
+// not be changed afterward.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	} else if s.order.Len() >= s.size {
+		s.remove(s.order.Back())
+	}
+
+	e := &memoryEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	s.entries[key] = s.order.PushFront(e)
+
+	return nil
+}
+
+// Delete satisfies the CacheStore interface.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+
+	return nil
+}
+
+// Len will return the number of values in the store, including those that
+// expired but weren't evicted yet.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*memoryEntry).key)
+}
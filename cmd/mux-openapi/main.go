@@ -0,0 +1,52 @@
+// Command mux-openapi generates the routes, handler stubs, and types of an
+// OpenAPI 3.0 document for the mux package. Use it with go generate:
+//
+//	//go:generate go run github.com/kevinfalting/mux/cmd/mux-openapi -spec openapi.json -pkg api -o api_gen.go
+//
+// The generated Register function registers the document's operations on a
+// mux.Router, using the methods of a Handlers implementation. Embed the
+// generated Unimplemented type to implement the Handlers one at a time.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kevinfalting/mux/openapi"
+)
+
+func main() {
+	spec := flag.String("spec", "openapi.json", "path of the OpenAPI 3.0 JSON document")
+	pkg := flag.String("pkg", os.Getenv("GOPACKAGE"), "name of the generated package")
+	out := flag.String("o", "", "path of the generated file, or standard output if empty")
+	flag.Parse()
+
+	if err := run(*spec, *pkg, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "mux-openapi:", err)
+		os.Exit(1)
+	}
+}
+
+func run(spec, pkg, out string) error {
+	if pkg == "" {
+		return fmt.Errorf("no package name, set -pkg or run with go generate")
+	}
+
+	doc, err := openapi.Load(spec)
+	if err != nil {
+		return err
+	}
+
+	src, err := openapi.Scaffold(doc, pkg)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+
+	return os.WriteFile(out, src, 0o644)
+}
@@ -0,0 +1,220 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type coalesceOption func(*coalescer)
+
+// coalescer holds the requests being served by a Coalesce middleware.
+type coalescer struct {
+	headers []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is a request being served for every identical request that
+// arrived while it was.
+type coalescedCall struct {
+	done     chan struct{}
+	response *bufferedResponse
+	panicked bool
+}
+
+// Coalesce will return middleware serving identical GET and HEAD requests
+// arriving concurrently with a single execution of the rest of the chain, so
+// a burst of requests for an expensive read runs it once. The response is
+// buffered and written to every request. Requests are identical when they
+// share the method, host, path and query, and the values of the headers
+// selected with WithCoalesceHeader, so select those the response depends on,
+// such as Accept-Encoding.
+//
+// Requests carrying credentials, a Cookie, Authorization or X-Api-Key header,
+// are served on their own unless that header is selected, as are those with an
+// Identity or a client certificate, so one user's response is never written to
+// another. A Set-Cookie header of the response is only written to the request
+// that ran the chain.
+//
+// The chain runs with the context of the first request, without its
+// cancellation, so one client going away doesn't fail the others. Streaming
+// responses are buffered whole, so don't coalesce them.
+func Coalesce(options ...coalesceOption) Middleware {
+	c := &coalescer{calls: map[string]*coalescedCall{}}
+	for _, opt := range options {
+		opt(c)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !c.coalescable(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := c.key(r)
+			c.mu.Lock()
+			call, ok := c.calls[key]
+			if !ok {
+				call = &coalescedCall{done: make(chan struct{})}
+				c.calls[key] = call
+			}
+			c.mu.Unlock()
+
+			if !ok {
+				c.serve(key, call, next, r)
+			} else {
+				select {
+				case <-call.done:
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+			if call.panicked {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			if !ok {
+				call.response.writeTo(w)
+			} else {
+				call.response.writeShared(w)
+			}
+		})
+	}
+}
+
+// WithCoalesceHeader will only coalesce requests that have the same values of
+// the headers.
+func WithCoalesceHeader(names ...string) coalesceOption {
+	return func(c *coalescer) {
+		for _, name := range names {
+			c.headers = append(c.headers, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// serve will run the chain for the call, then release the requests waiting on
+// it. A panic is passed on once they're released.
+func (c *coalescer) serve(key string, call *coalescedCall, next http.Handler, r *http.Request) {
+	call.panicked = true
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.response = newBufferedResponse()
+	next.ServeHTTP(call.response, r.WithContext(context.WithoutCancel(r.Context())))
+	call.panicked = false
+}
+
+// credentialHeaders are the headers of a request that make its response
+// specific to the user sending it.
+var credentialHeaders = []string{"Authorization", "Cookie", "X-Api-Key"}
+
+// coalescable reports whether the request may share the response of another,
+// because it carries no credentials the key doesn't include, and no Identity
+// or client certificate the key can't.
+func (c *coalescer) coalescable(r *http.Request) bool {
+	if _, ok := IdentityOf(r); ok {
+		return false
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return false
+	}
+
+	for _, name := range credentialHeaders {
+		if len(r.Header.Values(name)) > 0 && !contains(c.headers, name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *coalescer) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.Host)
+	b.WriteString(r.URL.RequestURI())
+	for _, name := range c.headers {
+		b.WriteByte('\n')
+		b.WriteString(strings.Join(r.Header.Values(name), ", "))
+	}
+
+	return b.String()
+}
+
+// bufferedResponse is an http.ResponseWriter keeping the response, so it can
+// be written to several requests.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: http.Header{}}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.status == 0 && code >= 200 {
+		b.status = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+
+	return b.body.Write(p)
+}
+
+// writeTo will write the response to w. Every request gets its own copy of the
+// header values.
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	b.write(w, false)
+}
+
+// writeShared will write the response to w, a request other than the one it
+// was served for, leaving out the cookies it set.
+func (b *bufferedResponse) writeShared(w http.ResponseWriter) {
+	b.write(w, true)
+}
+
+func (b *bufferedResponse) write(w http.ResponseWriter, shared bool) {
+	h := w.Header()
+	for key, values := range b.header {
+		if shared && key == "Set-Cookie" {
+			continue
+		}
+		h[key] = append([]string(nil), values...)
+	}
+
+	status := b.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	w.WriteHeader(status)
+	w.Write(b.body.Bytes())
+}
@@ -0,0 +1,431 @@
+package mux
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLevel is the compression level asking an Encoder for its default.
+const DefaultLevel = -1
+
+// Encoder compresses responses with a content coding, for Compress. Gzip is
+// built in, the compress module provides brotli and zstd.
+type Encoder interface {
+	// Encoding will return the name of the content coding, as it appears
+	// in the Accept-Encoding and Content-Encoding headers, such as "gzip".
+	Encoding() string
+
+	// NewWriter will return a writer compressing to w at the level, or
+	// the encoder's default level if it's DefaultLevel.
+	NewWriter(w io.Writer, level int) (EncoderWriter, error)
+}
+
+// EncoderWriter is a compressing writer returned by an Encoder. Compress
+// pools them, resetting them for every response.
+type EncoderWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Gzip will return the Encoder for the gzip content coding.
+func Gzip() Encoder {
+	return gzipEncoder{}
+}
+
+type gzipEncoder struct{}
+
+func (gzipEncoder) Encoding() string {
+	return "gzip"
+}
+
+func (gzipEncoder) NewWriter(w io.Writer, level int) (EncoderWriter, error) {
+	if level == DefaultLevel {
+		level = gzip.DefaultCompression
+	}
+
+	return gzip.NewWriterLevel(w, level)
+}
+
+type compressOption func(*compressor)
+
+// compressor holds the configuration of a Compress middleware.
+type compressor struct {
+	encoders []Encoder
+	levels   map[string][]typeLevel
+	types    []string
+	minSize  int
+
+	// pools holds the writers of every encoding and level, built once all
+	// options are applied.
+	pools map[poolKey]*sync.Pool
+}
+
+// typeLevel is the compression level of an encoding for the content types
+// beginning with a prefix.
+type typeLevel struct {
+	prefix string
+	level  int
+}
+
+type poolKey struct {
+	encoding string
+	level    int
+}
+
+// Compress will return middleware compressing responses with the encoding the
+// client prefers by the q-values of its Accept-Encoding header, breaking ties
+// by the order of the encoders. Only gzip is used unless WithEncoders sets
+// others.
+//
+// Responses are compressed when their content type is text, JSON,
+// JavaScript, XML, SVG or WebAssembly, and their body is at least 1KB. They
+// aren't when they already have a Content-Encoding, are partial, or have no
+// body. A strong ETag is made weak, since the compressed body differs byte for
+// byte, and "Vary: Accept-Encoding" is added to every response that could be
+// compressed.
+func Compress(options ...compressOption) Middleware {
+	c := &compressor{
+		encoders: []Encoder{Gzip()},
+		levels:   map[string][]typeLevel{},
+		types: []string{
+			"text/",
+			"application/json",
+			"application/javascript",
+			"application/xml",
+			"application/wasm",
+			"image/svg+xml",
+		},
+		minSize: 1024,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+
+	c.pools = map[poolKey]*sync.Pool{}
+	for _, enc := range c.encoders {
+		enc := enc
+		levels := []int{DefaultLevel}
+		for _, tl := range c.levels[enc.Encoding()] {
+			levels = append(levels, tl.level)
+		}
+
+		for _, level := range levels {
+			level := level
+			c.pools[poolKey{enc.Encoding(), level}] = &sync.Pool{
+				New: func() any {
+					ew, err := enc.NewWriter(io.Discard, level)
+					if err != nil {
+						panic("compress: " + err.Error())
+					}
+					return ew
+				},
+			}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				c:              c,
+				enc:            negotiateEncoding(r.Header.Values("Accept-Encoding"), c.encoders),
+			}
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}
+}
+
+// WithEncoders will compress with the encoders, in order of preference,
+// instead of only gzip.
+func WithEncoders(encoders ...Encoder) compressOption {
+	if len(encoders) == 0 {
+		panic("encoders must not be empty")
+	}
+
+	return func(c *compressor) {
+		c.encoders = encoders
+	}
+}
+
+// WithCompressLevel will compress the content types beginning with the prefix,
+// such as "text/" or "application/json", at the level with the encoding. The
+// longest matching prefix is used, and an empty prefix sets the level of every
+// other type. Levels are those of the encoder, such as 1 to 9 for gzip.
+func WithCompressLevel(encoding, contentType string, level int) compressOption {
+	return func(c *compressor) {
+		encoding = strings.ToLower(encoding)
+		c.levels[encoding] = append(c.levels[encoding], typeLevel{prefix: strings.ToLower(contentType), level: level})
+		sort.SliceStable(c.levels[encoding], func(i, j int) bool {
+			return len(c.levels[encoding][i].prefix) > len(c.levels[encoding][j].prefix)
+		})
+	}
+}
+
+// WithCompressTypes will compress the content types beginning with the
+// prefixes, instead of the default ones.
+func WithCompressTypes(prefixes ...string) compressOption {
+	return func(c *compressor) {
+		c.types = nil
+		for _, prefix := range prefixes {
+			c.types = append(c.types, strings.ToLower(prefix))
+		}
+	}
+}
+
+// WithMinCompressSize will only compress bodies of at least n bytes, instead of
+// 1KB. Smaller ones, or ones flushed sooner, are buffered to find out.
+func WithMinCompressSize(n int) compressOption {
+	if n < 0 {
+		panic("min size must not be negative")
+	}
+
+	return func(c *compressor) {
+		c.minSize = n
+	}
+}
+
+// compressible reports whether the content type is one to compress.
+func (c *compressor) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+
+	for _, prefix := range c.types {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// level will return the level to compress the content type at with the
+// encoding.
+func (c *compressor) level(encoding, contentType string) int {
+	mediaType := strings.ToLower(contentType)
+	for _, tl := range c.levels[encoding] {
+		if strings.HasPrefix(mediaType, tl.prefix) {
+			return tl.level
+		}
+	}
+
+	return DefaultLevel
+}
+
+// negotiateEncoding will return the encoder the Accept-Encoding values prefer,
+// or nil if the response shouldn't be encoded.
+func negotiateEncoding(accept []string, encoders []Encoder) Encoder {
+	if len(accept) == 0 {
+		return nil
+	}
+
+	qs := encodingQualities(accept)
+	var best Encoder
+	bestQ := 0.0
+	for _, enc := range encoders {
+		if q := qualityOf(qs, enc.Encoding()); q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best
+}
+
+// encodingQualities will return the q-values of the encodings listed by the
+// values of an Accept-Encoding header, by their lowercased names.
+func encodingQualities(accept []string) map[string]float64 {
+	qs := map[string]float64{}
+	for _, value := range accept {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(key, "q") {
+					if v, err := strconv.ParseFloat(value, 64); err == nil {
+						q = v
+					}
+				}
+			}
+			qs[name] = q
+		}
+	}
+
+	return qs
+}
+
+// qualityOf will return the q-value of the encoding, or that of "*" if it
+// isn't listed. It's zero when the encoding isn't accepted.
+func qualityOf(qs map[string]float64, encoding string) float64 {
+	if q, ok := qs[encoding]; ok {
+		return q
+	}
+
+	return qs["*"]
+}
+
+// compressWriter buffers the start of a response until it's known whether to
+// compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	c   *compressor
+	enc Encoder
+
+	status  int
+	decided bool
+	buf     []byte
+
+	ew   EncoderWriter
+	pool *sync.Pool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.c.minSize {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+
+	if w.ew != nil {
+		return w.ew.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush will send what was written so far, compressed if the response is,
+// however small.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.decide(true)
+	}
+
+	if w.ew != nil {
+		w.ew.Flush()
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide will write the header, compressing the response if it should be, and
+// then what was buffered. If the body may be larger than what was buffered,
+// its size isn't held against it. It returns the error of writing what was
+// buffered.
+func (w *compressWriter) decide(more bool) error {
+	w.decided = true
+	h := w.Header()
+
+	contentType := h.Get("Content-Type")
+	if _, ok := h["Content-Type"]; !ok && len(w.buf) > 0 {
+		contentType = http.DetectContentType(w.buf)
+		h.Set("Content-Type", contentType)
+	}
+
+	compress := w.status != http.StatusNoContent && w.status != http.StatusNotModified &&
+		w.status != http.StatusPartialContent && h.Get("Content-Encoding") == "" &&
+		h.Get("Content-Range") == "" && w.c.compressible(contentType)
+
+	if compress {
+		Vary(h, "Accept-Encoding")
+	}
+
+	if !more && len(w.buf) < w.c.minSize {
+		compress = false
+	}
+
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < w.c.minSize {
+		compress = false
+	}
+
+	if compress && w.enc != nil {
+		encoding := w.enc.Encoding()
+		h.Set("Content-Encoding", encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		w.pool = w.c.pools[poolKey{encoding, w.c.level(encoding, contentType)}]
+		w.ew = w.pool.Get().(EncoderWriter)
+		w.ew.Reset(w.ResponseWriter)
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.ew != nil {
+		_, err = w.ew.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+// close will finish the response once the handler has returned.
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+
+	if w.ew != nil {
+		w.ew.Close()
+		w.ew.Reset(io.Discard)
+		w.pool.Put(w.ew)
+		w.ew = nil
+	}
+}
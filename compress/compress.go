@@ -0,0 +1,64 @@
+/*
+Package compress provides the brotli and zstd Encoders for mux.Compress, kept
+in their own module so the mux doesn't depend on them.
+
+	m := mux.New(mux.Compress(mux.WithEncoders(compress.Brotli(), compress.Zstd(), mux.Gzip())))
+*/
+package compress
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/kevinfalting/mux"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Brotli will return the Encoder for the br content coding. Its levels go from
+// 0 to 11, and it defaults to 5, a trade of speed for size suited to dynamic
+// responses.
+func Brotli() mux.Encoder {
+	return brotliEncoder{}
+}
+
+type brotliEncoder struct{}
+
+func (brotliEncoder) Encoding() string {
+	return "br"
+}
+
+func (brotliEncoder) NewWriter(w io.Writer, level int) (mux.EncoderWriter, error) {
+	if level == mux.DefaultLevel {
+		level = 5
+	}
+
+	return brotli.NewWriterLevel(w, level), nil
+}
+
+// Zstd will return the Encoder for the zstd content coding. Its levels are
+// those of zstd.EncoderLevel, from 1 for the fastest to 4 for the best
+// compression, and it defaults to zstd.SpeedDefault.
+func Zstd() mux.Encoder {
+	return zstdEncoder{}
+}
+
+type zstdEncoder struct{}
+
+func (zstdEncoder) Encoding() string {
+	return "zstd"
+}
+
+func (zstdEncoder) NewWriter(w io.Writer, level int) (mux.EncoderWriter, error) {
+	encoderLevel := zstd.SpeedDefault
+	if level != mux.DefaultLevel {
+		encoderLevel = zstd.EncoderLevel(level)
+	}
+
+	// A single goroutine per stream, and a window browsers accept; the
+	// encoders are pooled per response rather than shared.
+	return zstd.NewWriter(w,
+		zstd.WithEncoderLevel(encoderLevel),
+		zstd.WithEncoderConcurrency(1),
+		zstd.WithWindowSize(8<<20),
+	)
+}
@@ -0,0 +1,12 @@
+module github.com/kevinfalting/mux/compress
+
+go 1.22
+
+require (
+	github.com/andybalholm/brotli v1.2.0
+	github.com/kevinfalting/mux v0.0.0
+)
+
+require github.com/klauspost/compress v1.18.0
+
+replace github.com/kevinfalting/mux => ../
@@ -0,0 +1,120 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WriteIfChanged will answer a conditional request for content of the etag or
+// last modification time, calling writeBody to write the response only if the
+// client's copy is out of date. Otherwise it responds with a 304 for GET and
+// HEAD requests, and a 412 when a precondition of another method fails. It's
+// for handlers that know the version of their content up front, without
+// buffering the response to hash it.
+//
+// The preconditions are evaluated in the order of RFC 9110: If-Match, then
+// If-Unmodified-Since when there's no If-Match, then If-None-Match, then
+// If-Modified-Since when there's no If-None-Match. An etag without quotes is
+// quoted, and either may be left empty or zero. The ETag and Last-Modified
+// headers are set on the response.
+func WriteIfChanged(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time, writeBody func()) {
+	etag = quoteETag(etag)
+	h := w.Header()
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if status := precondition(r, etag, lastModified); status != 0 {
+		if status == http.StatusNotModified {
+			// A 304 has no content, so the headers describing it are
+			// removed, as by http.ServeContent.
+			delete(h, "Content-Type")
+			delete(h, "Content-Length")
+			delete(h, "Content-Encoding")
+		}
+
+		w.WriteHeader(status)
+		return
+	}
+
+	writeBody()
+}
+
+// precondition will return the status to respond to the request with, or zero
+// if the conditional request should be served in full.
+func precondition(r *http.Request, etag string, lastModified time.Time) int {
+	if values := r.Header.Values("If-Match"); len(values) > 0 {
+		if !matchETag(values, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, ok := headerTime(r, "If-Unmodified-Since"); ok && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if values := r.Header.Values("If-None-Match"); len(values) > 0 {
+		if !matchETag(values, etag, true) {
+			return 0
+		}
+
+		if safe {
+			return http.StatusNotModified
+		}
+
+		return http.StatusPreconditionFailed
+	}
+
+	if since, ok := headerTime(r, "If-Modified-Since"); ok && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// matchETag reports whether a list of entity tags of a conditional header
+// matches the etag, comparing them weakly or strongly. A "*" matches any
+// current etag.
+func matchETag(values []string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			switch {
+			case tag == "*":
+				return true
+			case weak && strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/"):
+				return true
+			case !weak && tag == etag && !strings.HasPrefix(etag, "W/"):
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// quoteETag will quote the etag, unless it's empty or already quoted.
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+
+	return `"` + etag + `"`
+}
+
+func headerTime(r *http.Request, name string) (time.Time, bool) {
+	t, err := http.ParseTime(r.Header.Get(name))
+	return t, err == nil
+}
@@ -0,0 +1,67 @@
+package mux
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ServerConns are the counts of the connections open on a Server, by their
+// state.
+type ServerConns struct {
+	// Open is the number of connections open, in any state.
+	Open int `json:"open"`
+
+	// Active is the number of connections reading or serving a request.
+	Active int `json:"active"`
+
+	// Idle is the number of kept alive connections between requests.
+	Idle int `json:"idle"`
+}
+
+// Connections will return the counts of the connections open on the listeners
+// of the Server, including those of its admin Mux. Those of its FastCGI
+// listeners aren't known, and hijacked connections, such as WebSockets, stop
+// being counted once they're hijacked.
+func (s *Server) Connections() ServerConns {
+	return s.conns.counts()
+}
+
+// connTracker records the state of the connections of an http.Server, from
+// its ConnState hook.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(t.conns, c)
+	default:
+		if t.conns == nil {
+			t.conns = map[net.Conn]http.ConnState{}
+		}
+		t.conns[c] = state
+	}
+}
+
+func (t *connTracker) counts() ServerConns {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	counts := ServerConns{Open: len(t.conns)}
+	for _, state := range t.conns {
+		switch state {
+		case http.StateActive:
+			counts.Active++
+		case http.StateIdle:
+			counts.Idle++
+		}
+	}
+
+	return counts
+}
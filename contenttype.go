@@ -0,0 +1,74 @@
+package mux
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ByContentType will return a handler that selects the handler of the media
+// type of the request's Content-Type, such as {"application/json": j,
+// "multipart/form-data": f}, for a route accepting its body in several
+// formats. A media type like "image/*" selects any subtype without a handler
+// of its own. Parameters such as the charset are ignored when selecting, and
+// left for the handler.
+//
+// Requests with any other media type, or none, are answered with a 415
+// listing the supported ones, and an Accept-Post or Accept-Patch header
+// listing them for POST or PATCH requests, as by RFC 9110.
+func ByContentType(handlers map[string]http.Handler) http.Handler {
+	if len(handlers) == 0 {
+		panic("handlers must not be empty")
+	}
+
+	types := make(map[string]http.Handler, len(handlers))
+	names := make([]string, 0, len(handlers))
+	for name, h := range handlers {
+		mt, _, err := mime.ParseMediaType(name)
+		if err != nil || !strings.Contains(mt, "/") {
+			panic(fmt.Sprintf("invalid media type %q", name))
+		}
+
+		if h == nil {
+			panic(fmt.Sprintf("handler for media type %q must not be nil", name))
+		}
+
+		if _, ok := types[mt]; ok {
+			panic(fmt.Sprintf("media type %q is given twice", mt))
+		}
+
+		types[mt] = h
+		names = append(names, mt)
+	}
+	sort.Strings(names)
+	supported := strings.Join(names, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err == nil {
+			if h, ok := types[mt]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if i := strings.Index(mt, "/"); i > 0 {
+				if h, ok := types[mt[:i]+"/*"]; ok {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Accept-Post", supported)
+		case http.MethodPatch:
+			w.Header().Set("Accept-Patch", supported)
+		}
+
+		msg := fmt.Sprintf("unsupported content type %q, supported types: %s", r.Header.Get("Content-Type"), supported)
+		http.Error(w, msg, http.StatusUnsupportedMediaType)
+	})
+}
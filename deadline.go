@@ -0,0 +1,213 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeoutHeader is the header PropagateDeadline reads the timeout of a request
+// from, and DeadlineTransport sends the remaining time in, as a duration such
+// as "1.5s" or "250ms", or as a number of seconds.
+const TimeoutHeader = "X-Request-Timeout"
+
+type deadlineOption func(*deadlineConfig)
+
+// deadlineConfig holds the configuration of PropagateDeadline.
+type deadlineConfig struct {
+	headers []string
+	max     time.Duration
+}
+
+// PropagateDeadline will return middleware applying the timeout a client sent
+// to the request's context, so the handler stops working on a request its
+// client has given up on. The timeout is read from the grpc-timeout header,
+// or else from the X-Request-Timeout header. Requests to other services made
+// with a DeadlineTransport carry the time remaining, so timeouts compose
+// across services. A request whose timeout has already passed is answered
+// with a 504 by the ErrorHandler of the route.
+func PropagateDeadline(options ...deadlineOption) Middleware {
+	cfg := &deadlineConfig{}
+	for _, opt := range options {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return &deadlineHandler{next: next, cfg: cfg, eh: DefaultErrorHandler}
+	}
+}
+
+// WithTimeoutHeader will also read the timeout from the header, after the
+// grpc-timeout and X-Request-Timeout headers, in the format of the latter.
+func WithTimeoutHeader(name string) deadlineOption {
+	return func(c *deadlineConfig) {
+		c.headers = append(c.headers, name)
+	}
+}
+
+// WithMaxTimeout will limit the timeout a client can set, and apply it to the
+// requests without one.
+func WithMaxTimeout(d time.Duration) deadlineOption {
+	if d <= 0 {
+		panic("max timeout must be positive")
+	}
+
+	return func(c *deadlineConfig) {
+		c.max = d
+	}
+}
+
+type deadlineHandler struct {
+	next http.Handler
+	cfg  *deadlineConfig
+	eh   *ErrorHandler
+}
+
+func (h *deadlineHandler) configure(rt *route) {
+	h.eh = rt.errorHandler()
+}
+
+func (h *deadlineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout, ok := h.timeout(r)
+	if !ok {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	if timeout <= 0 {
+		h.eh.respond(w, r, Error(context.DeadlineExceeded, http.StatusGatewayTimeout, http.StatusText(http.StatusGatewayTimeout)))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	h.next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// timeout will return the timeout of the request, and report false if it has
+// none.
+func (h *deadlineHandler) timeout(r *http.Request) (time.Duration, bool) {
+	timeout, ok := parseGRPCTimeout(r.Header.Get("Grpc-Timeout"))
+	if !ok {
+		timeout, ok = parseTimeout(r.Header.Get(TimeoutHeader))
+	}
+	for _, name := range h.cfg.headers {
+		if ok {
+			break
+		}
+		timeout, ok = parseTimeout(r.Header.Get(name))
+	}
+
+	if h.cfg.max > 0 && (!ok || timeout > h.cfg.max) {
+		return h.cfg.max, true
+	}
+
+	return timeout, ok
+}
+
+// parseTimeout will parse a timeout as a duration, or a number of seconds.
+func parseTimeout(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+
+	d, err := time.ParseDuration(value)
+	return d, err == nil
+}
+
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGRPCTimeout will parse the value of a grpc-timeout header: at most 8
+// digits followed by a unit.
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
+
+// DeadlineTransport will return a RoundTripper that sends the time remaining
+// before the deadline of each request's context in the X-Request-Timeout
+// header, or the grpc-timeout header for gRPC requests, so the next service
+// stops when this one would give up. Requests are made with next, or with
+// http.DefaultTransport if it's nil. Make requests with the context of the
+// request being served, such as with Proxy and WithTransport.
+func DeadlineTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &deadlineTransport{next: next}
+}
+
+type deadlineTransport struct {
+	next http.RoundTripper
+}
+
+func (t *deadlineTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		return t.next.RoundTrip(r)
+	}
+
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return nil, context.DeadlineExceeded
+	}
+
+	// A RoundTripper must not change the request it's given.
+	r = r.Clone(r.Context())
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		r.Header.Set("Grpc-Timeout", formatGRPCTimeout(remaining))
+	} else {
+		r.Header.Set(TimeoutHeader, strconv.FormatInt(remaining.Milliseconds(), 10)+"ms")
+	}
+
+	return t.next.RoundTrip(r)
+}
+
+// formatGRPCTimeout will format the timeout with the finest unit that fits in
+// the 8 digits gRPC allows.
+func formatGRPCTimeout(d time.Duration) string {
+	for _, unit := range []struct {
+		suffix string
+		d      time.Duration
+	}{
+		{"n", time.Nanosecond},
+		{"u", time.Microsecond},
+		{"m", time.Millisecond},
+		{"S", time.Second},
+		{"M", time.Minute},
+	} {
+		if n := d / unit.d; n < 1e8 {
+			return strconv.FormatInt(int64(n), 10) + unit.suffix
+		}
+	}
+
+	return strconv.FormatInt(int64(d/time.Hour), 10) + "H"
+}
@@ -0,0 +1,96 @@
+package mux
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// debugRoute is a route as served by the debug routes, with its hits.
+type debugRoute struct {
+	RouteInfo
+	Hits uint64 `json:"hits"`
+}
+
+// debugTable is what the debug routes serve.
+type debugTable struct {
+	Stats  Stats        `json:"stats"`
+	Routes []debugRoute `json:"routes"`
+}
+
+// AttachDebug will register routes under the prefix describing the route table
+// of the Mux, for the engineers on call: an HTML page of every route with its
+// methods, middleware, and hits at the prefix, and the same as JSON at
+// "routes.json" under it, along with the Stats of the table. They're wrapped
+// in the middleware, which must protect them, such as Internal or one
+// authenticating the engineers, so at least one is required.
+func AttachDebug(m *Mux, prefix string, mw ...Middleware) {
+	if len(mw) == 0 {
+		panic("debug routes must be protected by middleware, such as Internal")
+	}
+
+	attachDebug(m, m, prefix, mw)
+}
+
+// attachDebug will register the debug routes of the Mux on the router.
+func attachDebug(router Router, m *Mux, prefix string, mw []Middleware) {
+	g := router.Group(prefix, nil, mw...)
+	g.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := debugPage.Execute(w, m.debugTable()); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+
+	g.HandleFunc("GET /routes.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(m.debugTable())
+	})
+}
+
+func (m *Mux) debugTable() debugTable {
+	routes := m.Routes()
+	table := debugTable{Stats: m.Stats(), Routes: make([]debugRoute, len(routes))}
+	for i, ri := range routes {
+		table.Routes[i] = debugRoute{RouteInfo: ri, Hits: ri.Hits}
+	}
+
+	return table
+}
+
+var debugPage = template.Must(template.New("debug").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Routes</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 0.8em; text-align: left; border-bottom: 1px solid #ddd; vertical-align: top; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+code { font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Routes</h1>
+<p>{{.Stats.Routes}} routes, {{.Stats.Patterns}} patterns, {{.Stats.Matchers}} matchers, {{.Stats.Middleware}} middleware, about {{.Stats.Bytes}} bytes. <a href="routes.json">JSON</a></p>
+<table>
+<tr><th>Pattern</th><th>Methods</th><th>Middleware</th><th>Hits</th><th>Notes</th></tr>
+{{range .Routes}}<tr>
+<td><code>{{.Pattern}}</code></td>
+<td>{{if .Methods}}{{join .Methods ", "}}{{else}}*{{end}}</td>
+<td>{{join .Middleware " → "}}</td>
+<td class="n">{{.Hits}}</td>
+<td>{{if .Internal}}internal {{end}}{{if .Deprecation}}deprecated {{end}}{{with .AliasOf}}alias of <code>{{.}}</code> {{end}}{{with .Roles}}roles {{join . ", "}} {{end}}{{with .Permissions}}permissions {{join . ", "}} {{end}}{{with .Scopes}}scopes {{join . ", "}}{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
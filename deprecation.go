@@ -0,0 +1,79 @@
+package mux
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Deprecation describes when a deprecated route was deprecated, when it will
+// stop being served, and what replaces it.
+type Deprecation struct {
+	// Since is when the route was deprecated. If it's zero, the Deprecation
+	// header is sent as "true".
+	Since time.Time `json:"since"`
+
+	// Sunset is when the route will stop being served, sent in the Sunset
+	// header. It's omitted if zero.
+	Sunset time.Time `json:"sunset"`
+
+	// Successor is the URL of the route replacing this one, sent in a Link
+	// header with the "successor-version" relation. It's omitted if empty.
+	Successor string `json:"successor,omitempty"`
+}
+
+// Deprecated will mark the route as deprecated, adding the Deprecation, Sunset
+// (RFC 8594), and Link headers to its responses. Used with a Group, it marks
+// every route of the group. Deprecated routes are reported by Routes.
+func Deprecated(d Deprecation) Middleware {
+	deprecation := "true"
+	if !d.Since.IsZero() {
+		deprecation = "@" + strconv.FormatInt(d.Since.Unix(), 10)
+	}
+
+	var sunset string
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+
+	var link string
+	if d.Successor != "" {
+		link = "<" + d.Successor + `>; rel="successor-version"`
+	}
+
+	return func(next http.Handler) http.Handler {
+		return &deprecatedHandler{
+			next:        next,
+			d:           d,
+			deprecation: deprecation,
+			sunset:      sunset,
+			link:        link,
+		}
+	}
+}
+
+type deprecatedHandler struct {
+	next        http.Handler
+	d           Deprecation
+	deprecation string
+	sunset      string
+	link        string
+}
+
+func (h *deprecatedHandler) configure(rt *route) {
+	d := h.d
+	rt.deprecation = &d
+}
+
+func (h *deprecatedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Deprecation", h.deprecation)
+	if h.sunset != "" {
+		header.Set("Sunset", h.sunset)
+	}
+	if h.link != "" {
+		header.Add("Link", h.link)
+	}
+
+	h.next.ServeHTTP(w, r)
+}
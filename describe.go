@@ -0,0 +1,32 @@
+package mux
+
+// Doc documents a route for generated API documentation, such as the OpenAPI
+// documents of the openapi package. It doesn't change how the route is served.
+type Doc struct {
+	// Method limits the Doc to one method of the route. If empty, it applies
+	// to every method.
+	Method string
+
+	Summary     string
+	Description string
+	OperationID string
+	Tags        []string
+
+	// Request is a value of the type of the request body, such as User{}. It's
+	// omitted if nil.
+	Request any
+
+	// Responses maps the status codes of the route to a value of the type of
+	// their body, or nil if the response has no body.
+	Responses map[int]any
+}
+
+// Describe will document the route with the Doc, reported for it by Routes.
+// Used with a Group, such as to tag its routes, it documents every route of
+// the group, and the Docs of a route are reported after those of its groups.
+// It costs nothing per request.
+func Describe(d Doc) Middleware {
+	return option(func(rt *route) {
+		rt.docs = append(rt.docs, d)
+	})
+}
@@ -0,0 +1,112 @@
+package mux
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// processStart is when the process started, as near as the package knows.
+var processStart = time.Now()
+
+// Diagnosis is the report of the Diagnostics handler.
+type Diagnosis struct {
+	Build      BuildInfo    `json:"build"`
+	StartedAt  time.Time    `json:"startedAt"`
+	Uptime     float64      `json:"uptimeSeconds"`
+	Goroutines int          `json:"goroutines"`
+	Memory     MemoryInfo   `json:"memory"`
+	Conns      *ServerConns `json:"connections,omitempty"`
+	Routes     int          `json:"routes"`
+}
+
+// BuildInfo describes the build of the binary, from its debug.BuildInfo.
+type BuildInfo struct {
+	GoVersion string `json:"goVersion"`
+	Path      string `json:"path,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// MemoryInfo is the part of the runtime.MemStats useful on a dashboard.
+type MemoryInfo struct {
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	TotalAlloc   uint64 `json:"totalAlloc"`
+	Sys          uint64 `json:"sys"`
+	NumGC        uint32 `json:"numGC"`
+	PauseTotalNs uint64 `json:"pauseTotalNs"`
+}
+
+// Diagnostics will return a handler responding with the JSON Diagnosis of the
+// process serving the Mux, for fleet dashboards: the module version and VCS
+// revision it was built from, its uptime, goroutines and memory, the number
+// of routes of the Mux, and the connections open on the Server, if it's not
+// nil. Reading the memory stats stops the world briefly, so mount it on an
+// admin Mux, such as that of NewAdminMux, rather than polling it often.
+func Diagnostics(m *Mux, s *Server) http.Handler {
+	if m == nil {
+		panic("mux must not be nil")
+	}
+
+	build := readBuildInfo()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+
+		d := Diagnosis{
+			Build:      build,
+			StartedAt:  processStart,
+			Uptime:     time.Since(processStart).Seconds(),
+			Goroutines: runtime.NumGoroutine(),
+			Memory: MemoryInfo{
+				HeapAlloc:    mem.HeapAlloc,
+				HeapObjects:  mem.HeapObjects,
+				TotalAlloc:   mem.TotalAlloc,
+				Sys:          mem.Sys,
+				NumGC:        mem.NumGC,
+				PauseTotalNs: mem.PauseTotalNs,
+			},
+			Routes: len(m.routes()),
+		}
+
+		if s != nil {
+			conns := s.Connections()
+			d.Conns = &conns
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(d)
+	})
+}
+
+// readBuildInfo will return the BuildInfo of the binary, with only the Go
+// version if it wasn't built with module support.
+func readBuildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Path, info.Version = bi.Main.Path, bi.Main.Version
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.Time = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,224 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// matcher reports whether the request satisfies a constraint of a route. It may
+// record the values it captured in the request's route context, if it has one,
+// or return the request with them added to its context.
+type matcher func(r *http.Request) (*http.Request, bool)
+
+// entry is the handler registered on the http.ServeMux for a pattern. Several
+// routes can share a pattern when they're constrained by matchers, so the
+// entry dispatches to the first whose matchers are all satisfied, trying the
+// route without any last.
+type entry struct {
+	mux     *Mux
+	pattern string
+	table   atomic.Pointer[entryTable]
+}
+
+// entryTable is what an entry dispatches to. It's replaced whenever a route is
+// added to the entry, and never changed once it's in use.
+type entryTable struct {
+	candidates []*candidate
+	fallback   *candidate
+}
+
+type candidate struct {
+	route    *route
+	matchers []matcher
+	handler  http.Handler
+	stripper *stripHandler
+}
+
+// Match will return the route the request would be dispatched to, without
+// invoking its handler or middleware. It ignores maintenance mode and
+// draining, and reports false when the request would be redirected or not
+// found.
+func (m *Mux) Match(r *http.Request) (RouteInfo, bool) {
+	_, pattern := m.mux.Handler(r)
+
+	v, ok := m.entries.Load(pattern)
+	if !ok {
+		return RouteInfo{}, false
+	}
+
+	c, ok := v.(*entry).lookup(r)
+	if !ok {
+		return RouteInfo{}, false
+	}
+
+	return c.route.info(), true
+}
+
+func (e *entry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if e.mux.resolving.Load() > 0 && e.resolve(r) {
+		return
+	}
+
+	// Only the matchers of candidates capture values before the handler is
+	// reached, so only entries with candidates need a route context here.
+	table := e.table.Load()
+	var rc, owned *routeContext
+	if len(table.candidates) > 0 {
+		var ctx context.Context
+		if ctx, rc, owned = withRouteContext(r.Context(), true); owned != nil {
+			r = r.WithContext(ctx)
+		}
+	}
+
+	c, req, ok := table.dispatch(r, rc)
+	if !ok {
+		owned.release()
+		http.NotFound(w, r)
+		return
+	}
+
+	c.handler.ServeHTTP(w, req)
+	owned.release()
+}
+
+// dispatch will return the candidate for the request, and the request as its
+// matchers left it. The host labels captured by candidates that didn't match
+// are discarded.
+func (t *entryTable) dispatch(r *http.Request, rc *routeContext) (*candidate, *http.Request, bool) {
+	var base int
+	if rc != nil {
+		base = len(rc.hostParams)
+	}
+
+	for _, c := range t.candidates {
+		if req, ok := c.match(r); ok {
+			return c, req, true
+		}
+
+		if rc != nil {
+			rc.hostParams = rc.hostParams[:base]
+		}
+	}
+
+	return t.fallback, r, t.fallback != nil
+}
+
+// lookup will return the candidate the entry dispatches the request to.
+func (e *entry) lookup(r *http.Request) (*candidate, bool) {
+	table := e.table.Load()
+	for _, c := range table.candidates {
+		if _, ok := c.match(r); ok {
+			return c, true
+		}
+	}
+
+	return table.fallback, table.fallback != nil
+}
+
+func (c *candidate) match(r *http.Request) (*http.Request, bool) {
+	for _, m := range c.matchers {
+		var ok bool
+		if r, ok = m(r); !ok {
+			return nil, false
+		}
+	}
+
+	return r, true
+}
+
+// add will register the handler of the route, sharing the entry of any route
+// already registered with the same pattern. The entry's table is replaced
+// rather than changed, so requests being dispatched keep a consistent one.
+func (m *Mux) add(rt *route, handler http.Handler, stripper *stripHandler) {
+	c := &candidate{
+		route:    rt,
+		matchers: rt.matchers,
+		handler:  m.track(rt, handler),
+		stripper: stripper,
+	}
+
+	var e *entry
+	old := &entryTable{}
+	if v, ok := m.entries.Load(rt.pattern); ok {
+		e = v.(*entry)
+		old = e.table.Load()
+	}
+
+	table := &entryTable{candidates: old.candidates, fallback: old.fallback}
+	switch {
+	case len(c.matchers) > 0:
+		table.candidates = insertCandidate(old.candidates, c)
+	case old.fallback != nil:
+		m.problem(rt.pattern, "already registered at %s, add a constraint to share the pattern", old.fallback.route.site)
+		return
+	default:
+		table.fallback = c
+	}
+
+	// Checked before the route is registered, so a route that panics is
+	// never served.
+	m.shadowing(rt)
+
+	table.number()
+	if e != nil {
+		e.table.Store(table)
+	} else {
+		// The table is set before the entry is registered, so it's never
+		// served without one.
+		e = &entry{mux: m, pattern: rt.pattern}
+		e.table.Store(table)
+		if !m.register(rt.pattern, e) {
+			return
+		}
+		m.entries.Store(rt.pattern, e)
+	}
+
+	routes := append(m.routes(), rt)
+	m.routeList.Store(&routes)
+
+	for _, fn := range rt.registered {
+		fn()
+	}
+}
+
+// insertCandidate will return a copy of the candidates with the candidate
+// inserted after those of the same or a higher priority, so they're tried by
+// descending priority and then in the order they were registered.
+func insertCandidate(candidates []*candidate, c *candidate) []*candidate {
+	i := len(candidates)
+	for i > 0 && candidates[i-1].route.priority < c.route.priority {
+		i--
+	}
+
+	all := make([]*candidate, 0, len(candidates)+1)
+	all = append(all, candidates[:i]...)
+	all = append(all, c)
+	return append(all, candidates[i:]...)
+}
+
+// number will record the order the routes of the table are tried in, when it
+// has candidates to order.
+func (t *entryTable) number() {
+	if len(t.candidates) == 0 {
+		return
+	}
+
+	for i, c := range t.candidates {
+		c.route.order.Store(int32(i + 1))
+	}
+
+	if t.fallback != nil {
+		t.fallback.route.order.Store(int32(len(t.candidates) + 1))
+	}
+}
+
+// routes will return the routes registered so far. The slice is only ever
+// appended to while registering, so it's safe to read while routes are added.
+func (m *Mux) routes() []*route {
+	if routes := m.routeList.Load(); routes != nil {
+		return *routes
+	}
+
+	return nil
+}
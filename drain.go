@@ -0,0 +1,119 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// track will wrap the handler of the route so the number of requests its
+// pattern is currently serving can be reported by InFlight, and the number of
+// requests the route has served by Routes.
+func (m *Mux) track(rt *route, handler http.Handler) http.Handler {
+	pattern := rt.pattern
+
+	m.inFlightMu.Lock()
+	if m.inFlight == nil {
+		m.inFlight = map[string]*atomic.Int64{}
+	}
+	count, ok := m.inFlight[pattern]
+	if !ok {
+		count = &atomic.Int64{}
+		m.inFlight[pattern] = count
+	}
+	m.inFlightMu.Unlock()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt.hits.Add(1)
+		count.Add(1)
+		defer count.Add(-1)
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// InFlight will return the number of requests currently being served by each
+// registered pattern.
+func (m *Mux) InFlight() map[string]int64 {
+	m.inFlightMu.Lock()
+	defer m.inFlightMu.Unlock()
+
+	inFlight := make(map[string]int64, len(m.inFlight))
+	for pattern, count := range m.inFlight {
+		inFlight[pattern] = count.Load()
+	}
+
+	return inFlight
+}
+
+// Drain will stop the Mux from accepting new work and wait for the requests
+// already being served to finish. Requests arriving after Drain is called are
+// answered with a 503 and "Connection: close" so clients and load balancers
+// move on to another instance. If the context expires before the outstanding
+// requests finish, the context's error is returned. Draining can't be undone.
+// The OnDrain hooks are run when it starts.
+func (m *Mux) Drain(ctx context.Context) error {
+	m.drainMu.Lock()
+	var hooks []func()
+	if !m.draining.Swap(true) {
+		hooks = m.onDrain
+	}
+	m.drainMu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	// Poll like http.Server.Shutdown does, starting small and backing off so a
+	// quick drain returns promptly without spinning on a long one.
+	const maxPollInterval = 500 * time.Millisecond
+	pollInterval := time.Millisecond
+	timer := time.NewTimer(pollInterval)
+	defer timer.Stop()
+
+	for {
+		if m.active.Load() == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			pollInterval *= 2
+			if pollInterval > maxPollInterval {
+				pollInterval = maxPollInterval
+			}
+			timer.Reset(pollInterval)
+		}
+	}
+}
+
+// OnDrain will register a hook that's run when the Mux starts draining, so
+// the long-lived requests it would otherwise wait for, such as event streams,
+// can be ended. Hooks run in the order they were registered, and must not
+// block. A hook registered while the Mux is draining is run right away.
+func (m *Mux) OnDrain(hook func()) {
+	if hook == nil {
+		panic("hook must not be nil")
+	}
+
+	m.drainMu.Lock()
+	draining := m.draining.Load()
+	if !draining {
+		m.onDrain = append(m.onDrain, hook)
+	}
+	m.drainMu.Unlock()
+
+	if draining {
+		hook()
+	}
+}
+
+// serveDraining will respond to a request that arrived while the Mux is
+// draining.
+func serveDraining(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
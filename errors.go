@@ -11,40 +11,115 @@ import (
 // ErrorHandler holds resources for returning errors from handlers. If the writer
 // is nil, it will not write the error to it. You can use the writer to capture
 // a log of errors being returned to the handler. The errFunc uses http.Error if
-// no function is provided.
+// no function is provided. The errHook, if provided, is called with every error
+// along with the status and message sent to the client. The errBody documents
+// the body errFunc writes.
 type ErrorHandler struct {
 	ErrWriter io.Writer
 	ErrFunc   func(w http.ResponseWriter, error string, code int)
+	ErrHook   func(r *http.Request, err error, status int, msg string)
+
+	// ErrBody is a value of the type ErrFunc writes as the body of an error,
+	// for generated API documentation. If nil, errors are documented as plain
+	// text, like http.Error writes them.
+	ErrBody any
 }
 
 // ErrHandlerFunc is the function signature for handlers that return an error.
+// When registered directly on a Mux or Group, or in a Methods gate registered
+// on one, it's handled by the nearest ErrorHandler set with SetErrorHandler.
 type ErrHandlerFunc func(w http.ResponseWriter, r *http.Request) error
 
+// ServeHTTP satisfies the handler interface, handling any returned error with
+// the DefaultErrorHandler.
+func (f ErrHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	DefaultErrorHandler.serve(f, w, r)
+}
+
+// DefaultErrorHandler handles the errors of ErrHandlerFuncs registered where no
+// other ErrorHandler was set. It responds using http.Error and doesn't log.
+var DefaultErrorHandler = &ErrorHandler{}
+
 // Err will accept a handler that can return an error and handle it according to
 // the errFunc provided or http.Error by default.
 func (eh *ErrorHandler) Err(h ErrHandlerFunc) http.Handler {
-	if eh.ErrFunc == nil {
-		eh.ErrFunc = http.Error
+	return &errHandler{eh: eh, h: h}
+}
+
+// errHandler is returned by Err, so the ErrorHandler of a route can be
+// reported by Routes.
+type errHandler struct {
+	eh *ErrorHandler
+	h  ErrHandlerFunc
+}
+
+func (e *errHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.eh.serve(e.h, w, r)
+}
+
+func (eh *ErrorHandler) serve(h ErrHandlerFunc, w http.ResponseWriter, r *http.Request) {
+	if err := h(w, r); err != nil {
+		eh.respond(w, r, err)
 	}
+}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		err := h(w, r)
-		if err == nil {
-			return
-		}
+// respond will write the error to the client, with the status and message it
+// carries if it was returned by Error, and report it to the hook and writer.
+func (eh *ErrorHandler) respond(w http.ResponseWriter, r *http.Request, err error) {
+	errFunc := eh.ErrFunc
+	if errFunc == nil {
+		errFunc = http.Error
+	}
 
-		var e interface{ StatusMsg() (int, string) }
-		if errors.As(err, &e) {
-			status, msg := e.StatusMsg()
-			eh.ErrFunc(w, msg, status)
-		} else {
-			eh.ErrFunc(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		}
+	status, msg := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	var e interface{ StatusMsg() (int, string) }
+	if errors.As(err, &e) {
+		status, msg = e.StatusMsg()
+	}
+	errFunc(w, msg, status)
 
-		if eh.ErrWriter != nil {
-			fmt.Fprint(eh.ErrWriter, err)
+	if eh.ErrHook != nil {
+		eh.ErrHook(r, err, status, msg)
+	}
+
+	if eh.ErrWriter != nil {
+		fmt.Fprint(eh.ErrWriter, err)
+	}
+}
+
+// handler will return the handler that handles the errors of an ErrHandlerFunc
+// with this ErrorHandler, including those registered in a Methods gate. Any
+// other handler is returned as is.
+func (eh *ErrorHandler) handler(h http.Handler) http.Handler {
+	if m, ok := h.(*methodHandler); ok {
+		return m.withErrorHandler(eh)
+	}
+
+	f, ok := h.(ErrHandlerFunc)
+	if !ok || f == nil {
+		return h
+	}
+
+	return eh.Err(f)
+}
+
+// routeErrorHandler will return the ErrorHandler handling the errors of the
+// handler, or of any method of it if it's a Methods gate.
+func routeErrorHandler(h http.Handler) *ErrorHandler {
+	if m, ok := h.(*methodHandler); ok {
+		for _, h := range m.handlers {
+			if e, ok := h.(*errHandler); ok {
+				return e.eh
+			}
 		}
-	})
+		return nil
+	}
+
+	if e, ok := h.(*errHandler); ok {
+		return e.eh
+	}
+
+	return nil
 }
 
 type handlerError struct {
@@ -53,7 +128,8 @@ type handlerError struct {
 	responseMsg string
 }
 
-// StatusMsg will return the http status code and message to return to the client.
+// StatusMsg will return the http status code and message to return to the
+// client.
 func (h *handlerError) StatusMsg() (int, string) {
 	return h.status, h.responseMsg
 }
@@ -0,0 +1,268 @@
+package mux
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// ForceVariantHeader is the header that forces the variants of experiments, for
+// QA, as pairs of experiment and variant names such as "checkout=b, search=a".
+const ForceVariantHeader = "X-Force-Variant"
+
+// Variant is a variant of an Experiment. Requests are assigned to the variants
+// in proportion to their weights.
+type Variant struct {
+	Name   string
+	Weight int
+
+	// Handler serves the requests assigned to the variant, when the
+	// Experiment is used as a handler. It's not needed with Middleware.
+	Handler http.Handler
+}
+
+type experimentOption func(*Experiment)
+
+// Experiment assigns requests to the named variants of an A/B experiment. As a
+// handler it serves them with the Handler of their variant, and as Middleware
+// it only assigns them, for handlers that branch on it. The assigned variant
+// is available from ExperimentVariant, and all of a request's from
+// Experiments, such as for logging.
+//
+// A request is assigned by its ForceVariantHeader if it names a variant of
+// the experiment, then by its cookie with WithExperimentCookie, then by the
+// hash of its key with WithExperimentKey, and otherwise at random.
+type Experiment struct {
+	name     string
+	variants []Variant
+	total    int
+	cookie   string
+	key      KeyFunc
+	force    bool
+}
+
+// NewExperiment will return the Experiment with the variants.
+func NewExperiment(name string, variants []Variant, options ...experimentOption) *Experiment {
+	if len(name) == 0 {
+		panic("experiment name must not be empty")
+	}
+
+	if len(variants) == 0 {
+		panic("experiment must have variants")
+	}
+
+	e := &Experiment{name: name, force: true}
+	seen := map[string]bool{}
+	for _, v := range variants {
+		if len(v.Name) == 0 {
+			panic("variant name must not be empty")
+		}
+
+		if seen[v.Name] {
+			panic(fmt.Sprintf("variant %q already registered", v.Name))
+		}
+		seen[v.Name] = true
+
+		if v.Weight < 1 {
+			panic(fmt.Sprintf("variant %q must have a positive weight", v.Name))
+		}
+		e.total += v.Weight
+	}
+
+	e.variants = variants
+
+	for _, opt := range options {
+		opt(e)
+	}
+
+	return e
+}
+
+// WithExperimentCookie will keep the variant assigned to a client in the
+// cookie for 90 days, so it sees the same one on every request.
+func WithExperimentCookie(name string) experimentOption {
+	if len(name) == 0 {
+		panic("cookie name must not be empty")
+	}
+
+	return func(e *Experiment) {
+		e.cookie = name
+	}
+}
+
+// WithExperimentKey will assign the requests with the same key, such as a user
+// ID, to the same variant. The experiment's name is part of the hash, so a key
+// isn't assigned to the same variant of every experiment.
+func WithExperimentKey(key KeyFunc) experimentOption {
+	if key == nil {
+		panic("key must not be nil")
+	}
+
+	return func(e *Experiment) {
+		e.key = key
+	}
+}
+
+// WithoutForcedVariants will ignore the ForceVariantHeader, such as when the
+// header can't be stripped from untrusted clients.
+func WithoutForcedVariants() experimentOption {
+	return func(e *Experiment) {
+		e.force = false
+	}
+}
+
+// ServeHTTP satisfies the handler interface, serving the request with the
+// Handler of its variant, or a 404 if it has none.
+func (e *Experiment) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v, r := e.assign(w, r)
+	if v.Handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	v.Handler.ServeHTTP(w, r)
+}
+
+// Middleware will assign requests to a variant before passing them on.
+func (e *Experiment) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, r = e.assign(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+type experimentsCtxKey struct{}
+
+// assignment is the variant of an experiment a request was assigned.
+type assignment struct {
+	experiment, variant string
+}
+
+// ExperimentVariant will return the variant of the experiment the request was
+// assigned, or an empty string if it wasn't.
+func ExperimentVariant(r *http.Request, experiment string) string {
+	assigned, _ := r.Context().Value(experimentsCtxKey{}).([]assignment)
+	for i := len(assigned) - 1; i >= 0; i-- {
+		if assigned[i].experiment == experiment {
+			return assigned[i].variant
+		}
+	}
+
+	return ""
+}
+
+// Experiments will return the variants the request was assigned, by the names
+// of their experiments.
+func Experiments(r *http.Request) map[string]string {
+	assigned, _ := r.Context().Value(experimentsCtxKey{}).([]assignment)
+	experiments := make(map[string]string, len(assigned))
+	for _, a := range assigned {
+		experiments[a.experiment] = a.variant
+	}
+
+	return experiments
+}
+
+// assign will return the variant of the request, and the request with it in
+// its context. A variant kept in a cookie is set on the response. The
+// response is marked as varying by the headers the variant was assigned by.
+func (e *Experiment) assign(w http.ResponseWriter, r *http.Request) (Variant, *http.Request) {
+	if e.force {
+		Vary(w.Header(), ForceVariantHeader)
+	}
+
+	if e.cookie != "" {
+		Vary(w.Header(), "Cookie")
+	}
+
+	v, ok := e.forced(r)
+	if !ok && e.cookie != "" {
+		if c, err := r.Cookie(e.cookie); err == nil {
+			v, ok = e.variant(c.Value)
+		}
+
+		if !ok {
+			v, ok = e.pick(r), true
+			http.SetCookie(w, &http.Cookie{
+				Name:     e.cookie,
+				Value:    v.Name,
+				Path:     "/",
+				MaxAge:   90 * 24 * 60 * 60,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+
+	if !ok {
+		v = e.pick(r)
+	}
+
+	// Copy the assignments, the slice of the outer request is shared with
+	// the other requests derived from it.
+	assigned, _ := r.Context().Value(experimentsCtxKey{}).([]assignment)
+	assigned = append(assigned[:len(assigned):len(assigned)], assignment{experiment: e.name, variant: v.Name})
+
+	return v, r.WithContext(context.WithValue(r.Context(), experimentsCtxKey{}, assigned))
+}
+
+// forced will return the variant the request's ForceVariantHeader names for the
+// experiment, if any.
+func (e *Experiment) forced(r *http.Request) (Variant, bool) {
+	if !e.force {
+		return Variant{}, false
+	}
+
+	for _, value := range r.Header.Values(ForceVariantHeader) {
+		for _, pair := range strings.Split(value, ",") {
+			name, variant, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && name == e.name {
+				return e.variant(variant)
+			}
+		}
+	}
+
+	return Variant{}, false
+}
+
+func (e *Experiment) variant(name string) (Variant, bool) {
+	for _, v := range e.variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+
+	return Variant{}, false
+}
+
+// pick will choose the variant of the request by the hash of its key, or at
+// random if it has none.
+func (e *Experiment) pick(r *http.Request) Variant {
+	var key string
+	if e.key != nil {
+		key = e.key(r)
+	}
+
+	var n int
+	if key == "" {
+		n = rand.Intn(e.total)
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(e.name))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		n = int(h.Sum32() % uint32(e.total))
+	}
+
+	for _, v := range e.variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+
+	return e.variants[len(e.variants)-1]
+}
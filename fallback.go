@@ -0,0 +1,63 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+)
+
+// Fallback will return a handler serving requests with the primary, and with
+// the secondary instead when trigger reports the primary failed, so a route
+// can degrade to a cached response or a static stub. The primary's response is
+// buffered until it's known whether it's used, so don't use it for streaming.
+//
+// The trigger is called with the status the primary wrote, 0 if it wrote
+// none, and the error it returned if it's an ErrHandlerFunc, or the error of
+// the request's context if it ended while the primary was serving, such as
+// from a timeout. The secondary is still served if the context ended. Errors
+// of the primary that don't trigger the secondary, when it wrote no response
+// for them, and those of the secondary, are returned to the ErrorHandler.
+func Fallback(primary, secondary http.Handler, trigger func(status int, err error) bool) ErrHandlerFunc {
+	if primary == nil || secondary == nil {
+		panic("handlers must not be nil")
+	}
+
+	if trigger == nil {
+		panic("trigger must not be nil")
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		buf := newBufferedResponse()
+		err := serveErr(primary, buf, r)
+		if err == nil {
+			err = r.Context().Err()
+		}
+
+		if !trigger(buf.status, err) {
+			// Leave an error the primary wrote nothing for to the
+			// ErrorHandler, the response it wrote is already the one
+			// for its error.
+			if err == nil || buf.status != 0 {
+				buf.writeTo(w)
+				return nil
+			}
+			return err
+		}
+
+		if r.Context().Err() != nil {
+			r = r.WithContext(context.WithoutCancel(r.Context()))
+		}
+
+		return serveErr(secondary, w, r)
+	}
+}
+
+// serveErr will serve the request with the handler, returning its error if
+// it's an ErrHandlerFunc.
+func serveErr(h http.Handler, w http.ResponseWriter, r *http.Request) error {
+	if f, ok := h.(ErrHandlerFunc); ok {
+		return f(w, r)
+	}
+
+	h.ServeHTTP(w, r)
+	return nil
+}
@@ -0,0 +1,65 @@
+/*
+Package gcp serves a mux.Mux on Google Cloud: as an HTTP function of the
+Functions Framework, or as a Cloud Run service with a server preset for its
+container contract.
+*/
+package gcp
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/kevinfalting/mux"
+)
+
+// shutdownTimeout is how long a Cloud Run service has to shut down, after
+// receiving SIGTERM and before being killed, less a margin for the hooks.
+const shutdownTimeout = 9 * time.Second
+
+// Function will register the Mux as the HTTP function with the name, for the
+// Functions Framework to serve. Call it from an init function of the
+// function's package. It panics if the Mux has invalid routes, so a broken
+// deployment fails on startup.
+func Function(name string, m *mux.Mux) {
+	if err := m.Validate(); err != nil {
+		panic(err)
+	}
+
+	functions.HTTP(name, m.ServeHTTP)
+}
+
+// NewServer will return a mux.Server following the Cloud Run container
+// contract: it listens on the port from the PORT variable, or 8080, logs its
+// errors in the structured format of Cloud Logging, and when run with
+// Server.Run shuts down gracefully within the 10 seconds allowed after
+// SIGTERM. The options are applied after the preset, so they can override it.
+func NewServer(m *mux.Mux, options ...func(*mux.Server)) *mux.Server {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	srv := &http.Server{
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          NewLogLogger(os.Stderr, SeverityError),
+	}
+
+	return mux.NewServer(":"+port, m,
+		mux.WithHTTPServer(srv),
+		mux.WithShutdownTimeout(shutdownTimeout),
+		func(s *mux.Server) {
+			for _, opt := range options {
+				opt(s)
+			}
+		},
+	)
+}
+
+// Run will serve the Mux as a Cloud Run service with the NewServer preset,
+// until the context is canceled or SIGTERM is received.
+func Run(ctx context.Context, m *mux.Mux, options ...func(*mux.Server)) error {
+	return NewServer(m, options...).Run(ctx)
+}
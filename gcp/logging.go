@@ -0,0 +1,121 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/kevinfalting/mux"
+)
+
+// Severities of Cloud Logging, for NewLogLogger.
+const (
+	SeverityDebug   = "DEBUG"
+	SeverityInfo    = "INFO"
+	SeverityWarning = "WARNING"
+	SeverityError   = "ERROR"
+)
+
+// NewLogger will return a logger writing JSON lines in the structured format
+// Cloud Logging reads from a container's output, with the level as the
+// "severity" and the message as the "message".
+func NewLogger(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+
+			switch a.Key {
+			case slog.LevelKey:
+				return slog.String("severity", severity(a.Value.Any().(slog.Level)))
+			case slog.MessageKey:
+				a.Key = "message"
+			case slog.TimeKey:
+				a.Key = "time"
+			}
+
+			return a
+		},
+	}))
+}
+
+// NewLogLogger will return a log.Logger writing every line as a structured
+// entry of the severity, such as for http.Server.ErrorLog.
+func NewLogLogger(w io.Writer, severity string) *log.Logger {
+	level := slog.LevelInfo
+	switch severity {
+	case SeverityDebug:
+		level = slog.LevelDebug
+	case SeverityWarning:
+		level = slog.LevelWarn
+	case SeverityError:
+		level = slog.LevelError
+	}
+
+	return slog.NewLogLogger(NewLogger(w, level).Handler(), level)
+}
+
+// TraceAttr will return the attribute that correlates a log entry with the
+// trace of the request from the X-Cloud-Trace-Context header, so Cloud Logging
+// groups the entries of a request together. It's empty if the request has no
+// trace.
+func TraceAttr(r *http.Request, projectID string) slog.Attr {
+	header := r.Header.Get("X-Cloud-Trace-Context")
+	trace, _, _ := strings.Cut(header, "/")
+	if trace == "" {
+		return slog.Attr{}
+	}
+
+	return slog.String("logging.googleapis.com/trace", fmt.Sprintf("projects/%s/traces/%s", projectID, trace))
+}
+
+// Trace will return middleware adding the request's TraceAttr to the slog
+// logger in its context, or to the default logger if there is none, see
+// Logger.
+func Trace(projectID string, base *slog.Logger) mux.Middleware {
+	if base == nil {
+		base = slog.Default()
+	}
+
+	return mux.Named("gcp.Trace", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := base
+			if attr := TraceAttr(r, projectID); attr.Key != "" {
+				logger = logger.With(attr)
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerCtxKey{}, logger)))
+		})
+	})
+}
+
+type loggerCtxKey struct{}
+
+// Logger will return the logger Trace added to the request's context, or the
+// default logger.
+func Logger(r *http.Request) *slog.Logger {
+	if logger, ok := r.Context().Value(loggerCtxKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+func severity(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return SeverityError
+	case level >= slog.LevelWarn:
+		return SeverityWarning
+	case level >= slog.LevelInfo:
+		return SeverityInfo
+	}
+
+	return SeverityDebug
+}
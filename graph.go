@@ -0,0 +1,194 @@
+package mux
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// GraphFormat is a format WriteGraph can render the route tree in.
+type GraphFormat int
+
+const (
+	// GraphDOT renders a Graphviz digraph, for the dot command.
+	GraphDOT GraphFormat = iota
+
+	// GraphMermaid renders a Mermaid flowchart, which Markdown viewers such
+	// as GitHub's render in place.
+	GraphMermaid
+)
+
+// graphNode is a path prefix of the route tree, such as "/api/", with the
+// prefixes and routes beneath it.
+type graphNode struct {
+	id       string
+	label    string
+	group    bool
+	children []*graphNode
+	routes   []graphRoute
+}
+
+type graphRoute struct {
+	id    string
+	label string
+	notes string
+}
+
+// WriteGraph will render the tree of the routes of the Mux in the format: a
+// node for each host and each segment of their paths, the prefixes of Groups
+// in bold, and each route beneath its prefix with its methods and middleware.
+// The nodes are sorted by path, so the output is deterministic and suits
+// generating the diagrams of architecture docs.
+func (m *Mux) WriteGraph(w io.Writer, format GraphFormat) error {
+	m.regMu.Lock()
+	groups := make(map[string]bool, len(m.groups))
+	for _, prefix := range m.groups {
+		groups[prefix] = true
+	}
+	m.regMu.Unlock()
+
+	root := m.graph(groups)
+	switch format {
+	case GraphDOT:
+		var b strings.Builder
+		b.WriteString("digraph routes {\n\trankdir=LR;\n\tnode [shape=box];\n")
+		root.walk(func(n *graphNode) {
+			style := ""
+			if n.group {
+				style = ", style=bold"
+			}
+			fmt.Fprintf(&b, "\t%s [label=%s%s];\n", n.id, dotQuote(n.label), style)
+			for _, c := range n.children {
+				fmt.Fprintf(&b, "\t%s -> %s;\n", n.id, c.id)
+			}
+			for _, rt := range n.routes {
+				fmt.Fprintf(&b, "\t%s [label=%s, shape=ellipse];\n\t%s -> %s;\n", rt.id, dotQuote(strings.TrimSuffix(rt.label+"\n"+rt.notes, "\n")), n.id, rt.id)
+			}
+		})
+		b.WriteString("}\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+
+	case GraphMermaid:
+		var b strings.Builder
+		b.WriteString("flowchart LR\n")
+		root.walk(func(n *graphNode) {
+			label := mermaidQuote(n.label)
+			if n.group {
+				label = mermaidQuote("<b>" + n.label + "</b>")
+			}
+			fmt.Fprintf(&b, "\t%s[%s]\n", n.id, label)
+			for _, c := range n.children {
+				fmt.Fprintf(&b, "\t%s --> %s\n", n.id, c.id)
+			}
+			for _, rt := range n.routes {
+				label := rt.label
+				if rt.notes != "" {
+					label += "<br/>" + strings.ReplaceAll(rt.notes, "\n", "<br/>")
+				}
+				fmt.Fprintf(&b, "\t%s([%s])\n\t%s --> %s\n", rt.id, mermaidQuote(label), n.id, rt.id)
+			}
+		})
+		_, err := io.WriteString(w, b.String())
+		return err
+
+	default:
+		return fmt.Errorf("mux: unknown graph format %d", format)
+	}
+}
+
+// graph will build the route tree, rooted at a node of the hosts.
+func (m *Mux) graph(groups map[string]bool) *graphNode {
+	routes := m.Routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		_, pi := splitMethod(routes[i].Pattern)
+		_, pj := splitMethod(routes[j].Pattern)
+		if pi != pj {
+			return pi < pj
+		}
+
+		return routes[i].Pattern < routes[j].Pattern
+	})
+
+	var ids int
+	nextID := func(prefix string) string {
+		ids++
+		return fmt.Sprintf("%s%d", prefix, ids)
+	}
+
+	root := &graphNode{id: nextID("n"), label: "routes"}
+	nodes := map[string]*graphNode{}
+	for _, ri := range routes {
+		_, rest := splitMethod(ri.Pattern)
+		i := strings.Index(rest, "/")
+		if i < 0 {
+			continue
+		}
+		host, path := rest[:i], rest[i:]
+
+		// Each directory of the path, up to its last slash, is a node
+		// beneath that of the host, or of the root for routes of any host.
+		key, parent := host, root
+		if host != "" {
+			if parent = nodes[key]; parent == nil {
+				parent = &graphNode{id: nextID("n"), label: host}
+				nodes[key] = parent
+				root.children = append(root.children, parent)
+			}
+		}
+
+		slash := strings.LastIndex(path, "/")
+		dir, leaf := path[:slash+1], path[slash+1:]
+		prefix := ""
+		for _, seg := range strings.SplitAfter(dir, "/") {
+			if seg == "" {
+				continue
+			}
+
+			prefix += seg
+			n := nodes[key+prefix]
+			if n == nil {
+				n = &graphNode{id: nextID("n"), label: seg, group: groups[prefix]}
+				nodes[key+prefix] = n
+				parent.children = append(parent.children, n)
+			}
+			parent = n
+		}
+
+		methods := strings.Join(ri.Methods, ",")
+		if methods == "" {
+			methods = "*"
+		}
+
+		if leaf == "" {
+			leaf = "/"
+		}
+
+		parent.routes = append(parent.routes, graphRoute{
+			id:    nextID("r"),
+			label: methods + " " + leaf,
+			notes: strings.Join(ri.Middleware, ", "),
+		})
+	}
+
+	return root
+}
+
+// walk will call fn for the node and every node beneath it, depth first.
+func (n *graphNode) walk(fn func(*graphNode)) {
+	fn(n)
+	for _, c := range n.children {
+		c.walk(fn)
+	}
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,202 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group is a set of routes registered on a Mux under a common prefix. Groups
+// can contain groups, their prefixes are concatenated and their middleware is
+// composed from the outermost group inwards. Handlers registered on a Group
+// see the request path with the full prefix stripped, unless registered with
+// KeepPrefix.
+type Group struct {
+	mux    *Mux
+	parent *Group
+	prefix string
+	mw     []Middleware
+	eh     *ErrorHandler
+
+	methodDefaults []methodOption
+
+	// compiled caches the middleware of the mux and every group down to this
+	// one, shared by all of the group's routes. It's rebuilt when the
+	// middleware generation of the mux changes.
+	compiled    []Middleware
+	compiledGen uint64
+}
+
+// Handle will register the provided handler under the group's prefix, wrapped
+// in the provided middleware(s). Middleware is envoked from left to right per
+// request, after any mux and group level middleware. The pattern may start
+// with a method, like "GET /users/{id}", which is kept in front of the prefix.
+func (g *Group) Handle(pattern string, handler http.Handler, mw ...Middleware) {
+	g.mux.regMu.Lock()
+	defer g.mux.regMu.Unlock()
+
+	method, path := splitMethod(pattern)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+		g.mux.problem(joinMethod(method, g.join(path)), "group patterns must begin with a slash, after any method, it's registered with one")
+	}
+
+	if !isNilHandler(handler) {
+		handler = g.prepare(handler)
+	}
+
+	g.mux.handle(joinMethod(method, g.join(path)), handler, g.chain(mw), g.Prefix(), g.errorHandler())
+}
+
+// Use will append middleware to the group's middleware. It only wraps the
+// routes registered on the group afterward, including those of nested groups.
+// Appended middleware is envoked after the group's existing middleware, and
+// before that of any nested group or route.
+func (g *Group) Use(mw ...Middleware) {
+	g.mux.regMu.Lock()
+	defer g.mux.regMu.Unlock()
+
+	g.mw = chain(g.mw, mw)
+	g.mux.mwGen++
+}
+
+// HandleErr will register the provided handler that returns an error under the
+// group's prefix, with its errors handled by the group's ErrorHandler.
+func (g *Group) HandleErr(pattern string, handler ErrHandlerFunc, mw ...Middleware) {
+	g.Handle(pattern, handler, mw...)
+}
+
+// SetErrorHandler will set the ErrorHandler for the ErrHandlerFuncs registered
+// on the group afterward, including those of nested groups that don't set
+// their own. It overrides the ErrorHandler of the mux and any parent group.
+func (g *Group) SetErrorHandler(eh *ErrorHandler) {
+	g.mux.regMu.Lock()
+	defer g.mux.regMu.Unlock()
+
+	g.eh = eh
+}
+
+// MethodDefaults will set options applied to every Methods gate registered on
+// the group afterward, including those of nested groups. They're applied
+// before the defaults of parent groups and the mux, and never replace a
+// method the gate registered itself.
+func (g *Group) MethodDefaults(options ...methodOption) {
+	g.mux.regMu.Lock()
+	defer g.mux.regMu.Unlock()
+
+	g.methodDefaults = append(g.methodDefaults, options...)
+}
+
+// prepare will resolve the handler's ErrorHandler and Methods defaults before
+// it's registered.
+func (g *Group) prepare(h http.Handler) http.Handler {
+	var defaults []methodOption
+	for p := g; p != nil; p = p.parent {
+		defaults = append(defaults, p.methodDefaults...)
+	}
+	defaults = append(defaults, g.mux.methodDefaults...)
+
+	h = withMethodDefaults(h, defaults)
+	return g.errorHandler().handler(h)
+}
+
+// errorHandler will return the nearest ErrorHandler set on the group, its
+// parents, or the mux.
+func (g *Group) errorHandler() *ErrorHandler {
+	for p := g; p != nil; p = p.parent {
+		if p.eh != nil {
+			return p.eh
+		}
+	}
+
+	return g.mux.errorHandler()
+}
+
+// HandleFunc will register the provided handler function under the group's
+// prefix, wrapped in the provided middleware(s).
+func (g *Group) HandleFunc(pattern string, handler http.HandlerFunc, mw ...Middleware) {
+	g.Handle(pattern, handler, mw...)
+}
+
+// Group will register the provided handler under the prefix, relative to this
+// group's prefix, and return the nested Group. It behaves like Mux.Group, and
+// the nested Group inherits this group's middleware.
+func (g *Group) Group(prefix string, h http.Handler, mw ...Middleware) *Group {
+	g.mux.regMu.Lock()
+	defer g.mux.regMu.Unlock()
+
+	child := &Group{
+		mux:    g.mux,
+		parent: g,
+		prefix: prefix,
+		mw:     mw,
+	}
+	child.mount(h)
+
+	return child
+}
+
+// Prefix will return the full prefix of the group, including the prefixes of
+// any parent groups.
+func (g *Group) Prefix() string {
+	if g.parent == nil {
+		return g.prefix
+	}
+
+	return g.parent.strip() + g.prefix
+}
+
+// mount will register the handler for the group's prefix, if there is one.
+func (g *Group) mount(h http.Handler) {
+	prefix := g.Prefix()
+	g.mux.groups = append(g.mux.groups, prefix)
+	if !strings.HasSuffix(prefix, "/") {
+		g.mux.problem(prefix, "group prefix must end with a trailing slash, only the exact path is matched")
+	}
+
+	if h == nil {
+		return
+	}
+
+	if !isNilHandler(h) {
+		h = g.prepare(h)
+	}
+
+	g.mux.handle(prefix, h, g.chain(nil), prefix, g.errorHandler())
+}
+
+// join will return the pattern prefixed with the group's full prefix.
+func (g *Group) join(pattern string) string {
+	return g.strip() + pattern
+}
+
+// strip will return the group's full prefix without the trailing slash, which
+// is removed from the paths its handlers see.
+func (g *Group) strip() string {
+	return strings.TrimSuffix(g.Prefix(), "/")
+}
+
+// chain will return the middleware for a route of the group: the mux level
+// middleware, then that of each group from the outermost inwards, followed by
+// the route's own.
+func (g *Group) chain(mw []Middleware) []Middleware {
+	if g.compiled == nil || g.compiledGen != g.mux.mwGen {
+		var groups [][]Middleware
+		for p := g; p != nil; p = p.parent {
+			groups = append(groups, p.mw)
+		}
+
+		all := [][]Middleware{g.mux.mw}
+		for i := len(groups) - 1; i >= 0; i-- {
+			all = append(all, groups[i])
+		}
+
+		g.compiled = chain(all...)
+		g.compiledGen = g.mux.mwGen
+	}
+
+	if len(mw) == 0 {
+		return g.compiled
+	}
+
+	return chain(g.compiled, mw)
+}
@@ -0,0 +1,63 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Gateway will mount a generated REST gateway, such as a grpc-gateway
+// runtime.ServeMux, under the prefix of the router, and return the Group so
+// other routes can be registered alongside it. Generated gateways route on the
+// full path from their annotations, so the gateway sees the path with the
+// prefix kept, whereas routes registered on the Group have it stripped as
+// usual. The middleware wraps the gateway and every route of the Group.
+func Gateway(r Router, prefix string, gw http.Handler, mw ...Middleware) *Group {
+	if gw == nil {
+		panic("gateway must not be nil")
+	}
+
+	g := r.Group(prefix, nil, mw...)
+	g.Handle("/", gw, KeepPrefix())
+
+	return g
+}
+
+// SplitGRPC will return middleware that sends gRPC requests to the grpc
+// handler, such as a *grpc.Server, and every other request on through the
+// chain, so gRPC and HTTP can be served on the same port. Use it as listener
+// middleware with WithListener or WithTLSListener. gRPC requires HTTP/2, so
+// the listener must use TLS, or the server must accept unencrypted HTTP/2.
+func SplitGRPC(grpc http.Handler) Middleware {
+	if grpc == nil {
+		panic("grpc handler must not be nil")
+	}
+
+	return Named("mux.SplitGRPC", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsGRPC(r) {
+				grpc.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// IsGRPC reports whether the request is a gRPC request: an HTTP/2 request with
+// an "application/grpc" content type, including subtypes such as
+// "application/grpc+proto". gRPC-Web requests are sent over HTTP/1.1 too and
+// need a proxy in front of the gRPC server, so they aren't gRPC requests.
+func IsGRPC(r *http.Request) bool {
+	if r.ProtoMajor != 2 {
+		return false
+	}
+
+	ct := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "application/grpc") {
+		return false
+	}
+
+	rest := ct[len("application/grpc"):]
+	return rest == "" || rest[0] == '+' || rest[0] == ';'
+}
@@ -0,0 +1,295 @@
+package mux
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// GRPCWeb will return a handler translating gRPC-Web requests, including
+// gRPC-Web-Text, into gRPC requests served by the grpc handler, so browser
+// clients can call gRPC services over HTTP/1.1. The grpc handler is either an
+// in-process *grpc.Server, or a Proxy to a gRPC backend with an HTTP/2
+// transport, such as an http2.Transport. The response's trailers are sent in
+// the body as gRPC-Web requires. Requests that aren't gRPC-Web are responded
+// to with a 415 status.
+//
+// gRPC routes on the full method name, so mount it under a Group with the
+// prefix the clients are configured with, which is stripped as usual:
+//
+//	g := m.Group("/rpc/", nil)
+//	g.Handle("POST /", mux.GRPCWeb(srv))
+//
+// Clients served from another origin need CORS middleware allowing the
+// X-Grpc-Web and X-User-Agent request headers, and exposing the Grpc-Status
+// and Grpc-Message response headers.
+func GRPCWeb(grpc http.Handler) http.Handler {
+	if grpc == nil {
+		panic("grpc handler must not be nil")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGRPCWeb(r) {
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+
+		serveGRPCWeb(grpc, w, r)
+	})
+}
+
+// SplitGRPCWeb will return middleware that translates gRPC-Web requests into
+// gRPC requests served by the grpc handler, as by GRPCWeb, and sends every
+// other request on through the chain, such as for clients calling the
+// services at the root of the host alongside other routes.
+func SplitGRPCWeb(grpc http.Handler) Middleware {
+	if grpc == nil {
+		panic("grpc handler must not be nil")
+	}
+
+	return Named("mux.SplitGRPCWeb", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsGRPCWeb(r) {
+				serveGRPCWeb(grpc, w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// IsGRPCWeb reports whether the request is a gRPC-Web request: a POST request
+// with an "application/grpc-web" or "application/grpc-web-text" content type,
+// including subtypes such as "application/grpc-web+proto".
+func IsGRPCWeb(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+
+	_, _, ok := grpcWebContentType(r.Header.Get("Content-Type"))
+	return ok
+}
+
+// grpcWebContentType will return the suffix of the gRPC-Web content type,
+// such as "+proto", and whether it's gRPC-Web-Text.
+func grpcWebContentType(ct string) (suffix string, text, ok bool) {
+	rest, found := strings.CutPrefix(ct, "application/grpc-web")
+	if !found {
+		return "", false, false
+	}
+
+	rest, text = strings.CutPrefix(rest, "-text")
+	if rest != "" && rest[0] != '+' && rest[0] != ';' {
+		return "", false, false
+	}
+
+	return rest, text, true
+}
+
+func serveGRPCWeb(grpc http.Handler, w http.ResponseWriter, r *http.Request) {
+	suffix, text, _ := grpcWebContentType(r.Header.Get("Content-Type"))
+
+	gr := new(http.Request)
+	*gr = *r
+	gr.Proto, gr.ProtoMajor, gr.ProtoMinor = "HTTP/2.0", 2, 0
+	gr.Header = r.Header.Clone()
+	gr.Header.Set("Content-Type", "application/grpc"+suffix)
+	gr.Header.Set("Te", "trailers")
+	gr.Header.Del("Content-Length")
+	gr.Header.Del("X-Grpc-Web")
+	if text {
+		gr.Body = &grpcWebTextReader{r: r.Body}
+		gr.ContentLength = -1
+	}
+
+	gw := &grpcWebWriter{w: w, header: http.Header{}, text: text}
+	gw.out = w
+	if text {
+		gw.enc = base64.NewEncoder(base64.StdEncoding, w)
+		gw.out = gw.enc
+	}
+
+	grpc.ServeHTTP(gw, gr)
+	gw.finish()
+}
+
+// grpcWebWriter translates a gRPC response into a gRPC-Web one, sending its
+// trailers in a frame at the end of the body.
+type grpcWebWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+	text   bool
+	out    io.Writer
+	enc    io.WriteCloser
+
+	wroteHeader bool
+	sent        map[string]bool
+	announced   []string
+}
+
+func (gw *grpcWebWriter) Header() http.Header {
+	return gw.header
+}
+
+func (gw *grpcWebWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+
+	gw.sent = map[string]bool{}
+	h := gw.w.Header()
+	for key, values := range gw.header {
+		switch {
+		case key == "Trailer":
+			for _, v := range values {
+				for _, name := range strings.Split(v, ",") {
+					if name = strings.TrimSpace(name); name != "" {
+						gw.announced = append(gw.announced, http.CanonicalHeaderKey(name))
+					}
+				}
+			}
+		case key == "Content-Length", strings.HasPrefix(key, http.TrailerPrefix):
+		default:
+			h[key] = append([]string(nil), values...)
+			gw.sent[key] = true
+		}
+	}
+
+	ct := "application/grpc-web"
+	if gw.text {
+		ct = "application/grpc-web-text"
+	}
+	if rest, ok := strings.CutPrefix(h.Get("Content-Type"), "application/grpc"); ok {
+		ct += rest
+	}
+	h.Set("Content-Type", ct)
+
+	gw.w.WriteHeader(status)
+}
+
+func (gw *grpcWebWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+
+	return gw.out.Write(b)
+}
+
+// Flush will flush the response, ending the base64 of gRPC-Web-Text with its
+// padding first, which clients decode chunk by chunk.
+func (gw *grpcWebWriter) Flush() {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+
+	if gw.text {
+		_ = gw.enc.Close()
+		gw.enc = base64.NewEncoder(base64.StdEncoding, gw.w)
+		gw.out = gw.enc
+	}
+
+	_ = http.NewResponseController(gw.w).Flush()
+}
+
+func (gw *grpcWebWriter) Unwrap() http.ResponseWriter {
+	return gw.w
+}
+
+// finish will write the trailers of the response in a trailer frame. A
+// response without any, such as a trailers-only response sending the status
+// in its headers, is left as it is.
+func (gw *grpcWebWriter) finish() {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+
+	trailers := http.Header{}
+	for _, key := range gw.announced {
+		if values, ok := gw.header[key]; ok {
+			trailers[key] = values
+		}
+	}
+	for key, values := range gw.header {
+		if name, ok := strings.CutPrefix(key, http.TrailerPrefix); ok {
+			trailers[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+
+	if len(trailers) > 0 {
+		keys := make([]string, 0, len(trailers))
+		for key := range trailers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var b strings.Builder
+		for _, key := range keys {
+			for _, v := range trailers[key] {
+				b.WriteString(strings.ToLower(key) + ": " + v + "\r\n")
+			}
+		}
+
+		frame := make([]byte, 5, 5+b.Len())
+		frame[0] = 0x80
+		binary.BigEndian.PutUint32(frame[1:], uint32(b.Len()))
+		frame = append(frame, b.String()...)
+		_, _ = gw.out.Write(frame)
+	}
+
+	if gw.text {
+		_ = gw.enc.Close()
+	}
+}
+
+// grpcWebTextReader decodes the base64 body of a gRPC-Web-Text request, which
+// clients may send as several chunks, each with its padding.
+type grpcWebTextReader struct {
+	r       io.ReadCloser
+	raw     []byte
+	decoded []byte
+	err     error
+}
+
+func (tr *grpcWebTextReader) Read(p []byte) (int, error) {
+	for len(tr.decoded) == 0 {
+		if tr.err != nil {
+			if tr.err == io.EOF && len(tr.raw) > 0 {
+				return 0, base64.CorruptInputError(0)
+			}
+			return 0, tr.err
+		}
+
+		buf := make([]byte, 4096)
+		n, err := tr.r.Read(buf)
+		tr.raw = append(tr.raw, buf[:n]...)
+		tr.err = err
+
+		// Every quantum of 4 bytes decodes on its own, padded or not.
+		whole := len(tr.raw) - len(tr.raw)%4
+		dst := make([]byte, whole/4*3)
+		var written int
+		for i := 0; i < whole; i += 4 {
+			m, err := base64.StdEncoding.Decode(dst[written:], tr.raw[i:i+4])
+			if err != nil {
+				tr.err = err
+				break
+			}
+			written += m
+		}
+		tr.decoded = dst[:written]
+		tr.raw = append(tr.raw[:0], tr.raw[whole:]...)
+	}
+
+	n := copy(p, tr.decoded)
+	tr.decoded = tr.decoded[n:]
+	return n, nil
+}
+
+func (tr *grpcWebTextReader) Close() error {
+	return tr.r.Close()
+}
@@ -0,0 +1,40 @@
+package mux
+
+import "net/http"
+
+// RequireHeader will constrain the route to requests with the header set to
+// the value, such as RequireHeader("X-GitHub-Event", "push"), so several
+// routes can share a pattern and be told apart by a header. Requests it
+// doesn't match are dispatched to the next route of the pattern, trying the
+// route without any constraint last.
+func RequireHeader(name, value string) Middleware {
+	return RequireHeaderFunc(name, func(v string) bool {
+		return v == value
+	})
+}
+
+// RequireHeaderFunc will constrain the route like RequireHeader, to requests
+// with a value of the header the function reports true for. The function
+// isn't called for requests without the header.
+func RequireHeaderFunc(name string, fn func(value string) bool) Middleware {
+	if len(name) == 0 {
+		panic("header name must not be empty")
+	}
+
+	if fn == nil {
+		panic("header function must not be nil")
+	}
+
+	name = http.CanonicalHeaderKey(name)
+	return option(func(rt *route) {
+		rt.matchers = append(rt.matchers, func(r *http.Request) (*http.Request, bool) {
+			for _, v := range r.Header[name] {
+				if fn(v) {
+					return r, true
+				}
+			}
+
+			return r, false
+		})
+	})
+}
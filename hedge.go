@@ -0,0 +1,148 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// hedgePolicy holds the configuration of the hedged requests of a Proxy.
+type hedgePolicy struct {
+	after     time.Duration
+	upstreams []*url.URL
+	next      atomic.Uint64
+}
+
+// WithHedge will send a second attempt of a GET, HEAD or OPTIONS request
+// without a body if the upstream hasn't responded after the duration, for
+// latency sensitive reads. The first response is used, and the other attempt
+// is canceled. The second attempt is sent to the upstreams in turn, which are
+// sent the same path as the target, or to the target if there are none. With
+// WithRetries, both attempts together count as one try.
+func WithHedge(after time.Duration, upstreams ...*url.URL) proxyOption {
+	if after <= 0 {
+		panic("hedge delay must be positive")
+	}
+
+	for _, u := range upstreams {
+		if u == nil {
+			panic("upstream must not be nil")
+		}
+	}
+
+	return func(p *proxy) {
+		p.hedge = &hedgePolicy{after: after, upstreams: upstreams}
+	}
+}
+
+// transport will return the RoundTripper hedging the requests made with next.
+func (hp *hedgePolicy) transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &hedgeTransport{policy: hp, next: next}
+}
+
+type hedgeTransport struct {
+	policy *hedgePolicy
+	next   http.RoundTripper
+}
+
+type hedgeResult struct {
+	attempt int
+	resp    *http.Response
+	err     error
+}
+
+func (t *hedgeTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !hedgeable(r) {
+		return t.next.RoundTrip(r)
+	}
+
+	// The channel holds both results, so an attempt never blocks once the
+	// other has won.
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	send := func(req *http.Request) {
+		ctx, cancel := context.WithCancel(req.Context())
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.next.RoundTrip(req.WithContext(ctx))
+			results <- hedgeResult{attempt: attempt, resp: resp, err: err}
+		}()
+	}
+
+	send(r)
+	timer := time.NewTimer(t.policy.after)
+	defer timer.Stop()
+
+	hedge := timer.C
+	pending := 1
+	for {
+		select {
+		case <-hedge:
+			hedge = nil
+			send(t.hedgeRequest(r))
+			pending++
+		case res := <-results:
+			pending--
+			if res.err != nil {
+				cancels[res.attempt]()
+				if pending > 0 {
+					continue
+				}
+				return nil, res.err
+			}
+
+			// Cancel the loser, and close its response if it arrives
+			// anyway.
+			for i, cancel := range cancels {
+				if i != res.attempt {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go func() {
+					if loser := <-results; loser.resp != nil {
+						loser.resp.Body.Close()
+					}
+				}()
+			}
+
+			res.resp.Body = &cancelBody{ReadCloser: res.resp.Body, cancel: cancels[res.attempt]}
+			return res.resp, nil
+		}
+	}
+}
+
+// hedgeRequest will return the second attempt of the request, sent to the next
+// of the upstreams.
+func (t *hedgeTransport) hedgeRequest(r *http.Request) *http.Request {
+	hedge := r.Clone(r.Context())
+	upstreams := t.policy.upstreams
+	if len(upstreams) == 0 {
+		return hedge
+	}
+
+	u := upstreams[(t.policy.next.Add(1)-1)%uint64(len(upstreams))]
+	if hedge.Host == r.URL.Host {
+		hedge.Host = u.Host
+	}
+	hedge.URL.Scheme, hedge.URL.Host = u.Scheme, u.Host
+
+	return hedge
+}
+
+// hedgeable reports whether the request is a read that can be sent twice.
+func hedgeable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return r.Body == nil || r.Body == http.NoBody
+	}
+
+	return false
+}
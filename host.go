@@ -0,0 +1,24 @@
+package mux
+
+import "strings"
+
+// ForHost will bind the route to the host, so it only matches requests for
+// that host, such as "admin.example.com". Used with a Group, it binds every
+// route of the group, letting one Mux serve a different tree per host.
+func ForHost(host string) Middleware {
+	if len(host) == 0 || strings.Contains(host, "/") {
+		panic("host must not be empty or contain a slash")
+	}
+
+	return option(func(rt *route) {
+		rt.host = host
+	})
+}
+
+// Host will bind the routes registered on the group afterward to the host,
+// like ForHost. To bind the handler provided to Group itself, pass ForHost as
+// one of its middleware instead.
+func (g *Group) Host(host string) *Group {
+	g.Use(ForHost(host))
+	return g
+}
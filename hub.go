@@ -0,0 +1,333 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSlowClient is the error a HubConn is closed with when it falls behind
+// the messages queued for it.
+var ErrSlowClient = errors.New("mux: slow client")
+
+// ErrHubClosed is the error a HubConn is closed with when its Hub is closed.
+var ErrHubClosed = errors.New("mux: hub closed")
+
+// HubSender is a connection of a Hub, such as a WebSocket, as adapted by the
+// websocket module.
+type HubSender interface {
+	// Send will write the message to the connection, returning once it's
+	// written or the context ends.
+	Send(ctx context.Context, msg []byte) error
+
+	// Close will close the connection for the reason.
+	Close(reason error) error
+}
+
+type hubOption func(*Hub)
+
+// Hub keeps the bookkeeping of real-time connections: the rooms they've
+// joined, broadcasting to them, and a send queue per connection, so one slow
+// client can't hold up the others. A connection whose queue is full, or that
+// takes longer than the write timeout to write a message, is closed with
+// ErrSlowClient.
+type Hub struct {
+	queue   int
+	timeout time.Duration
+	onClose func(c *HubConn, err error)
+
+	mu     sync.Mutex
+	conns  map[*HubConn]struct{}
+	rooms  map[string]map[*HubConn]struct{}
+	closed bool
+}
+
+// HubConn is a connection registered on a Hub.
+type HubConn struct {
+	hub    *Hub
+	sender HubSender
+	queue  chan []byte
+	done   chan struct{}
+	rooms  map[string]struct{}
+
+	once sync.Once
+	err  error
+}
+
+// NewHub will return a Hub queueing 64 messages per connection, and writing
+// each within 10 seconds.
+func NewHub(options ...hubOption) *Hub {
+	h := &Hub{
+		queue:   64,
+		timeout: 10 * time.Second,
+		conns:   map[*HubConn]struct{}{},
+		rooms:   map[string]map[*HubConn]struct{}{},
+	}
+	for _, opt := range options {
+		opt(h)
+	}
+
+	return h
+}
+
+// WithHubQueue will queue n messages per connection, instead of 64, before
+// it's closed for falling behind.
+func WithHubQueue(n int) hubOption {
+	if n < 1 {
+		panic("queue must be at least 1")
+	}
+
+	return func(h *Hub) {
+		h.queue = n
+	}
+}
+
+// WithHubWriteTimeout will close a connection that takes longer than the
+// duration to write a message, instead of 10 seconds.
+func WithHubWriteTimeout(d time.Duration) hubOption {
+	if d <= 0 {
+		panic("write timeout must be positive")
+	}
+
+	return func(h *Hub) {
+		h.timeout = d
+	}
+}
+
+// WithHubOnClose will call the function with every connection once it's
+// closed, with the reason, such as ErrSlowClient, or nil if it was closed by
+// Close.
+func WithHubOnClose(fn func(c *HubConn, err error)) hubOption {
+	return func(h *Hub) {
+		h.onClose = fn
+	}
+}
+
+// Register will add the connection to the Hub, in no room, and start writing
+// the messages queued for it. It returns nil if the Hub is closed, after
+// closing the connection with ErrHubClosed.
+func (h *Hub) Register(sender HubSender) *HubConn {
+	c := &HubConn{
+		hub:    h,
+		sender: sender,
+		queue:  make(chan []byte, h.queue),
+		done:   make(chan struct{}),
+		rooms:  map[string]struct{}{},
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		_ = sender.Close(ErrHubClosed)
+		return nil
+	}
+	h.conns[c] = struct{}{}
+	h.mu.Unlock()
+
+	go c.write()
+	return c
+}
+
+// write will write the messages queued for the connection until it's closed.
+func (c *HubConn) write() {
+	for {
+		select {
+		case msg := <-c.queue:
+			ctx, cancel := context.WithTimeout(context.Background(), c.hub.timeout)
+			err := c.sender.Send(ctx, msg)
+			timedOut := ctx.Err() != nil
+			cancel()
+
+			if err != nil {
+				if timedOut {
+					err = ErrSlowClient
+				}
+				c.close(err)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Broadcast will queue the message for every connection in the room, and
+// return how many it was queued for.
+func (h *Hub) Broadcast(room string, msg []byte) int {
+	h.mu.Lock()
+	members := make([]*HubConn, 0, len(h.rooms[room]))
+	for c := range h.rooms[room] {
+		members = append(members, c)
+	}
+	h.mu.Unlock()
+
+	var n int
+	for _, c := range members {
+		if c.Send(msg) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Rooms will return the rooms with at least one connection, sorted.
+func (h *Hub) Rooms() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rooms := make([]string, 0, len(h.rooms))
+	for room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+
+	return rooms
+}
+
+// Members will return the number of connections in the room.
+func (h *Hub) Members(room string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.rooms[room])
+}
+
+// Conns will return the number of connections registered on the Hub.
+func (h *Hub) Conns() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.conns)
+}
+
+// Close will close every connection with ErrHubClosed, and those registered
+// afterward. Register it with Mux.OnDrain, so the connections don't hold up
+// draining the Mux:
+//
+//	m.OnDrain(hub.Close)
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	conns := make([]*HubConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range conns {
+		c.close(ErrHubClosed)
+	}
+}
+
+// Join will add the connection to the room.
+func (c *HubConn) Join(room string) {
+	h := c.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.conns[c]; !ok {
+		return
+	}
+
+	if h.rooms[room] == nil {
+		h.rooms[room] = map[*HubConn]struct{}{}
+	}
+	h.rooms[room][c] = struct{}{}
+	c.rooms[room] = struct{}{}
+}
+
+// Leave will remove the connection from the room.
+func (c *HubConn) Leave(room string) {
+	h := c.hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.leave(c, room)
+}
+
+// leave will remove the connection from the room, with the Hub locked.
+func (h *Hub) leave(c *HubConn, room string) {
+	delete(c.rooms, room)
+	delete(h.rooms[room], c)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+// Rooms will return the rooms the connection is in, sorted.
+func (c *HubConn) Rooms() []string {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+
+	rooms := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+
+	return rooms
+}
+
+// Send will queue the message for the connection, reporting false if it's
+// closed. A connection whose queue is full is closed with ErrSlowClient.
+func (c *HubConn) Send(msg []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.queue <- msg:
+		return true
+	default:
+		c.close(ErrSlowClient)
+		return false
+	}
+}
+
+// Done will return a channel closed once the connection is closed, such as
+// for its reading loop to stop.
+func (c *HubConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err will return why the connection was closed, or nil if it's open or was
+// closed by Close.
+func (c *HubConn) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close will remove the connection from the Hub and its rooms, and close it.
+// The messages still queued for it are dropped.
+func (c *HubConn) Close() {
+	c.close(nil)
+}
+
+func (c *HubConn) close(err error) {
+	c.once.Do(func() {
+		h := c.hub
+		h.mu.Lock()
+		for room := range c.rooms {
+			h.leave(c, room)
+		}
+		delete(h.conns, c)
+		h.mu.Unlock()
+
+		c.err = err
+		close(c.done)
+		_ = c.sender.Close(err)
+
+		if h.onClose != nil {
+			h.onClose(c, err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+)
+
+type identityCtxKey struct{}
+
+// Identity is who made a request, as established by authentication
+// middleware. Authorization middleware, such as Authorize, read it with
+// IdentityOf.
+type Identity struct {
+	// Subject identifies the user or client, such as a user ID.
+	Subject string
+
+	// Roles are the roles the subject holds, for RequireRole.
+	Roles []string
+
+	// Permissions are the permissions the subject was granted, for
+	// RequirePermission.
+	Permissions []string
+
+	// Scopes are the OAuth scopes the subject's token was granted, for
+	// RequireScope.
+	Scopes []string
+
+	// Claims are any other attributes of the identity, such as those of a
+	// token it was established from.
+	Claims map[string]any
+
+	// Anonymous is set for the identity Anonymous gives a client that isn't
+	// logged in, whose Subject is a random UUID.
+	Anonymous bool
+}
+
+// WithIdentity will return the request with the identity in its context, for
+// authentication middleware to pass on to the handlers after it.
+func WithIdentity(r *http.Request, id Identity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityCtxKey{}, id))
+}
+
+// IdentityOf will return the identity of the request, reporting false if no
+// authentication middleware established one.
+func IdentityOf(r *http.Request) (Identity, bool) {
+	id, ok := r.Context().Value(identityCtxKey{}).(Identity)
+	return id, ok
+}
+
+// HasRole reports whether the identity holds the role.
+func (id Identity) HasRole(role string) bool {
+	return contains(id.Roles, role)
+}
+
+// HasPermission reports whether the identity was granted the permission.
+func (id Identity) HasPermission(permission string) bool {
+	return contains(id.Permissions, permission)
+}
+
+// HasScope reports whether the identity was granted the OAuth scope.
+func (id Identity) HasScope(scope string) bool {
+	return contains(id.Scopes, scope)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package mux
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+)
+
+type internalOption func(*internalPolicy)
+
+// internalPolicy is what requests for internal routes are trusted by.
+type internalPolicy struct {
+	prefixes []netip.Prefix
+	header   string
+	value    string
+	unix     bool
+}
+
+// defaultInternalPolicy trusts only the requests from unix sockets, until
+// SetInternalPolicy is called. Loopback addresses aren't trusted, since every
+// request forwarded by a proxy or sidecar on the same host arrives from one.
+var defaultInternalPolicy = &internalPolicy{unix: true}
+
+// Internal will mark the route internal, such as for metrics or admin
+// endpoints, so it only serves the requests trusted by the internal policy of
+// the mux, as set by SetInternalPolicy. Any other request is answered with a
+// 404 before any of the route's middleware is envoked, as if the route didn't
+// exist. Used with a Group, it marks every route of the group.
+func Internal() Middleware {
+	return option(func(rt *route) {
+		rt.internal = true
+	})
+}
+
+// SetInternalPolicy will set what the requests for Internal routes are trusted
+// by, any one of the options being enough. Until it's set, only the requests
+// arriving on unix sockets are trusted, not even those from loopback
+// addresses, as behind a proxy or sidecar on the same host every request
+// arrives from one. The address of a request is that of the connection it
+// arrived on, never a header like X-Forwarded-For, so behind a proxy trust a
+// header it sets instead.
+func (m *Mux) SetInternalPolicy(options ...internalOption) {
+	p := &internalPolicy{}
+	for _, opt := range options {
+		opt(p)
+	}
+
+	m.internal.Store(p)
+}
+
+// WithTrustedCIDRs will trust the requests from the addresses in the CIDRs,
+// such as "10.0.0.0/8".
+func WithTrustedCIDRs(cidrs ...string) internalOption {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("invalid CIDR %q: %v", cidr, err))
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return func(p *internalPolicy) {
+		p.prefixes = append(p.prefixes, prefixes...)
+	}
+}
+
+// WithTrustedHeader will trust the requests with the header set to the value,
+// such as a secret set by the ingress on the requests it routes from inside
+// the network. The ingress must remove the header from the requests from the
+// public internet.
+func WithTrustedHeader(name, value string) internalOption {
+	if len(name) == 0 || len(value) == 0 {
+		panic("header name and value must not be empty")
+	}
+
+	return func(p *internalPolicy) {
+		p.header, p.value = http.CanonicalHeaderKey(name), value
+	}
+}
+
+// WithTrustedUnixSockets will trust the requests arriving on the unix socket
+// listeners of a Server, such as one added with WithListener("unix", path).
+func WithTrustedUnixSockets() internalOption {
+	return func(p *internalPolicy) {
+		p.unix = true
+	}
+}
+
+// trusts reports whether the request may be served by an internal route.
+func (p *internalPolicy) trusts(r *http.Request) bool {
+	if p.unix {
+		if addr, ok := ListenerAddr(r.Context()); ok && addr.Network() == "unix" {
+			return true
+		}
+	}
+
+	if p.header != "" {
+		for _, v := range r.Header[p.header] {
+			if subtle.ConstantTimeCompare([]byte(v), []byte(p.value)) == 1 {
+				return true
+			}
+		}
+	}
+
+	if len(p.prefixes) > 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		addr, err := netip.ParseAddr(host)
+		if err != nil {
+			return false
+		}
+
+		addr = addr.Unmap()
+		for _, prefix := range p.prefixes {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// guardInternal will return the handler of an internal route, serving only the
+// requests the mux's internal policy trusts.
+func (m *Mux) guardInternal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := m.internal.Load()
+		if p == nil {
+			p = defaultInternalPolicy
+		}
+
+		if !p.trusts(r) {
+			http.NotFound(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
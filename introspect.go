@@ -0,0 +1,292 @@
+package mux
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is the error a request is responded to with when its
+	// bearer token isn't active, as reported by the introspection endpoint.
+	ErrInvalidToken = errors.New("mux: invalid token")
+
+	// ErrIntrospection is the error a request is responded to with when its
+	// bearer token couldn't be introspected.
+	ErrIntrospection = errors.New("mux: token introspection failed")
+)
+
+// maxIntrospectionSize limits the responses read from the introspection
+// endpoint.
+const maxIntrospectionSize = 1 << 20
+
+type introspectionOption func(*Introspection)
+
+// Introspection validates the bearer tokens of requests with an OAuth 2.0
+// token introspection endpoint, as by RFC 7662, for authorization servers
+// issuing opaque reference tokens rather than JWTs. Its Middleware places the
+// Identity of an active token in the request's context, for Authorize.
+//
+// Its Subject is the "sub" of the introspection response, or else its
+// "username" or "client_id", its Scopes are those of its "scope", and its
+// Claims are the whole response.
+type Introspection struct {
+	endpoint string
+	client   *http.Client
+	clientID string
+	secret   string
+	ttl      time.Duration
+	store    CacheStore
+}
+
+// NewIntrospection will return an Introspection posting tokens to the
+// endpoint with the http.DefaultClient. Its responses aren't cached unless
+// WithIntrospectionCache is given.
+func NewIntrospection(endpoint string, options ...introspectionOption) *Introspection {
+	if u, err := url.Parse(endpoint); err != nil || !u.IsAbs() {
+		panic("endpoint must be an absolute URL")
+	}
+
+	in := &Introspection{endpoint: endpoint, client: http.DefaultClient}
+	for _, opt := range options {
+		opt(in)
+	}
+
+	if in.ttl > 0 && in.store == nil {
+		in.store = NewMemoryStore(1000)
+	}
+
+	return in
+}
+
+// WithIntrospectionClient will post to the introspection endpoint with the
+// client, such as one with a timeout or authenticating itself in its
+// transport.
+func WithIntrospectionClient(client *http.Client) introspectionOption {
+	if client == nil {
+		panic("client must not be nil")
+	}
+
+	return func(in *Introspection) {
+		in.client = client
+	}
+}
+
+// WithIntrospectionCredentials will authenticate to the introspection
+// endpoint with HTTP Basic authentication of the client's ID and secret.
+func WithIntrospectionCredentials(clientID, secret string) introspectionOption {
+	if len(clientID) == 0 {
+		panic("client ID must not be empty")
+	}
+
+	return func(in *Introspection) {
+		in.clientID, in.secret = clientID, secret
+	}
+}
+
+// WithIntrospectionCache will keep the introspection responses for the ttl,
+// or until their token expires if that's sooner, so a token isn't introspected
+// on every request. A token revoked meanwhile is accepted until its response
+// expires from the cache, so keep the ttl short.
+func WithIntrospectionCache(ttl time.Duration) introspectionOption {
+	if ttl <= 0 {
+		panic("ttl must be positive")
+	}
+
+	return func(in *Introspection) {
+		in.ttl = ttl
+	}
+}
+
+// WithIntrospectionStore will keep the cached introspection responses in the
+// store, such as one shared by the replicas of a service, instead of a
+// MemoryStore of 1000. Their keys are the hashes of the tokens, prefixed with
+// "introspect:".
+func WithIntrospectionStore(store CacheStore) introspectionOption {
+	if store == nil {
+		panic("store must not be nil")
+	}
+
+	return func(in *Introspection) {
+		in.store = store
+	}
+}
+
+// introspectionResult is the part of an introspection response the
+// Introspection reads itself.
+type introspectionResult struct {
+	Active   bool    `json:"active"`
+	Scope    string  `json:"scope"`
+	Subject  string  `json:"sub"`
+	Username string  `json:"username"`
+	ClientID string  `json:"client_id"`
+	Expires  float64 `json:"exp"`
+}
+
+// Middleware will place the Identity of the request's bearer token in its
+// context. A request without one is passed on without an Identity, for
+// Authorize to reject if the route requires one. A token that isn't active is
+// responded to with a 401 of ErrInvalidToken, and one that couldn't be
+// introspected with a 503 of ErrIntrospection, by the route's ErrorHandler.
+func (in *Introspection) Middleware(next http.Handler) http.Handler {
+	return &introspectionHandler{in: in, next: next, eh: DefaultErrorHandler}
+}
+
+type introspectionHandler struct {
+	in   *Introspection
+	next http.Handler
+	eh   *ErrorHandler
+}
+
+func (h *introspectionHandler) configure(rt *route) {
+	h.eh = rt.errorHandler()
+}
+
+func (h *introspectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	id, err := h.in.Introspect(r.Context(), token)
+	switch {
+	case errors.Is(err, ErrInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		h.eh.respond(w, r, Error(err, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized)))
+		return
+	case err != nil:
+		h.eh.respond(w, r, Error(err, http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable)))
+		return
+	}
+
+	h.next.ServeHTTP(w, WithIdentity(r, id))
+}
+
+// Introspect will return the Identity of the token, or ErrInvalidToken if it
+// isn't active, using the cached response if there is one.
+func (in *Introspection) Introspect(ctx context.Context, token string) (Identity, error) {
+	var key string
+	if in.store != nil {
+		sum := sha256.Sum256([]byte(token))
+		key = "introspect:" + hex.EncodeToString(sum[:])
+		if b, ok, err := in.store.Get(ctx, key); err == nil && ok {
+			return introspectionIdentity(b)
+		}
+	}
+
+	b, err := in.post(ctx, token)
+	if err != nil {
+		return Identity{}, errors.Join(ErrIntrospection, err)
+	}
+
+	id, err := introspectionIdentity(b)
+	if in.store != nil && (err == nil || errors.Is(err, ErrInvalidToken)) {
+		// A store that fails only costs introspecting the token again.
+		_ = in.store.Set(ctx, key, b, in.cacheTTL(id))
+	}
+
+	return id, err
+}
+
+// cacheTTL will return how long to cache the response of the identity for,
+// at most until its token expires.
+func (in *Introspection) cacheTTL(id Identity) time.Duration {
+	ttl := in.ttl
+	if exp, ok := id.Claims["exp"].(float64); ok {
+		ttl = min(ttl, time.Until(time.Unix(int64(exp), 0)))
+	}
+
+	return max(ttl, time.Second)
+}
+
+func (in *Introspection) post(ctx context.Context, token string) ([]byte, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, in.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if in.clientID != "" {
+		// Credentials are form encoded before they're encoded for Basic
+		// authentication, as by RFC 6749.
+		req.SetBasicAuth(url.QueryEscape(in.clientID), url.QueryEscape(in.secret))
+	}
+
+	resp, err := in.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxIntrospectionSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mux: introspection endpoint responded with %d", resp.StatusCode)
+	}
+
+	if !json.Valid(b) {
+		return nil, errors.New("mux: introspection endpoint responded with invalid JSON")
+	}
+
+	return b, nil
+}
+
+// introspectionIdentity will return the Identity of the introspection
+// response, or ErrInvalidToken if its token isn't active or has expired.
+func introspectionIdentity(b []byte) (Identity, error) {
+	var res introspectionResult
+	var claims map[string]any
+	if err := json.Unmarshal(b, &res); err != nil {
+		return Identity{}, errors.Join(ErrIntrospection, err)
+	}
+
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return Identity{}, errors.Join(ErrIntrospection, err)
+	}
+
+	if !res.Active {
+		return Identity{}, ErrInvalidToken
+	}
+
+	// The response may have been cached until just about when its token
+	// expires, so the expiry is checked on every use.
+	if res.Expires > 0 && !time.Now().Before(time.Unix(int64(res.Expires), 0)) {
+		return Identity{}, ErrInvalidToken
+	}
+
+	subject := res.Subject
+	if subject == "" {
+		subject = res.Username
+	}
+	if subject == "" {
+		subject = res.ClientID
+	}
+
+	return Identity{Subject: subject, Scopes: strings.Fields(res.Scope), Claims: claims}, nil
+}
+
+// bearerToken will return the token of the request's bearer Authorization, as
+// by RFC 6750, reporting false if it has none.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
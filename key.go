@@ -0,0 +1,46 @@
+package mux
+
+import "net/http"
+
+// KeyFunc returns a key identifying whose request it is, such as a user or a
+// session, or an empty string if it can't tell.
+type KeyFunc func(r *http.Request) string
+
+// CookieKey will return a KeyFunc using the value of the cookie.
+func CookieKey(name string) KeyFunc {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+
+		return c.Value
+	}
+}
+
+// HeaderKey will return a KeyFunc using the value of the header, such as one
+// set by authentication middleware in front of the service.
+func HeaderKey(name string) KeyFunc {
+	name = http.CanonicalHeaderKey(name)
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// IdentityKey will return a KeyFunc using the Subject of the request's
+// Identity. The subjects of anonymous identities are prefixed with
+// "anonymous:", so they're never mistaken for a user's.
+func IdentityKey() KeyFunc {
+	return func(r *http.Request) string {
+		id, ok := IdentityOf(r)
+		if !ok || id.Subject == "" {
+			return ""
+		}
+
+		if id.Anonymous {
+			return "anonymous:" + id.Subject
+		}
+
+		return id.Subject
+	}
+}
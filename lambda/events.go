@@ -0,0 +1,194 @@
+package lambda
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// v1Event is an API Gateway REST API proxy event.
+type v1Event struct {
+	events.APIGatewayProxyRequest
+}
+
+func (e *v1Event) raw() any {
+	return &e.APIGatewayProxyRequest
+}
+
+func (e *v1Event) request(ctx context.Context) (*http.Request, error) {
+	query := url.Values(e.MultiValueQueryStringParameters)
+	if len(query) == 0 {
+		query = single(e.QueryStringParameters)
+	}
+
+	header := http.Header{}
+	addHeaders(header, e.MultiValueHeaders, e.Headers)
+
+	return newRequest(ctx, e.HTTPMethod, e.Path, query.Encode(), header, e.Body, e.IsBase64Encoded, e.RequestContext.Identity.SourceIP)
+}
+
+func (e *v1Event) response(w *responseWriter) any {
+	body, encoded := w.body()
+	return events.APIGatewayProxyResponse{
+		StatusCode:        w.status,
+		MultiValueHeaders: w.header,
+		Body:              body,
+		IsBase64Encoded:   encoded,
+	}
+}
+
+// v2Event is an API Gateway HTTP API event, payload format version 2.0.
+type v2Event struct {
+	events.APIGatewayV2HTTPRequest
+}
+
+func (e *v2Event) raw() any {
+	return &e.APIGatewayV2HTTPRequest
+}
+
+func (e *v2Event) request(ctx context.Context) (*http.Request, error) {
+	header := http.Header{}
+	for name, value := range e.Headers {
+		// Repeated headers are joined with commas, which is how they're
+		// combined in HTTP anyway.
+		header.Set(name, value)
+	}
+	if len(e.Cookies) > 0 {
+		header.Set("Cookie", strings.Join(e.Cookies, "; "))
+	}
+
+	return newRequest(ctx, e.RequestContext.HTTP.Method, e.RawPath, e.RawQueryString, header, e.Body, e.IsBase64Encoded, e.RequestContext.HTTP.SourceIP)
+}
+
+func (e *v2Event) response(w *responseWriter) any {
+	// Cookies must be returned separately, since Set-Cookie headers can't be
+	// joined with commas.
+	cookies := w.header.Values("Set-Cookie")
+	w.header.Del("Set-Cookie")
+
+	body, encoded := w.body()
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:        w.status,
+		MultiValueHeaders: w.header,
+		Body:              body,
+		IsBase64Encoded:   encoded,
+		Cookies:           cookies,
+	}
+}
+
+// albEvent is an Application Load Balancer target group event.
+type albEvent struct {
+	events.ALBTargetGroupRequest
+}
+
+func (e *albEvent) raw() any {
+	return &e.ALBTargetGroupRequest
+}
+
+func (e *albEvent) request(ctx context.Context) (*http.Request, error) {
+	// The load balancer passes the query parameters as they were sent, still
+	// URL encoded.
+	params := e.MultiValueQueryStringParameters
+	if len(params) == 0 {
+		params = single(e.QueryStringParameters)
+	}
+
+	var query []string
+	for name, values := range params {
+		for _, value := range values {
+			query = append(query, name+"="+value)
+		}
+	}
+
+	header := http.Header{}
+	addHeaders(header, e.MultiValueHeaders, e.Headers)
+
+	// The load balancer appends the client's address to X-Forwarded-For, the
+	// first entry is the original client.
+	client, _, _ := strings.Cut(header.Get("X-Forwarded-For"), ",")
+
+	return newRequest(ctx, e.HTTPMethod, e.Path, strings.Join(query, "&"), header, e.Body, e.IsBase64Encoded, strings.TrimSpace(client))
+}
+
+func (e *albEvent) response(w *responseWriter) any {
+	body, encoded := w.body()
+	resp := events.ALBTargetGroupResponse{
+		StatusCode:        w.status,
+		StatusDescription: strconv.Itoa(w.status) + " " + http.StatusText(w.status),
+		Body:              body,
+		IsBase64Encoded:   encoded,
+	}
+
+	// The load balancer only reads the kind of headers it sent, depending on
+	// whether multi value headers are enabled for the target group.
+	if e.MultiValueHeaders != nil {
+		resp.MultiValueHeaders = w.header
+	} else {
+		resp.Headers = map[string]string{}
+		for name := range w.header {
+			resp.Headers[name] = w.header.Get(name)
+		}
+	}
+
+	return resp
+}
+
+// newRequest will build the request an event describes.
+func newRequest(ctx context.Context, method, path, rawQuery string, header http.Header, body string, base64Encoded bool, remoteAddr string) (*http.Request, error) {
+	data := []byte(body)
+	if base64Encoded {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(body); err != nil {
+			return nil, err
+		}
+	}
+
+	target := path
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+
+	r, err := http.NewRequestWithContext(ctx, method, target, strings.NewReader(string(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header = header
+	r.Host = header.Get("Host")
+	r.RemoteAddr = remoteAddr
+	r.RequestURI = target
+	if len(data) == 0 {
+		r.Body = http.NoBody
+	}
+
+	return r, nil
+}
+
+func addHeaders(header http.Header, multi map[string][]string, headers map[string]string) {
+	if len(multi) > 0 {
+		for name, values := range multi {
+			for _, value := range values {
+				header.Add(name, value)
+			}
+		}
+		return
+	}
+
+	for name, value := range headers {
+		header.Set(name, value)
+	}
+}
+
+func single(params map[string]string) map[string][]string {
+	values := make(map[string][]string, len(params))
+	for name, value := range params {
+		values[name] = []string{value}
+	}
+
+	return values
+}
@@ -0,0 +1,9 @@
+module github.com/kevinfalting/mux/lambda
+
+go 1.22
+
+require github.com/kevinfalting/mux v0.0.0
+
+require github.com/aws/aws-lambda-go v1.54.0
+
+replace github.com/kevinfalting/mux => ../
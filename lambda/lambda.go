@@ -0,0 +1,105 @@
+/*
+Package lambda runs a mux.Mux inside AWS Lambda, translating API Gateway REST
+(v1) and HTTP (v2) API events and Application Load Balancer events into
+http.Requests and the responses back, so the same route tree serves both
+containers and serverless deployments.
+*/
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	awslambda "github.com/aws/aws-lambda-go/lambda"
+	"github.com/kevinfalting/mux"
+)
+
+type eventCtxKey struct{}
+
+// Start will validate the Mux and serve it as the Lambda function's handler.
+// It only returns if the Mux has invalid routes, with the error from Validate.
+func Start(m *mux.Mux) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	awslambda.Start(Handler(m))
+	return nil
+}
+
+// Handler will return a Lambda handler serving the handler. The kind of event
+// is detected from its payload, so one function can sit behind API Gateway
+// and a load balancer at once.
+func Handler(h http.Handler) func(ctx context.Context, payload json.RawMessage) (any, error) {
+	if h == nil {
+		panic("handler must not be nil")
+	}
+
+	return func(ctx context.Context, payload json.RawMessage) (any, error) {
+		e, err := decode(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := e.request(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		w := newResponseWriter()
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), eventCtxKey{}, e.raw())))
+
+		return e.response(w), nil
+	}
+}
+
+// Event will return the event the request was translated from, one of
+// *events.APIGatewayProxyRequest, *events.APIGatewayV2HTTPRequest, or
+// *events.ALBTargetGroupRequest, for the details not carried by the request
+// such as the authorizer's claims.
+func Event(r *http.Request) (any, bool) {
+	e := r.Context().Value(eventCtxKey{})
+	return e, e != nil
+}
+
+// event is an event that can be served as an http.Request.
+type event interface {
+	request(ctx context.Context) (*http.Request, error)
+	response(w *responseWriter) any
+	raw() any
+}
+
+// decode will detect the kind of the event from the fields that only it has.
+func decode(payload json.RawMessage) (event, error) {
+	var probe struct {
+		Version        string `json:"version"`
+		RequestContext struct {
+			ELB  *json.RawMessage `json:"elb"`
+			HTTP *json.RawMessage `json:"http"`
+		} `json:"requestContext"`
+		HTTPMethod string `json:"httpMethod"`
+	}
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, fmt.Errorf("lambda: decoding event: %w", err)
+	}
+
+	var e event
+	switch {
+	case probe.RequestContext.ELB != nil:
+		e = &albEvent{}
+	case probe.Version == "2.0" && probe.RequestContext.HTTP != nil:
+		e = &v2Event{}
+	case probe.HTTPMethod != "":
+		e = &v1Event{}
+	default:
+		return nil, fmt.Errorf("lambda: unsupported event, expected an API Gateway or ALB event")
+	}
+
+	if err := json.Unmarshal(payload, e.raw()); err != nil {
+		return nil, fmt.Errorf("lambda: decoding event: %w", err)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,88 @@
+package lambda
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// responseWriter buffers the response, since Lambda returns it whole.
+type responseWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func newResponseWriter() *responseWriter {
+	return &responseWriter{header: http.Header{}}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.header.Get("Content-Type") == "" {
+		w.header.Set("Content-Type", http.DetectContentType(b))
+	}
+
+	return w.buf.Write(b)
+}
+
+// body will return the response body, base64 encoded unless it's text.
+func (w *responseWriter) body() (string, bool) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if isText(w.header) {
+		return w.buf.String(), false
+	}
+
+	return base64.StdEncoding.EncodeToString(w.buf.Bytes()), true
+}
+
+// isText reports whether the body can be returned as is, rather than base64
+// encoded. Compressed bodies are binary whatever their type.
+func isText(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	ct := header.Get("Content-Type")
+	if ct == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-www-form-urlencoded":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// HandleLazy will register the handler built by the provided function on the
+// mux, like Handle, but only build it when the route is first requested. Use
+// it for rarely requested routes that are costly to construct, such as ones
+// parsing templates or setting up clients, so they don't slow the startup.
+// Requests arriving while it's being built wait for it, and it's built only
+// once. If building panics, the request fails and the next one tries again.
+// The ErrorHandler and Methods defaults of the mux are those at registration.
+func (m *Mux) HandleLazy(pattern string, build func() http.Handler, mw ...Middleware) {
+	if build == nil {
+		m.problem(pattern, "handler must not be nil")
+		return
+	}
+
+	m.regMu.Lock()
+	defaults, eh := m.methodDefaults, m.errorHandler()
+	m.regMu.Unlock()
+
+	m.Handle(pattern, &lazyHandler{
+		pattern: pattern,
+		build: func() http.Handler {
+			return eh.handler(withMethodDefaults(build(), defaults))
+		},
+	}, mw...)
+}
+
+// lazyHandler is the handler registered by HandleLazy.
+type lazyHandler struct {
+	pattern string
+	build   func() http.Handler
+
+	mu      sync.Mutex
+	handler atomic.Pointer[http.Handler]
+}
+
+// ServeHTTP satisfies the handler interface.
+func (l *lazyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := l.handler.Load()
+	if h == nil {
+		h = l.load()
+	}
+
+	(*h).ServeHTTP(w, r)
+}
+
+// load will build the handler, unless a request that held the lock before
+// this one already did.
+func (l *lazyHandler) load() *http.Handler {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if h := l.handler.Load(); h != nil {
+		return h
+	}
+
+	h := l.build()
+	if isNilHandler(h) {
+		panic(fmt.Sprintf("lazy handler for %q must not be nil", l.pattern))
+	}
+
+	l.handler.Store(&h)
+	return &h
+}
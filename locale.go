@@ -0,0 +1,185 @@
+package mux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type localeCtxKey struct{}
+
+type localeOption func(*locales)
+
+type locales struct {
+	names    []string
+	fallback string
+	cookie   string
+}
+
+// Localized will register the handler under a prefix of each locale on the
+// router, so {"en", "de"} serves both /en/ and /de/ with the same handler,
+// the locale stripped from the path it sees and available from Locale.
+// Requests for paths without a locale are redirected to the path under the
+// locale of the cookie named "locale", if it's one of them, or else to the
+// one the Accept-Language header prefers, or else to the first locale.
+// Localized registers a catch-all "/" route on the router for this, so use it
+// on a Group when the router has other routes at its root.
+func Localized(r Router, names []string, h http.Handler, options ...localeOption) {
+	if len(names) == 0 {
+		panic("locales must not be empty")
+	}
+
+	if h == nil {
+		panic("handler must not be nil")
+	}
+
+	l := &locales{fallback: names[0], cookie: "locale"}
+	for _, name := range names {
+		if len(name) == 0 || strings.Contains(name, "/") {
+			panic(fmt.Sprintf("invalid locale %q", name))
+		}
+
+		l.names = append(l.names, name)
+	}
+
+	for _, opt := range options {
+		opt(l)
+	}
+
+	for _, name := range l.names {
+		r.Group("/"+name+"/", withLocale(name, h))
+	}
+
+	r.Handle("/", http.HandlerFunc(l.redirect))
+}
+
+// WithLocaleCookie will read the locale that overrides the Accept-Language
+// header from the cookie of the name, such as one set by a language picker.
+func WithLocaleCookie(name string) localeOption {
+	if len(name) == 0 {
+		panic("cookie name must not be empty")
+	}
+
+	return func(l *locales) {
+		l.cookie = name
+	}
+}
+
+// WithDefaultLocale will redirect the requests that don't accept any of the
+// locales to the locale, instead of the first.
+func WithDefaultLocale(locale string) localeOption {
+	return func(l *locales) {
+		if !contains(l.names, locale) {
+			panic(fmt.Sprintf("default locale %q is not one of the locales", locale))
+		}
+
+		l.fallback = locale
+	}
+}
+
+// Locale will return the locale of the request's path, as registered by
+// Localized, or an empty string if it wasn't served by Localized.
+func Locale(r *http.Request) string {
+	locale, _ := r.Context().Value(localeCtxKey{}).(string)
+	return locale
+}
+
+func withLocale(locale string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), localeCtxKey{}, locale)))
+	})
+}
+
+// redirect will redirect the request to its path under the negotiated locale,
+// after the prefix of the Group it was registered on, if any.
+func (l *locales) redirect(w http.ResponseWriter, r *http.Request) {
+	Vary(w.Header(), "Accept-Language", "Cookie")
+
+	prefix := strings.TrimSuffix(OriginalPath(r), r.URL.Path)
+	target := prefix + "/" + l.negotiate(r) + r.URL.Path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// negotiate will return the locale of the request's cookie, or the one its
+// Accept-Language header prefers, or the default locale. A language range
+// matches a locale exactly, or else by its primary language, so "de-CH"
+// matches "de".
+func (l *locales) negotiate(r *http.Request) string {
+	if c, err := r.Cookie(l.cookie); err == nil {
+		for _, name := range l.names {
+			if strings.EqualFold(name, c.Value) {
+				return name
+			}
+		}
+	}
+
+	for _, tag := range acceptedLanguages(r.Header.Values("Accept-Language")) {
+		for _, name := range l.names {
+			if strings.EqualFold(name, tag) {
+				return name
+			}
+		}
+
+		primary, _, _ := strings.Cut(tag, "-")
+		for _, name := range l.names {
+			if p, _, _ := strings.Cut(name, "-"); strings.EqualFold(p, primary) {
+				return name
+			}
+		}
+	}
+
+	return l.fallback
+}
+
+// acceptedLanguages will return the language ranges of the Accept-Language
+// header values ordered by their quality, those of the same quality in the
+// order they're listed. Ranges with a quality of zero, and "*", are left out.
+func acceptedLanguages(accept []string) []string {
+	type accepted struct {
+		tag string
+		q   float64
+	}
+
+	var all []accepted
+	for _, value := range accept {
+		for _, part := range strings.Split(value, ",") {
+			tag, params, _ := strings.Cut(part, ";")
+			tag = strings.TrimSpace(tag)
+			if tag == "" || tag == "*" {
+				continue
+			}
+
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(key, "q") {
+					if v, err := strconv.ParseFloat(value, 64); err == nil {
+						q = v
+					}
+				}
+			}
+
+			if q > 0 {
+				all = append(all, accepted{tag: tag, q: q})
+			}
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].q > all[j].q
+	})
+
+	tags := make([]string, len(all))
+	for i, a := range all {
+		tags[i] = a.tag
+	}
+
+	return tags
+}
@@ -0,0 +1,442 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrLockedOut is the error a Lockout rejects attempts with while their
+// identifier is locked.
+var ErrLockedOut = errors.New("mux: locked out")
+
+// AttemptStore counts the failed attempts of identifiers for a Lockout, and
+// keeps their locks. Implementations must be safe for concurrent use, and
+// MemoryAttemptStore keeps them in memory.
+type AttemptStore interface {
+	// Fail will count a failed attempt of the key, returning its failures.
+	// They're kept for the window after the last one.
+	Fail(ctx context.Context, key string, window time.Duration) (int, error)
+
+	// Lock will lock the key until the time, keeping its failures for the
+	// window after it, so the delay keeps growing if it fails again.
+	Lock(ctx context.Context, key string, until time.Time, window time.Duration) error
+
+	// Locked will return when the lock of the key ends, or the zero time if
+	// it isn't locked.
+	Locked(ctx context.Context, key string) (time.Time, error)
+
+	// Reset will clear the failures and lock of the key.
+	Reset(ctx context.Context, key string) error
+}
+
+// LockoutEventKind is what happened to an attempt.
+type LockoutEventKind int
+
+const (
+	// LockoutFailed is a failed attempt that didn't lock its identifier.
+	LockoutFailed LockoutEventKind = iota
+
+	// LockoutLocked is a failed attempt that locked its identifier.
+	LockoutLocked
+
+	// LockoutRejected is an attempt rejected because its identifier was
+	// locked, or had another attempt being served.
+	LockoutRejected
+
+	// LockoutSucceeded is a successful attempt, which clears the failures
+	// of its identifier.
+	LockoutSucceeded
+)
+
+// LockoutEvent is given to the hook of a Lockout, such as to emit security
+// events.
+type LockoutEvent struct {
+	Kind LockoutEventKind
+
+	// Key is the identifier of the attempt, such as the username.
+	Key string
+
+	// Failures are the failures counted within the window, for failed
+	// attempts.
+	Failures int
+
+	// Until is when the lock ends, for locked and rejected attempts.
+	Until time.Time
+}
+
+type lockoutOption func(*Lockout)
+
+// lockoutStoreTimeout bounds the calls to the AttemptStore made after the
+// response is written, which no longer have the request to be canceled with.
+const lockoutStoreTimeout = 5 * time.Second
+
+// Lockout limits the failed attempts a credential endpoint, such as a login
+// form, allows per identifier, such as the username. Once an identifier fails
+// more than the threshold, each within the window of the last, it's locked for
+// a delay that doubles with every further failure, and its attempts are
+// rejected with ErrLockedOut as a 429 with a Retry-After header by the route's
+// ErrorHandler. A successful attempt clears its failures.
+//
+// Only one attempt of an identifier is served at a time by each instance, and
+// those arriving meanwhile are rejected the same way, so a burst of concurrent
+// guesses can't all be served before their failures are counted.
+//
+// An attempt fails when the handler responds with a 401 or 403, unless
+// WithLockoutFailure decides otherwise, and succeeds with any other status
+// below 400.
+type Lockout struct {
+	key       KeyFunc
+	store     AttemptStore
+	threshold int
+	window    time.Duration
+	base      time.Duration
+	max       time.Duration
+	failed    func(status int) bool
+	hook      func(r *http.Request, e LockoutEvent)
+
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+}
+
+// NewLockout will return a Lockout of the identifiers the key returns, such as
+// the username of a login form, allowing 5 failures, each within 15 minutes of
+// the last, before locking for a minute, doubling up to an hour. Attempts
+// without a key aren't counted.
+func NewLockout(key KeyFunc, options ...lockoutOption) *Lockout {
+	if key == nil {
+		panic("key must not be nil")
+	}
+
+	l := &Lockout{
+		key:       key,
+		threshold: 5,
+		window:    15 * time.Minute,
+		base:      time.Minute,
+		max:       time.Hour,
+		failed: func(status int) bool {
+			return status == http.StatusUnauthorized || status == http.StatusForbidden
+		},
+		inFlight: map[string]struct{}{},
+	}
+	for _, opt := range options {
+		opt(l)
+	}
+
+	if l.store == nil {
+		l.store = NewMemoryAttemptStore()
+	}
+
+	return l
+}
+
+// WithLockoutThreshold will allow n failures within the window before locking
+// an identifier, instead of 5.
+func WithLockoutThreshold(n int) lockoutOption {
+	if n < 1 {
+		panic("threshold must be at least 1")
+	}
+
+	return func(l *Lockout) {
+		l.threshold = n
+	}
+}
+
+// WithLockoutWindow will keep counting the failures of an identifier until the
+// duration has passed without one, or since its lock ended, instead of 15
+// minutes.
+func WithLockoutWindow(d time.Duration) lockoutOption {
+	if d <= 0 {
+		panic("window must be positive")
+	}
+
+	return func(l *Lockout) {
+		l.window = d
+	}
+}
+
+// WithLockoutBackoff will lock an identifier for base after the failure that
+// exceeds the threshold, doubling with every further failure up to max,
+// instead of a minute up to an hour.
+func WithLockoutBackoff(base, max time.Duration) lockoutOption {
+	if base <= 0 || max < base {
+		panic("backoff must be positive, and max at least base")
+	}
+
+	return func(l *Lockout) {
+		l.base, l.max = base, max
+	}
+}
+
+// WithAttemptStore will keep the failures and locks in the store, such as one
+// shared by the replicas of a service, instead of in memory.
+func WithAttemptStore(store AttemptStore) lockoutOption {
+	if store == nil {
+		panic("store must not be nil")
+	}
+
+	return func(l *Lockout) {
+		l.store = store
+	}
+}
+
+// WithLockoutFailure will decide whether an attempt failed by the status of
+// its response.
+func WithLockoutFailure(failed func(status int) bool) lockoutOption {
+	if failed == nil {
+		panic("failure func must not be nil")
+	}
+
+	return func(l *Lockout) {
+		l.failed = failed
+	}
+}
+
+// WithLockoutHook will call the hook with every attempt that fails, locks,
+// is rejected, or succeeds.
+func WithLockoutHook(hook func(r *http.Request, e LockoutEvent)) lockoutOption {
+	if hook == nil {
+		panic("hook must not be nil")
+	}
+
+	return func(l *Lockout) {
+		l.hook = hook
+	}
+}
+
+// Middleware will count the attempts of the routes it wraps.
+func (l *Lockout) Middleware(next http.Handler) http.Handler {
+	return &lockoutHandler{lockout: l, next: next, eh: DefaultErrorHandler}
+}
+
+type lockoutHandler struct {
+	lockout *Lockout
+	next    http.Handler
+	eh      *ErrorHandler
+}
+
+func (h *lockoutHandler) configure(rt *route) {
+	h.eh = rt.errorHandler()
+}
+
+func (h *lockoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l := h.lockout
+	key := l.key(r)
+	if key == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	until, err := l.store.Locked(ctx, key)
+	if err != nil {
+		h.eh.respond(w, r, Error(err, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+
+	if wait := time.Until(until); wait > 0 {
+		h.reject(w, r, key, until)
+		return
+	}
+
+	if !l.acquire(key) {
+		h.reject(w, r, key, time.Now().Add(time.Second))
+		return
+	}
+	defer l.release(key)
+
+	rw := WrapResponseWriter(w)
+	defer ReleaseResponseWriter(rw)
+	h.next.ServeHTTP(rw, r)
+
+	status := rw.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// The response is already written, so a failure of the store can't be
+	// responded to and the attempt goes uncounted. The attempt is counted even
+	// if the client is already gone, which is when guesses are cheapest.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockoutStoreTimeout)
+	defer cancel()
+
+	switch {
+	case l.failed(status):
+		l.fail(ctx, r, key)
+	case status < http.StatusBadRequest:
+		if l.store.Reset(ctx, key) == nil {
+			l.emit(r, LockoutEvent{Kind: LockoutSucceeded, Key: key})
+		}
+	}
+}
+
+// reject will respond to the attempt with ErrLockedOut, to be retried once the
+// time has come.
+func (h *lockoutHandler) reject(w http.ResponseWriter, r *http.Request, key string, until time.Time) {
+	h.lockout.emit(r, LockoutEvent{Kind: LockoutRejected, Key: key, Until: until})
+	wait := time.Until(until)
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	h.eh.respond(w, r, Error(ErrLockedOut, http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests)))
+}
+
+// acquire will mark an attempt of the key as being served, reporting false if
+// one already is.
+func (l *Lockout) acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.inFlight[key]; ok {
+		return false
+	}
+	l.inFlight[key] = struct{}{}
+
+	return true
+}
+
+func (l *Lockout) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.inFlight, key)
+}
+
+// fail will count the failure of the key, locking it once it's over the
+// threshold.
+func (l *Lockout) fail(ctx context.Context, r *http.Request, key string) {
+	failures, err := l.store.Fail(ctx, key, l.window)
+	if err != nil {
+		return
+	}
+
+	over := failures - l.threshold
+	if over <= 0 {
+		l.emit(r, LockoutEvent{Kind: LockoutFailed, Key: key, Failures: failures})
+		return
+	}
+
+	delay := l.max
+	if over <= 32 && l.base<<(over-1) < l.max && l.base<<(over-1) > 0 {
+		delay = l.base << (over - 1)
+	}
+
+	until := time.Now().Add(delay)
+	if l.store.Lock(ctx, key, until, l.window) == nil {
+		l.emit(r, LockoutEvent{Kind: LockoutLocked, Key: key, Failures: failures, Until: until})
+	}
+}
+
+func (l *Lockout) emit(r *http.Request, e LockoutEvent) {
+	if l.hook != nil {
+		l.hook(r, e)
+	}
+}
+
+// MemoryAttemptStore is an AttemptStore keeping failures and locks in memory,
+// for a single instance or for tests. The keys that expired are swept as
+// failures are counted, at most once a minute.
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*memoryAttempts
+	swept    time.Time
+}
+
+type memoryAttempts struct {
+	failures int
+	expires  time.Time
+	locked   time.Time
+}
+
+// NewMemoryAttemptStore will return an empty MemoryAttemptStore.
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: map[string]*memoryAttempts{}}
+}
+
+// Fail satisfies the AttemptStore interface.
+func (s *MemoryAttemptStore) Fail(_ context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	a, ok := s.attempts[key]
+	if !ok {
+		a = &memoryAttempts{}
+		s.attempts[key] = a
+	}
+
+	if !now.Before(a.expires) {
+		a.failures = 0
+	}
+	a.failures++
+	if expires := now.Add(window); expires.After(a.expires) {
+		a.expires = expires
+	}
+
+	return a.failures, nil
+}
+
+// Lock satisfies the AttemptStore interface.
+func (s *MemoryAttemptStore) Lock(_ context.Context, key string, until time.Time, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok {
+		a = &memoryAttempts{}
+		s.attempts[key] = a
+	}
+	a.locked = until
+	if expires := until.Add(window); expires.After(a.expires) {
+		a.expires = expires
+	}
+
+	return nil
+}
+
+// Locked satisfies the AttemptStore interface.
+func (s *MemoryAttemptStore) Locked(_ context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	if !time.Now().Before(a.expires) {
+		// The failures are kept longer than the lock, so neither is
+		// current and the key is forgotten.
+		delete(s.attempts, key)
+		return time.Time{}, nil
+	}
+
+	return a.locked, nil
+}
+
+// Reset satisfies the AttemptStore interface.
+func (s *MemoryAttemptStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// sweep will forget the keys that expired, unless they were swept less than a
+// minute ago, so keys that are never requested again don't pile up.
+func (s *MemoryAttemptStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+
+	for key, a := range s.attempts {
+		if !now.Before(a.expires) {
+			delete(s.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package mux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maintenance holds the state of an enabled maintenance mode.
+type maintenance struct {
+	allowlist []string
+	handler   http.Handler
+}
+
+// allowed reports whether the path may bypass maintenance mode. Allowlist
+// entries ending in a slash match any path with that prefix, all others must
+// match the path exactly.
+func (mt *maintenance) allowed(path string) bool {
+	for _, allow := range mt.allowlist {
+		if strings.HasSuffix(allow, "/") && strings.HasPrefix(path, allow) {
+			return true
+		}
+
+		if path == allow {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetMaintenance will enable or disable maintenance mode. While enabled, every
+// request whose path is not in the allowlist is answered by the maintenance
+// handler instead of the registered routes. Allowlist entries ending in a
+// slash match the whole subtree, like the patterns of the http.ServeMux. It is
+// safe to call while the Mux is serving requests.
+func (m *Mux) SetMaintenance(enabled bool, allowlist ...string) {
+	m.maintenanceMu.Lock()
+	defer m.maintenanceMu.Unlock()
+
+	if !enabled {
+		m.maintenance.Store(nil)
+		return
+	}
+
+	m.maintenance.Store(&maintenance{
+		allowlist: allowlist,
+		handler:   m.maintenanceHandler(),
+	})
+}
+
+// SetMaintenanceHandler will set the handler used to respond to requests while
+// maintenance mode is enabled. If it's never set, or set to nil, the response
+// from DefaultMaintenancePage is used.
+func (m *Mux) SetMaintenanceHandler(h http.Handler) {
+	m.maintenanceMu.Lock()
+	defer m.maintenanceMu.Unlock()
+
+	m.maintenanceHdlr = h
+	if mt := m.maintenance.Load(); mt != nil {
+		m.maintenance.Store(&maintenance{
+			allowlist: mt.allowlist,
+			handler:   m.maintenanceHandler(),
+		})
+	}
+}
+
+func (m *Mux) maintenanceHandler() http.Handler {
+	if m.maintenanceHdlr == nil {
+		return DefaultMaintenancePage
+	}
+
+	return m.maintenanceHdlr
+}
+
+// DefaultMaintenancePage is the plain text response used during maintenance
+// mode when no other handler has been set.
+var DefaultMaintenancePage = MaintenancePage(time.Minute, "text/plain; charset=utf-8", []byte(http.StatusText(http.StatusServiceUnavailable)+"\n"))
+
+// MaintenancePage will return a handler that responds with a 503, the provided
+// body and content type, and a Retry-After header with the number of seconds
+// in retryAfter. A retryAfter less than one second omits the header. Use it to
+// serve an HTML page or a JSON document during maintenance.
+func MaintenancePage(retryAfter time.Duration, contentType string, body []byte) http.Handler {
+	seconds := int64(retryAfter / time.Second)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if seconds > 0 {
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if r.Method != http.MethodHead {
+			w.Write(body)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Manifest describes routes to register from a configuration file rather than
+// in code, by the names of their handlers and middleware in a Registry. Decode
+// it from JSON with ParseManifest, or from YAML with a YAML package into the
+// same struct, its fields being tagged for both.
+type Manifest struct {
+	Routes []ManifestRoute `json:"routes" yaml:"routes"`
+}
+
+// ManifestRoute describes a route of a Manifest.
+type ManifestRoute struct {
+	// Pattern is the pattern the route is registered with, as for Handle.
+	Pattern string `json:"pattern" yaml:"pattern"`
+
+	// Methods gate the handler by method, as by Methods, if the pattern has
+	// none of its own.
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+
+	// Handler is the name of the route's handler in the Registry.
+	Handler string `json:"handler" yaml:"handler"`
+
+	// Middleware are the names of the route's middleware in the Registry,
+	// outermost first.
+	Middleware []string `json:"middleware,omitempty" yaml:"middleware,omitempty"`
+
+	// Host binds the route to the host, as by ForHost.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+
+	// Priority is the priority of the route, as by Priority.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+
+	// Internal marks the route Internal.
+	Internal bool `json:"internal,omitempty" yaml:"internal,omitempty"`
+
+	// Deprecated marks the route as Deprecated.
+	Deprecated *Deprecation `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+
+	// Summary, Description, OperationID and Tags document the route, as by
+	// Describe.
+	Summary     string   `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	OperationID string   `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Tags        []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// ParseManifest will decode the JSON Manifest, rejecting fields it doesn't
+// know so a misspelled one isn't silently ignored.
+func ParseManifest(r io.Reader) (Manifest, error) {
+	var m Manifest
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return Manifest{}, fmt.Errorf("mux: parsing manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// Registry holds the handlers and middleware a Manifest refers to by name.
+type Registry struct {
+	handlers   map[string]http.Handler
+	middleware map[string]Middleware
+}
+
+// NewRegistry will return an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{handlers: map[string]http.Handler{}, middleware: map[string]Middleware{}}
+}
+
+// Handler will register the handler by the name.
+func (reg *Registry) Handler(name string, h http.Handler) {
+	if len(name) == 0 {
+		panic("handler name must not be empty")
+	}
+
+	if h == nil {
+		panic("handler must not be nil")
+	}
+
+	if _, ok := reg.handlers[name]; ok {
+		panic(fmt.Sprintf("handler %q already registered", name))
+	}
+
+	reg.handlers[name] = h
+}
+
+// Middleware will register the middleware by the name, reported by Routes
+// with it.
+func (reg *Registry) Middleware(name string, mw Middleware) {
+	if len(name) == 0 {
+		panic("middleware name must not be empty")
+	}
+
+	if mw == nil {
+		panic("middleware must not be nil")
+	}
+
+	if _, ok := reg.middleware[name]; ok {
+		panic(fmt.Sprintf("middleware %q already registered", name))
+	}
+
+	reg.middleware[name] = Named(name, mw)
+}
+
+// Load will register the routes of the manifest on the router. Every route is
+// checked before any is registered, so a manifest naming an unknown handler or
+// middleware registers nothing and a ValidationError of all its routes is
+// returned. The routes are registered like those in code, so check Validate
+// for the registrations rejected.
+//
+// A Mux can't unregister routes, so to change the routing while the service
+// is running, load the changed manifest on a new Mux and switch to serving it.
+func (reg *Registry) Load(r Router, m Manifest) error {
+	type registration struct {
+		pattern string
+		handler http.Handler
+		mw      []Middleware
+	}
+
+	var errs []*RouteError
+	registrations := make([]registration, 0, len(m.Routes))
+	for _, route := range m.Routes {
+		handler, mw, err := reg.resolve(route)
+		if err != nil {
+			errs = append(errs, &RouteError{Pattern: route.Pattern, Problem: err.Error()})
+			continue
+		}
+
+		registrations = append(registrations, registration{pattern: route.Pattern, handler: handler, mw: mw})
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+
+	for _, rg := range registrations {
+		r.Handle(rg.pattern, rg.handler, rg.mw...)
+	}
+
+	return nil
+}
+
+// resolve will return the handler and middleware of the route.
+func (reg *Registry) resolve(route ManifestRoute) (http.Handler, []Middleware, error) {
+	if route.Pattern == "" {
+		return nil, nil, errors.New("pattern must not be empty")
+	}
+
+	handler, ok := reg.handlers[route.Handler]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown handler %q", route.Handler)
+	}
+
+	if len(route.Methods) > 0 {
+		if method, _ := splitMethod(route.Pattern); method != "" {
+			return nil, nil, errors.New("pattern has a method, methods must not be given")
+		}
+
+		options := make([]methodOption, 0, len(route.Methods))
+		seen := map[string]bool{}
+		for _, method := range route.Methods {
+			if method == "" || seen[method] {
+				return nil, nil, fmt.Errorf("invalid or repeated method %q", method)
+			}
+
+			seen[method] = true
+			options = append(options, WithMethod(method, handler))
+		}
+
+		handler = Methods(options...)
+	}
+
+	var mw []Middleware
+	for _, name := range route.Middleware {
+		m, ok := reg.middleware[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown middleware %q", name)
+		}
+
+		mw = append(mw, m)
+	}
+
+	if route.Host != "" {
+		if strings.Contains(route.Host, "/") {
+			return nil, nil, fmt.Errorf("host %q must not contain a slash", route.Host)
+		}
+
+		mw = append(mw, ForHost(route.Host))
+	}
+
+	if route.Priority != 0 {
+		mw = append(mw, Priority(route.Priority))
+	}
+
+	if route.Internal {
+		mw = append(mw, Internal())
+	}
+
+	if route.Deprecated != nil {
+		mw = append(mw, Deprecated(*route.Deprecated))
+	}
+
+	if route.Summary != "" || route.Description != "" || route.OperationID != "" || len(route.Tags) > 0 {
+		mw = append(mw, Describe(Doc{
+			Summary:     route.Summary,
+			Description: route.Description,
+			OperationID: route.OperationID,
+			Tags:        route.Tags,
+		}))
+	}
+
+	return handler, mw, nil
+}
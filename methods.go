@@ -3,17 +3,111 @@ package mux
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
-type methodOption func(map[string]http.Handler)
+type methodOption func(*methodSet)
+
+// methodSet collects the handlers of a Methods gate as its options are applied.
+type methodSet struct {
+	handlers map[string]http.Handler
+	autoHEAD bool
+
+	// defaults is set while applying the defaults of a Mux or Group, so they
+	// don't replace the methods registered explicitly.
+	defaults bool
+}
+
+// methodHandler is the handler returned by Methods.
+type methodHandler struct {
+	options  []methodOption
+	handlers map[string]http.Handler
+
+	// standard holds the handlers of the standard methods, indexed by
+	// methodIndex, so most requests are dispatched by a switch instead of a
+	// map lookup. The handlers of other methods are only in the map.
+	standard [len(standardMethods)]http.Handler
+}
+
+var standardMethods = [...]string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodConnect,
+	http.MethodTrace,
+}
+
+// methodIndex will return the index of the method in standardMethods, or -1
+// if it isn't one of them.
+func methodIndex(method string) int {
+	switch method {
+	case http.MethodGet:
+		return 0
+	case http.MethodHead:
+		return 1
+	case http.MethodPost:
+		return 2
+	case http.MethodPut:
+		return 3
+	case http.MethodPatch:
+		return 4
+	case http.MethodDelete:
+		return 5
+	case http.MethodOptions:
+		return 6
+	case http.MethodConnect:
+		return 7
+	case http.MethodTrace:
+		return 8
+	default:
+		return -1
+	}
+}
+
+// newCompiledMethodHandler will return the gate for the handlers, with the
+// handlers of the standard methods indexed.
+func newCompiledMethodHandler(options []methodOption, handlers map[string]http.Handler) *methodHandler {
+	m := &methodHandler{
+		options:  options,
+		handlers: handlers,
+	}
+
+	for method, h := range handlers {
+		if i := methodIndex(method); i >= 0 {
+			m.standard[i] = h
+		}
+	}
+
+	return m
+}
 
 // Methods will return a handler that will gate handlers by method for a path.
 // If no OPTIONS handler was provided, one will be created.
 func Methods(options ...methodOption) http.Handler {
-	methodHandlers := map[string]http.Handler{}
+	return newMethodHandler(options, nil)
+}
+
+func newMethodHandler(options, defaults []methodOption) *methodHandler {
+	set := &methodSet{handlers: map[string]http.Handler{}}
 	for _, opt := range options {
-		opt(methodHandlers)
+		opt(set)
+	}
+
+	set.defaults = true
+	for _, opt := range defaults {
+		opt(set)
+	}
+
+	methodHandlers := set.handlers
+	if get, ok := methodHandlers[http.MethodGet]; ok && set.autoHEAD {
+		if _, ok := methodHandlers[http.MethodHead]; !ok {
+			methodHandlers[http.MethodHead] = get
+		}
 	}
 
 	if _, ok := methodHandlers[http.MethodOptions]; !ok {
@@ -21,25 +115,87 @@ func Methods(options ...methodOption) http.Handler {
 		for method := range methodHandlers {
 			allowMethods = append(allowMethods, method)
 		}
+		sort.Strings(allowMethods)
 
-		allowValue := strings.Join(allowMethods, ", ")
-		methodHandlers[http.MethodOptions] = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Add("Allow", allowValue)
-			w.Header().Add("Access-Control-Allow-Methods", allowValue)
-		})
+		methodHandlers[http.MethodOptions] = allowHandler(strings.Join(allowMethods, ", "))
 	}
 
+	return newCompiledMethodHandler(options, methodHandlers)
+}
+
+// allowHandler will return the handler answering OPTIONS requests with the
+// allowed methods. The header values are built once and shared, with no spare
+// capacity, so adding to them copies instead of changing another response.
+func allowHandler(allow string) http.Handler {
+	values := []string{allow}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		handler, ok := methodHandlers[r.Method]
-		if !ok {
-			http.NotFound(w, r)
-			return
+		h := w.Header()
+		for _, key := range [...]string{"Allow", "Access-Control-Allow-Methods"} {
+			if existing, ok := h[key]; ok {
+				h[key] = append(existing, allow)
+				continue
+			}
+			h[key] = values[:1:1]
 		}
-
-		handler.ServeHTTP(w, r)
 	})
 }
 
+// ServeHTTP satisfies the handler interface.
+func (m *methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var handler http.Handler
+	if i := methodIndex(r.Method); i >= 0 {
+		handler = m.standard[i]
+	} else {
+		handler = m.handlers[r.Method]
+	}
+
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// methods will return the sorted methods of the handler if it's a Methods
+// gate.
+func methods(h http.Handler) []string {
+	m, ok := h.(*methodHandler)
+	if !ok {
+		return nil
+	}
+
+	methods := make([]string, 0, len(m.handlers))
+	for method := range m.handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	return methods
+}
+
+// withMethodDefaults will rebuild the handler with the defaults if it's a
+// Methods gate. Any other handler is returned as is.
+func withMethodDefaults(h http.Handler, defaults []methodOption) http.Handler {
+	m, ok := h.(*methodHandler)
+	if !ok || len(defaults) == 0 {
+		return h
+	}
+
+	return newMethodHandler(m.options, defaults)
+}
+
+// withErrorHandler will return a copy of the gate with the errors of its
+// ErrHandlerFuncs handled by the ErrorHandler.
+func (m *methodHandler) withErrorHandler(eh *ErrorHandler) *methodHandler {
+	handlers := make(map[string]http.Handler, len(m.handlers))
+	for method, h := range m.handlers {
+		handlers[method] = eh.handler(h)
+	}
+
+	return newCompiledMethodHandler(m.options, handlers)
+}
+
 // WithMethod will register the handler against the http method
 func WithMethod(method string, h http.Handler) methodOption {
 	if len(method) == 0 {
@@ -50,11 +206,14 @@ func WithMethod(method string, h http.Handler) methodOption {
 		panic("handler must not be nil")
 	}
 
-	return func(m map[string]http.Handler) {
-		if _, ok := m[method]; ok {
+	return func(s *methodSet) {
+		if _, ok := s.handlers[method]; ok {
+			if s.defaults {
+				return
+			}
 			panic(fmt.Sprintf("method %q already registered", method))
 		}
-		m[method] = h
+		s.handlers[method] = h
 	}
 }
 
@@ -88,3 +247,11 @@ func WithDELETE(h http.Handler) methodOption {
 func WithOPTIONS(h http.Handler) methodOption {
 	return WithMethod(http.MethodOptions, h)
 }
+
+// WithAutoHEAD will answer HEAD requests with the GET handler, if there is one
+// and no HEAD handler was provided. The http.Server discards the body.
+func WithAutoHEAD() methodOption {
+	return func(s *methodSet) {
+		s.autoHEAD = true
+	}
+}
@@ -17,3 +17,20 @@ func WrapMiddleware(mw []Middleware, handler http.Handler) http.Handler {
 
 	return handler
 }
+
+// chain will return a new slice of the middleware in order, so appending to it
+// never modifies the slices it was built from. Chains are only ever replaced,
+// never modified in place, so they can be shared between routes.
+func chain(mws ...[]Middleware) []Middleware {
+	var n int
+	for _, mw := range mws {
+		n += len(mw)
+	}
+
+	all := make([]Middleware, 0, n)
+	for _, mw := range mws {
+		all = append(all, mw...)
+	}
+
+	return all
+}
@@ -0,0 +1,169 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+type mirrorOption func(*mirror)
+
+// mirror holds the configuration of a Mirror middleware.
+type mirror struct {
+	shadow    http.Handler
+	percent   float64
+	bodyLimit int64
+	timeout   time.Duration
+	slots     chan struct{}
+}
+
+// Mirror will return middleware that also sends a copy of requests to the
+// shadow handler, such as a Proxy to a rewritten service, to test it with
+// production traffic. The shadow is served in the background with its
+// response discarded, so it never slows or changes the response of the route.
+//
+// Every request is mirrored unless limited by WithMirrorPercent. Request
+// bodies are buffered to be sent to both, and requests with a body over 64KB
+// aren't mirrored. The shadow requests have 10 seconds to complete, and at
+// most 100 are served at once, with the requests beyond that not mirrored.
+func Mirror(shadow http.Handler, options ...mirrorOption) Middleware {
+	if shadow == nil {
+		panic("shadow handler must not be nil")
+	}
+
+	m := &mirror{
+		shadow:    shadow,
+		percent:   100,
+		bodyLimit: 64 << 10,
+		timeout:   10 * time.Second,
+		slots:     make(chan struct{}, 100),
+	}
+	for _, opt := range options {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.sample() {
+				r = m.mirror(r)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithMirrorPercent will only mirror the percentage of requests, from 0 to
+// 100, chosen at random.
+func WithMirrorPercent(percent float64) mirrorOption {
+	if percent < 0 || percent > 100 {
+		panic("mirror percent must be between 0 and 100")
+	}
+
+	return func(m *mirror) {
+		m.percent = percent
+	}
+}
+
+// WithMirrorBodyLimit will only mirror requests with a body of at most n
+// bytes.
+func WithMirrorBodyLimit(n int64) mirrorOption {
+	if n < 0 {
+		panic("mirror body limit must not be negative")
+	}
+
+	return func(m *mirror) {
+		m.bodyLimit = n
+	}
+}
+
+// WithMirrorTimeout will limit how long the shadow may take to serve a request.
+func WithMirrorTimeout(d time.Duration) mirrorOption {
+	if d <= 0 {
+		panic("mirror timeout must be positive")
+	}
+
+	return func(m *mirror) {
+		m.timeout = d
+	}
+}
+
+// WithMirrorConcurrency will serve at most n shadow requests at once.
+func WithMirrorConcurrency(n int) mirrorOption {
+	if n < 1 {
+		panic("mirror concurrency must be at least 1")
+	}
+
+	return func(m *mirror) {
+		m.slots = make(chan struct{}, n)
+	}
+}
+
+func (m *mirror) sample() bool {
+	return m.percent >= 100 || rand.Float64()*100 < m.percent
+}
+
+// mirror will send a copy of the request to the shadow, if there's room for
+// it, and return the request to serve the route with. A body that's too large
+// is left for the route, with what was read of it put back.
+func (m *mirror) mirror(r *http.Request) *http.Request {
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return r
+	}
+
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		read, err := io.ReadAll(io.LimitReader(r.Body, m.bodyLimit+1))
+		if err != nil || int64(len(read)) > m.bodyLimit {
+			<-m.slots
+			r2 := r.Clone(r.Context())
+			r2.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(read), errOrReader(err, r.Body)), Closer: r.Body}
+			return r2
+		}
+
+		body = read
+		r.Body.Close()
+		r = r.Clone(r.Context())
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	ctx, cancel := context.WithTimeout(detachRouteContext(context.WithoutCancel(r.Context())), m.timeout)
+	shadow := r.Clone(ctx)
+	if body != nil {
+		shadow.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	go func() {
+		defer func() { <-m.slots }()
+		defer cancel()
+
+		// A panicking shadow must not take down the server, its response
+		// is discarded anyway.
+		defer func() { _ = recover() }()
+
+		m.shadow.ServeHTTP(discardWriter{header: http.Header{}}, shadow)
+	}()
+
+	return r
+}
+
+// readCloser joins the reader of a body with the Closer of the original.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errOrReader will return a reader failing with the error, if there was one,
+// or else the rest of the body.
+func errOrReader(err error, rest io.Reader) io.Reader {
+	if err != nil {
+		return errReader{err}
+	}
+
+	return rest
+}
@@ -0,0 +1,127 @@
+package mux
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+// PeerIdentity is the identity of a client established by the certificate it
+// presented over mutual TLS, once it was verified.
+type PeerIdentity struct {
+	// SPIFFEID is the first URI SAN with the "spiffe" scheme, such as
+	// "spiffe://example.org/ns/prod/sa/billing", or empty if there's none.
+	SPIFFEID string
+
+	// CommonName is the CN of the certificate's subject.
+	CommonName string
+
+	// DNSNames, URIs and EmailAddresses are the SANs of the certificate.
+	DNSNames       []string
+	URIs           []string
+	EmailAddresses []string
+
+	// Certificate is the verified leaf certificate.
+	Certificate *x509.Certificate
+}
+
+// PeerIdentityOf will return the identity of the client certificate the
+// request's connection was verified with, reporting false if it wasn't, such
+// as when the client presented none or the server didn't ask for one.
+func PeerIdentityOf(r *http.Request) (PeerIdentity, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return PeerIdentity{}, false
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	id := PeerIdentity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Certificate:    cert,
+	}
+
+	for _, uri := range cert.URIs {
+		id.URIs = append(id.URIs, uri.String())
+		if id.SPIFFEID == "" && uri.Scheme == "spiffe" {
+			id.SPIFFEID = uri.String()
+		}
+	}
+
+	return id, true
+}
+
+// ClientCertIdentity will return middleware establishing the Identity of
+// requests from their verified client certificate, when no middleware before
+// it established one. Its Subject is the SPIFFE ID of the certificate, or else
+// its common name, and its Claims hold "spiffe_id", "cn", "dns_names", "uris"
+// and "emails", so authorization middleware and audit logs treat certificates
+// like any other identity. The Server does this for every request when
+// WithClientCerts is given.
+func ClientCertIdentity() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := IdentityOf(r); !ok {
+				if peer, ok := PeerIdentityOf(r); ok {
+					r = WithIdentity(r, peer.identity())
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (peer PeerIdentity) identity() Identity {
+	subject := peer.SPIFFEID
+	if subject == "" {
+		subject = peer.CommonName
+	}
+
+	return Identity{
+		Subject: subject,
+		Claims: map[string]any{
+			"spiffe_id": peer.SPIFFEID,
+			"cn":        peer.CommonName,
+			"dns_names": peer.DNSNames,
+			"uris":      peer.URIs,
+			"emails":    peer.EmailAddresses,
+		},
+	}
+}
+
+// WithClientCerts will ask the clients of TLS listeners for certificates, and
+// verify them against the pool of CAs. If required, connections without a
+// valid certificate are refused, and otherwise verified only when one is
+// given. The identity of verified certificates is established for the Mux as
+// by ClientCertIdentity. It applies to the TLS config however it was set.
+func WithClientCerts(pool *x509.CertPool, required bool) serverOption {
+	if pool == nil {
+		panic("pool must not be nil")
+	}
+
+	return func(s *Server) {
+		auth := tls.VerifyClientCertIfGiven
+		if required {
+			auth = tls.RequireAndVerifyClientCert
+		}
+
+		s.clientCAs, s.clientAuth = pool, auth
+	}
+}
+
+// applyClientCerts will set the client certificate policy on the TLS config,
+// once every option has been applied.
+func (s *Server) applyClientCerts() {
+	if s.clientCAs == nil {
+		return
+	}
+
+	cfg := &tls.Config{}
+	if s.server.TLSConfig != nil {
+		cfg = s.server.TLSConfig.Clone()
+	}
+
+	cfg.ClientCAs, cfg.ClientAuth = s.clientCAs, s.clientAuth
+	s.server.TLSConfig = cfg
+}
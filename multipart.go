@@ -0,0 +1,222 @@
+package mux
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrPartTooLarge is returned by reading a Part beyond the MaxPartSize of its
+// MultipartLimits.
+var ErrPartTooLarge = errors.New("mux: multipart part too large")
+
+// MultipartLimits limits the parts of a multipart request read by
+// StreamMultipart. A zero value is unlimited.
+type MultipartLimits struct {
+	// MaxPartSize is the most bytes read from the content of any one part.
+	MaxPartSize int64
+
+	// MaxTotalSize is the most bytes read from the request body, including
+	// the headers and boundaries of the parts.
+	MaxTotalSize int64
+
+	// MaxParts is the most parts the request may have.
+	MaxParts int
+
+	// ContentTypes are the media types a file part may have, such as
+	// "image/png", or "image/*" for any subtype. A file part without a
+	// Content-Type is taken to be "application/octet-stream". If empty, any
+	// media type is allowed. Parts that aren't files are never checked.
+	ContentTypes []string
+}
+
+// Part is a part of a multipart request, as given to the callback of
+// StreamMultipart. Reading it reads the request body directly, so it must be
+// read before the callback returns.
+type Part struct {
+	*multipart.Part
+
+	r io.Reader
+}
+
+// Read will read the content of the part, returning ErrPartTooLarge once it's
+// longer than the MaxPartSize.
+func (p *Part) Read(b []byte) (int, error) {
+	return p.r.Read(b)
+}
+
+// Bytes will read the content of the part into memory, such as for a form
+// field.
+func (p *Part) Bytes() ([]byte, error) {
+	return io.ReadAll(p)
+}
+
+// SaveTemp will write the content of the part to a new temporary file in dir,
+// named by pattern as with os.CreateTemp, and return it positioned at its
+// start. The caller must close and remove the file. The file is removed if
+// the part can't be read whole.
+func (p *Part) SaveTemp(dir, pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(f, p); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// StreamMultipart will call fn with each part of the multipart request body in
+// turn, as it's read from the client, so large uploads aren't held in memory
+// or temporary files unless fn asks for it with Bytes or SaveTemp. Whatever of
+// a part fn doesn't read is discarded. The iteration stops at the first error
+// fn returns, which is returned as is unless it's from reading over the limits.
+//
+// The errors of the request are returned as errors for the ErrorHandler: a 415
+// for a body that isn't multipart or a file part of a media type not in the
+// limits, a 413 for a body, part or number of parts over the limits, and a 400
+// for a malformed body.
+//
+//	err := mux.StreamMultipart(w, r, limits, func(p *mux.Part) error {
+//		if p.FormName() != "file" {
+//			return nil
+//		}
+//		_, err := store.Put(r.Context(), p.FileName(), p)
+//		return err
+//	})
+func StreamMultipart(w http.ResponseWriter, r *http.Request, limits MultipartLimits, fn func(p *Part) error) error {
+	if limits.MaxTotalSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limits.MaxTotalSize)
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return Error(err, http.StatusUnsupportedMediaType, "request body must be multipart")
+		}
+		return Error(err, http.StatusBadRequest, "malformed multipart body")
+	}
+
+	for n := 1; ; n++ {
+		mp, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return multipartError(err)
+		}
+
+		if limits.MaxParts > 0 && n > limits.MaxParts {
+			mp.Close()
+			return Error(fmt.Errorf("mux: more than %d multipart parts", limits.MaxParts), http.StatusRequestEntityTooLarge, "too many parts")
+		}
+
+		if err := limits.checkContentType(mp); err != nil {
+			mp.Close()
+			return err
+		}
+
+		p := &Part{Part: mp, r: mp}
+		if limits.MaxPartSize > 0 {
+			p.r = &partLimitReader{r: mp, n: limits.MaxPartSize}
+		}
+
+		err = fn(p)
+		mp.Close()
+		if err != nil {
+			return multipartError(err)
+		}
+	}
+}
+
+// checkContentType will return an error if the part is a file of a media type
+// not allowed by the limits.
+func (l MultipartLimits) checkContentType(p *multipart.Part) error {
+	if len(l.ContentTypes) == 0 || p.FileName() == "" {
+		return nil
+	}
+
+	ct := p.Header.Get("Content-Type")
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+
+	mt, _, err := mime.ParseMediaType(ct)
+	if err == nil {
+		for _, allowed := range l.ContentTypes {
+			if allowed == mt {
+				return nil
+			}
+
+			if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mt, prefix+"/") {
+				return nil
+			}
+		}
+	}
+
+	msg := fmt.Sprintf("unsupported content type %q of file %q, supported types: %s", ct, p.FileName(), strings.Join(l.ContentTypes, ", "))
+	return Error(fmt.Errorf("mux: multipart file of content type %q", ct), http.StatusUnsupportedMediaType, msg)
+}
+
+// multipartError will return the error of reading a multipart body as an
+// error for the ErrorHandler, unless it already carries a status.
+func multipartError(err error) error {
+	var e interface{ StatusMsg() (int, string) }
+	if errors.As(err, &e) {
+		return err
+	}
+
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		return Error(err, http.StatusRequestEntityTooLarge, "request body too large")
+	case errors.Is(err, ErrPartTooLarge):
+		return Error(err, http.StatusRequestEntityTooLarge, "part too large")
+	case errors.Is(err, io.ErrUnexpectedEOF), strings.HasPrefix(err.Error(), "multipart: "):
+		return Error(err, http.StatusBadRequest, "malformed multipart body")
+	}
+
+	return err
+}
+
+// partLimitReader reads at most n bytes, returning ErrPartTooLarge if there's
+// more.
+type partLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *partLimitReader) Read(b []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrPartTooLarge
+	}
+
+	// Read one byte past the limit, to tell a part of exactly the limit from
+	// a longer one.
+	if int64(len(b)) > l.n+1 {
+		b = b[:l.n+1]
+	}
+
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n + int(l.n), ErrPartTooLarge
+	}
+
+	return n, err
+}
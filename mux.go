@@ -1,15 +1,77 @@
 package mux
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Mux wraps the http.ServeMux and provides a mechanism for registering
-// middleware
+// middleware. Routes, groups and middleware may be registered while the Mux
+// is serving requests, such as by plugins loaded at runtime, without any
+// locking around it. Registrations are applied one at a time, and a request
+// sees a route once its registration has returned.
 type Mux struct {
 	mux *http.ServeMux
-	mw  []Middleware
+
+	// regMu serializes registration, and guards the middleware, error
+	// handlers, and Methods defaults of the mux and its groups.
+	regMu sync.Mutex
+
+	mw []Middleware
+	eh *ErrorHandler
+
+	// mwGen counts the changes to the middleware of the mux and its groups,
+	// so groups know when to rebuild their compiled chains.
+	mwGen uint64
+
+	methodDefaults []methodOption
+
+	// paths indexes the routes by the path of their pattern, to find those
+	// shadowing each other.
+	paths map[string][]*route
+
+	// groups are the full prefixes of the groups, for WriteGraph.
+	groups []string
+
+	maintenanceMu   sync.Mutex
+	maintenance     atomic.Pointer[maintenance]
+	maintenanceHdlr http.Handler
+
+	draining   atomic.Bool
+	drainMu    sync.Mutex
+	onDrain    []func()
+	active     atomic.Int64
+	inFlightMu sync.Mutex
+	inFlight   map[string]*atomic.Int64
+
+	problemsMu sync.Mutex
+	problems   []*RouteError
+
+	// entries and routeList are read while serving without holding regMu, an
+	// entry is never removed and the list is replaced as routes are added.
+	entries   sync.Map
+	routeList atomic.Pointer[[]*route]
+	resolving atomic.Int64
+
+	routeCache atomic.Pointer[routeCache]
+
+	// internal is the policy of Internal routes, nil until it's set.
+	internal atomic.Pointer[internalPolicy]
+
+	// normalizer normalizes the paths of requests before they're matched, if
+	// NormalizePaths was given any options.
+	normalizer atomic.Pointer[pathNormalizer]
+}
+
+// Router is satisfied by both Mux and Group, for helpers that register routes
+// on either.
+type Router interface {
+	Handle(pattern string, handler http.Handler, mw ...Middleware)
+	HandleFunc(pattern string, handler http.HandlerFunc, mw ...Middleware)
+	Group(prefix string, h http.Handler, mw ...Middleware) *Group
 }
 
 // New will return an instance of a new Mux. The provided middleware will wrap
@@ -23,20 +85,55 @@ func New(mw ...Middleware) *Mux {
 
 // ServeHTTP satisfies the handler interface.
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Count the request before checking for a drain, so Drain can't observe
+	// zero active requests while this one is about to be served.
+	m.active.Add(1)
+	defer m.active.Add(-1)
+
+	if m.draining.Load() {
+		serveDraining(w, r)
+		return
+	}
+
+	if n := m.normalizer.Load(); n != nil {
+		var ok bool
+		if r, ok = n.normalize(r); !ok {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if mt := m.maintenance.Load(); mt != nil && !mt.allowed(r.URL.Path) {
+		mt.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if c := m.routeCache.Load(); c != nil {
+		m.serveCached(c, w, r)
+		return
+	}
+
 	m.mux.ServeHTTP(w, r)
 }
 
 // Handle will register the provided handler on the mux, wrapped in the provided
 // middleware(s). Middleware is envoked from left to right per request, after
-// any mux level middleware.
+// any mux level middleware. The pattern is any pattern of the http.ServeMux,
+// such as "GET /users/{id}", with its wildcards available from r.PathValue.
+// Problems with the registration are reported by Validate, other than routes
+// requiring roles without Authorize, which panic.
 func (m *Mux) Handle(pattern string, handler http.Handler, mw ...Middleware) {
-	// handler specific middleware
-	handler = WrapMiddleware(mw, handler)
+	m.regMu.Lock()
+	defer m.regMu.Unlock()
 
-	// mux middleware
-	handler = WrapMiddleware(m.mw, handler)
+	// The chain is only read while the route is wrapped, so the mux's own
+	// middleware is used as is when the route adds none.
+	all := m.mw
+	if len(mw) > 0 {
+		all = chain(m.mw, mw)
+	}
 
-	m.mux.Handle(pattern, handler)
+	m.handle(pattern, m.prepare(handler), all, "", m.errorHandler())
 }
 
 // HandleFunc will register the provided handler function on the mux, wrapped in
@@ -46,8 +143,148 @@ func (m *Mux) HandleFunc(pattern string, handler http.HandlerFunc, mw ...Middlew
 	m.Handle(pattern, handler, mw...)
 }
 
-// Group will register the provided handler under the prefix. The prefix must
-// end with a trailing slash.
-func (m *Mux) Group(prefix string, h http.Handler, mw ...Middleware) {
-	m.Handle(prefix, http.StripPrefix(strings.TrimSuffix(prefix, "/"), h), mw...)
+// Use will append middleware to the mux level middleware. It only wraps the
+// routes registered afterward, so call it before registering the routes it
+// should apply to. Appended middleware is envoked after the middleware
+// already on the mux, and before any group or route middleware.
+func (m *Mux) Use(mw ...Middleware) {
+	m.regMu.Lock()
+	defer m.regMu.Unlock()
+
+	m.mw = chain(m.mw, mw)
+	m.mwGen++
+}
+
+// HandleErr will register the provided handler that returns an error on the
+// mux, with its errors handled by the mux's ErrorHandler.
+func (m *Mux) HandleErr(pattern string, handler ErrHandlerFunc, mw ...Middleware) {
+	m.Handle(pattern, handler, mw...)
+}
+
+// SetErrorHandler will set the ErrorHandler for the ErrHandlerFuncs registered
+// on the mux afterward, and for those of groups that don't set their own. If
+// it's never set, the DefaultErrorHandler is used.
+func (m *Mux) SetErrorHandler(eh *ErrorHandler) {
+	m.regMu.Lock()
+	defer m.regMu.Unlock()
+
+	m.eh = eh
+}
+
+// MethodDefaults will set options applied to every Methods gate registered on
+// the mux afterward, including those of groups. They never replace a method
+// the gate registered itself, which suits a shared OPTIONS policy or
+// WithAutoHEAD.
+func (m *Mux) MethodDefaults(options ...methodOption) {
+	m.regMu.Lock()
+	defer m.regMu.Unlock()
+
+	m.methodDefaults = append(m.methodDefaults, options...)
+}
+
+// prepare will resolve the handler's ErrorHandler and Methods defaults before
+// it's registered.
+func (m *Mux) prepare(h http.Handler) http.Handler {
+	h = withMethodDefaults(h, m.methodDefaults)
+	return m.errorHandler().handler(h)
+}
+
+func (m *Mux) errorHandler() *ErrorHandler {
+	if m.eh == nil {
+		return DefaultErrorHandler
+	}
+
+	return m.eh
+}
+
+// Group will register the provided handler under the prefix, and return the
+// Group so more routes can be registered beneath it. The prefix must end with
+// a trailing slash. If the handler is nil, nothing is registered for the
+// prefix itself. The middleware wraps the handler and every route of the
+// Group.
+func (m *Mux) Group(prefix string, h http.Handler, mw ...Middleware) *Group {
+	m.regMu.Lock()
+	defer m.regMu.Unlock()
+
+	g := &Group{
+		mux:    m,
+		prefix: prefix,
+		mw:     mw,
+	}
+	g.mount(h)
+
+	return g
+}
+
+// handle will register the handler on the underlying http.ServeMux, wrapped in
+// the middleware chain. The prefix of the group registering the route, without
+// its trailing slash, is removed from the path before the handler is invoked,
+// unless the route keeps it. The ErrorHandler is that of the mux or group
+// registering the route.
+func (m *Mux) handle(pattern string, handler http.Handler, mw []Middleware, prefix string, eh *ErrorHandler) {
+	if isNilHandler(handler) {
+		m.problem(pattern, "handler must not be nil")
+		return
+	}
+
+	method, path := splitMethod(pattern)
+	rt := &route{
+		pattern: pattern,
+		methods: methods(handler),
+		site:    callSite(),
+	}
+	if rt.methods == nil && method != "" {
+		rt.methods = patternMethods(method)
+	}
+
+	rt.errors = routeErrorHandler(handler)
+	rt.eh = eh
+
+	var stripper *stripHandler
+	strip := strings.TrimSuffix(prefix, "/")
+	if strip != "" {
+		stripper = newStripHandler(handler)
+		handler = stripper
+	}
+
+	handler = wrapRoute(rt, mw, handler)
+	if rt.strict {
+		if problem := strictProblem(pattern, prefix); problem != "" {
+			panic(fmt.Sprintf("mux: pattern %q: %s", pattern, problem))
+		}
+	}
+
+	if rt.internal {
+		handler = m.guardInternal(handler)
+	}
+
+	if rt.requires() && !rt.authorized {
+		panic(fmt.Sprintf("mux: pattern %q: requires roles, permissions or scopes without Authorize in its chain", pattern))
+	}
+
+	if stripper != nil && !rt.keepPrefix {
+		stripper.enable(strip)
+	}
+
+	if rt.host != "" {
+		if strings.HasPrefix(path, "/") {
+			rt.pattern = joinMethod(method, rt.host+path)
+		} else {
+			m.problem(pattern, "pattern already has a host, it's served on it rather than on %q", rt.host)
+		}
+	}
+
+	// A host without a path is served at the root of the host, rather than
+	// rejected by the http.ServeMux.
+	if !strings.Contains(rt.pattern, "/") {
+		root := rt.pattern + "/{$}"
+		m.problem(rt.pattern, "pattern has a host but no path, it's registered as %q", root)
+		rt.pattern = root
+	}
+
+	if reason := unreachable(rt.pattern); reason != "" {
+		m.problem(rt.pattern, "unreachable, %s", reason)
+	}
+
+	m.add(rt, handler, stripper)
 }
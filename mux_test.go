@@ -0,0 +1,112 @@
+package mux_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kevinfalting/mux"
+)
+
+// TestConcurrentRegistration registers routes and groups while the Mux is
+// serving, and is meant to be run with -race.
+func TestConcurrentRegistration(t *testing.T) {
+	eh := &mux.ErrorHandler{}
+	m := mux.New()
+	m.SetErrorHandler(eh)
+	m.Handle("/", http.NotFoundHandler())
+	m.Handle("GET /admin", http.NotFoundHandler(), mux.Authorize(), mux.RequireRole("admin"))
+
+	const n = 100
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+
+		// The ErrorHandler is shared by the routes being served.
+		eh.Err(func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+		for i := 0; i < n; i++ {
+			m.HandleFunc(fmt.Sprintf("GET /route/%d", i), func(w http.ResponseWriter, r *http.Request) {})
+			g := m.Group(fmt.Sprintf("/group/%d/", i), nil)
+			g.HandleErr("/err", func(w http.ResponseWriter, r *http.Request) error {
+				return errors.New("failed")
+			})
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < n; i++ {
+			for _, path := range []string{"/admin", fmt.Sprintf("/route/%d", i), fmt.Sprintf("/group/%d/err", i)} {
+				m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+			_ = m.Routes()
+		}
+	}()
+
+	wg.Wait()
+
+	for path, want := range map[string]int{
+		"/admin":         http.StatusUnauthorized,
+		"/route/0":       http.StatusOK,
+		"/group/0/err":   http.StatusInternalServerError,
+		"/not/a/route/0": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s: got status %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+// TestResponseCacheVary serves a response varying by Accept-Encoding and one to
+// a request with Authorization, neither of which may be served to the wrong
+// client.
+func TestResponseCacheVary(t *testing.T) {
+	var calls int
+	h := mux.NewResponseCache(time.Minute).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Vary", "Accept-Encoding")
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header = header
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	gzip := http.Header{"Accept-Encoding": {"gzip"}}
+	get(gzip)
+	if w := get(gzip); calls != 1 || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("same Accept-Encoding: got %d calls and Content-Encoding %q, want 1 call and gzip", calls, w.Header().Get("Content-Encoding"))
+	}
+
+	if w := get(http.Header{}); calls != 2 || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("no Accept-Encoding: got %d calls and Content-Encoding %q, want 2 calls and none", calls, w.Header().Get("Content-Encoding"))
+	}
+
+	calls = 0
+	h = mux.NewResponseCache(time.Minute).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+
+	get(http.Header{"Authorization": {"Bearer alice"}})
+	if w := get(http.Header{"Authorization": {"Bearer bob"}}); calls != 2 || w.Body.String() != "Bearer bob" {
+		t.Errorf("Authorization: got %d calls and body %q, want 2 calls and Bearer bob", calls, w.Body.String())
+	}
+}
@@ -0,0 +1,372 @@
+package muxtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kevinfalting/mux"
+)
+
+// Client sends requests directly to a handler, such as a mux.Mux, and records
+// the responses for assertions.
+type Client struct {
+	handler http.Handler
+	header  http.Header
+}
+
+// NewClient will return a Client sending requests to the handler.
+func NewClient(h http.Handler) *Client {
+	if h == nil {
+		panic("handler must not be nil")
+	}
+
+	return &Client{
+		handler: h,
+		header:  http.Header{},
+	}
+}
+
+// SetHeader will set a header sent with every request of the client, such as
+// an Authorization header shared by a test.
+func (c *Client) SetHeader(key, value string) {
+	c.header.Set(key, value)
+}
+
+// Get will begin building a GET request for the path.
+func (c *Client) Get(path string) *Request {
+	return c.Request(http.MethodGet, path)
+}
+
+// Post will begin building a POST request for the path.
+func (c *Client) Post(path string) *Request {
+	return c.Request(http.MethodPost, path)
+}
+
+// Put will begin building a PUT request for the path.
+func (c *Client) Put(path string) *Request {
+	return c.Request(http.MethodPut, path)
+}
+
+// Patch will begin building a PATCH request for the path.
+func (c *Client) Patch(path string) *Request {
+	return c.Request(http.MethodPatch, path)
+}
+
+// Delete will begin building a DELETE request for the path.
+func (c *Client) Delete(path string) *Request {
+	return c.Request(http.MethodDelete, path)
+}
+
+// Request will begin building a request with the method for the path.
+func (c *Client) Request(method, path string) *Request {
+	r := newRequest(method, path)
+	r.client = c
+	for key, values := range c.header {
+		r.header[key] = append([]string(nil), values...)
+	}
+
+	return r
+}
+
+// Get will begin building a GET request for the target, which isn't bound to a
+// Client. Use Build or BuildFor to produce the *http.Request.
+func Get(target string) *Request {
+	return newRequest(http.MethodGet, target)
+}
+
+// Post will begin building a POST request for the target.
+func Post(target string) *Request {
+	return newRequest(http.MethodPost, target)
+}
+
+// Put will begin building a PUT request for the target.
+func Put(target string) *Request {
+	return newRequest(http.MethodPut, target)
+}
+
+// Patch will begin building a PATCH request for the target.
+func Patch(target string) *Request {
+	return newRequest(http.MethodPatch, target)
+}
+
+// Delete will begin building a DELETE request for the target.
+func Delete(target string) *Request {
+	return newRequest(http.MethodDelete, target)
+}
+
+// NewRequest will begin building a request with the method for the target.
+func NewRequest(method, target string) *Request {
+	return newRequest(method, target)
+}
+
+// Request builds a request fluently. Errors encountered while building, such
+// as encoding a JSON body, are reported when the request is sent or built.
+type Request struct {
+	client *Client
+	method string
+	path   string
+	header http.Header
+	body   []byte
+	values []ctxValue
+	err    error
+}
+
+type ctxValue struct {
+	key, value any
+}
+
+func newRequest(method, path string) *Request {
+	return &Request{
+		method: method,
+		path:   path,
+		header: http.Header{},
+	}
+}
+
+// WithHeader will set the header on the request.
+func (r *Request) WithHeader(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// WithAuth will set the bearer token in the Authorization header.
+func (r *Request) WithAuth(token string) *Request {
+	return r.WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithValue will add the value to the request's context, as middleware would,
+// such as an identity placed there by authentication middleware.
+func (r *Request) WithValue(key, value any) *Request {
+	r.values = append(r.values, ctxValue{key: key, value: value})
+	return r
+}
+
+// WithBody will send the body with the content type.
+func (r *Request) WithBody(contentType string, body []byte) *Request {
+	r.header.Set("Content-Type", contentType)
+	r.body = body
+	return r
+}
+
+// WithJSON will send the value encoded as JSON.
+func (r *Request) WithJSON(v any) *Request {
+	body, err := json.Marshal(v)
+	if err != nil {
+		r.err = fmt.Errorf("encoding JSON body: %w", err)
+		return r
+	}
+
+	return r.WithBody("application/json", body)
+}
+
+// Send will send the request to the client's handler and return the recorded
+// response. It fails the test if the request couldn't be built.
+func (r *Request) Send(t testing.TB) *Response {
+	t.Helper()
+
+	if r.client == nil {
+		t.Fatalf("muxtest: request %s %s has no client to send it", r.method, r.path)
+	}
+
+	if r.err != nil {
+		t.Fatalf("muxtest: building request %s %s: %v", r.method, r.path, r.err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.client.handler.ServeHTTP(rec, r.httpRequest())
+
+	return &Response{
+		t:        t,
+		Recorder: rec,
+	}
+}
+
+// Build will return the built request, for calling a handler directly. It
+// fails the test if the request couldn't be built.
+func (r *Request) Build(t testing.TB) *http.Request {
+	t.Helper()
+
+	if r.err != nil {
+		t.Fatalf("muxtest: building request %s %s: %v", r.method, r.path, r.err)
+	}
+
+	return r.httpRequest()
+}
+
+// BuildFor will return the built request as the handler of the route it matches
+// on the Mux would receive it, with path values, captured host labels, and
+// its group's prefix stripped, so a handler can be tested in isolation from
+// its middleware. It fails the test if the request matches no route.
+func (r *Request) BuildFor(t testing.TB, m *mux.Mux) *http.Request {
+	t.Helper()
+
+	req, _, ok := m.Resolve(r.Build(t))
+	if !ok {
+		t.Fatalf("muxtest: %s %s matches no route", r.method, r.path)
+	}
+
+	return req
+}
+
+// httpRequest will return the built request.
+func (r *Request) httpRequest() *http.Request {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+
+	req := httptest.NewRequest(r.method, r.path, body)
+	for key, values := range r.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+
+	if len(r.values) > 0 {
+		ctx := req.Context()
+		for _, v := range r.values {
+			ctx = context.WithValue(ctx, v.key, v.value)
+		}
+		req = req.WithContext(ctx)
+	}
+
+	return req
+}
+
+// Response is a recorded response with assertion helpers. Failed assertions
+// are reported with t.Errorf, so every assertion of a response is checked.
+type Response struct {
+	t        testing.TB
+	Recorder *httptest.ResponseRecorder
+}
+
+// Status will return the status code of the response.
+func (r *Response) Status() int {
+	return r.Recorder.Code
+}
+
+// Body will return the body of the response.
+func (r *Response) Body() string {
+	return r.Recorder.Body.String()
+}
+
+// Header will return the header of the response.
+func (r *Response) Header() http.Header {
+	return r.Recorder.Header()
+}
+
+// AssertStatus will check the response has the status code.
+func (r *Response) AssertStatus(code int) *Response {
+	r.t.Helper()
+
+	if r.Recorder.Code != code {
+		r.t.Errorf("muxtest: status = %d, want %d; body: %s", r.Recorder.Code, code, r.Recorder.Body.String())
+	}
+
+	return r
+}
+
+// AssertHeader will check the response has the header with the value.
+func (r *Response) AssertHeader(key, value string) *Response {
+	r.t.Helper()
+
+	if got := r.Recorder.Header().Get(key); got != value {
+		r.t.Errorf("muxtest: header %s = %q, want %q", key, got, value)
+	}
+
+	return r
+}
+
+// AssertBody will check the response body is exactly the string.
+func (r *Response) AssertBody(body string) *Response {
+	r.t.Helper()
+
+	if got := r.Recorder.Body.String(); got != body {
+		r.t.Errorf("muxtest: body = %q, want %q", got, body)
+	}
+
+	return r
+}
+
+// AssertJSON will check the value found at the path of the JSON response body
+// equals want, compared as JSON. The path is a dot-separated list of object
+// keys and array indexes, like "users.0.name", and an empty path is the whole
+// document.
+func (r *Response) AssertJSON(path string, want any) *Response {
+	r.t.Helper()
+
+	got, err := jsonPath(r.Recorder.Body.Bytes(), path)
+	if err != nil {
+		r.t.Errorf("muxtest: JSON path %q: %v", path, err)
+		return r
+	}
+
+	wantJSON, err := json.Marshal(want)
+	if err != nil {
+		r.t.Errorf("muxtest: encoding the wanted value of JSON path %q: %v", path, err)
+		return r
+	}
+
+	var wantValue any
+	if err := json.Unmarshal(wantJSON, &wantValue); err != nil {
+		r.t.Errorf("muxtest: decoding the wanted value of JSON path %q: %v", path, err)
+		return r
+	}
+
+	if !reflect.DeepEqual(got, wantValue) {
+		gotJSON, _ := json.Marshal(got)
+		r.t.Errorf("muxtest: JSON path %q = %s, want %s", path, gotJSON, wantJSON)
+	}
+
+	return r
+}
+
+// DecodeJSON will decode the JSON response body into v, failing the test if
+// it can't.
+func (r *Response) DecodeJSON(v any) {
+	r.t.Helper()
+
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("muxtest: decoding JSON body: %v; body: %s", err, r.Recorder.Body.String())
+	}
+}
+
+// jsonPath will return the value at the path of the JSON document.
+func jsonPath(doc []byte, path string) (any, error) {
+	var v any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, fmt.Errorf("decoding body: %w", err)
+	}
+
+	if path == "" {
+		return v, nil
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found", key)
+			}
+			v = value
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("index %q out of range of array with length %d", key, len(node))
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("key %q not found in %T", key, v)
+		}
+	}
+
+	return v, nil
+}
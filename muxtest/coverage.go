@@ -0,0 +1,74 @@
+package muxtest
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/kevinfalting/mux"
+)
+
+// Uncovered will return the routes of the Mux that haven't served a request.
+func Uncovered(m *mux.Mux) []mux.RouteInfo {
+	var uncovered []mux.RouteInfo
+	for _, route := range m.Routes() {
+		if route.Hits == 0 {
+			uncovered = append(uncovered, route)
+		}
+	}
+
+	return uncovered
+}
+
+// AssertCovered will check every route of the Mux has served a request. Call it
+// once the tests using the Mux have run, such as from the last test or a
+// cleanup function.
+func AssertCovered(t testing.TB, m *mux.Mux) {
+	t.Helper()
+
+	for _, route := range Uncovered(m) {
+		t.Errorf("muxtest: route %q was never hit", route.Pattern)
+	}
+}
+
+// TrackCoverage will check that every route of the Mux serves a request during
+// the test, failing it when the test and its subtests finish otherwise.
+// Requests served before calling it don't count.
+func TrackCoverage(t testing.TB, m *mux.Mux) {
+	t.Helper()
+
+	// Routes are reported in the order they were registered, so routes can be
+	// compared by index even if more are registered during the test.
+	var before []uint64
+	for _, route := range m.Routes() {
+		before = append(before, route.Hits)
+	}
+
+	t.Cleanup(func() {
+		for i, route := range m.Routes() {
+			var hits uint64
+			if i < len(before) {
+				hits = before[i]
+			}
+
+			if route.Hits == hits {
+				t.Errorf("muxtest: route %q was never hit", route.Pattern)
+			}
+		}
+	})
+}
+
+// ReportCoverage will write the number of routes of the Mux that have served a
+// request, followed by a line per route that hasn't, such as from TestMain
+// after the tests have run. It returns the number of uncovered routes.
+func ReportCoverage(w io.Writer, m *mux.Mux) int {
+	routes := m.Routes()
+	uncovered := Uncovered(m)
+
+	fmt.Fprintf(w, "muxtest: %d of %d routes covered\n", len(routes)-len(uncovered), len(routes))
+	for _, route := range uncovered {
+		fmt.Fprintf(w, "muxtest: route %q was never hit\n", route.Pattern)
+	}
+
+	return len(uncovered)
+}
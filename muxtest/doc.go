@@ -0,0 +1,5 @@
+/*
+Package muxtest provides helpers for testing handlers and routes registered on
+a mux.Mux, built on the net/http/httptest package.
+*/
+package muxtest
@@ -0,0 +1,100 @@
+package muxtest
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/kevinfalting/mux"
+)
+
+// CapturedError is an error returned by a handler, captured by an ErrorSink.
+type CapturedError struct {
+	Method  string
+	Path    string
+	Err     error
+	Status  int
+	Message string
+}
+
+// ErrorSink captures every error handled by its ErrorHandler, so tests can
+// check the error a handler returned rather than scraping response bodies.
+type ErrorSink struct {
+	mu   sync.Mutex
+	errs []CapturedError
+}
+
+// NewErrorSink will return an empty ErrorSink.
+func NewErrorSink() *ErrorSink {
+	return &ErrorSink{}
+}
+
+// ErrorHandler will return an ErrorHandler that responds with http.Error, and
+// captures every error into the sink. Set it on the Mux or a Group with
+// SetErrorHandler, or wrap handlers with its Err method.
+func (s *ErrorSink) ErrorHandler() *mux.ErrorHandler {
+	return &mux.ErrorHandler{
+		ErrHook: s.capture,
+	}
+}
+
+func (s *ErrorSink) capture(r *http.Request, err error, status int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errs = append(s.errs, CapturedError{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Err:     err,
+		Status:  status,
+		Message: msg,
+	})
+}
+
+// Errors will return the errors captured so far, in the order they were
+// handled.
+func (s *ErrorSink) Errors() []CapturedError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]CapturedError(nil), s.errs...)
+}
+
+// Reset will discard the captured errors.
+func (s *ErrorSink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errs = nil
+}
+
+// AssertNoErrors will check that no errors were captured.
+func (s *ErrorSink) AssertNoErrors(t testing.TB) {
+	t.Helper()
+
+	for _, e := range s.Errors() {
+		t.Errorf("muxtest: %s %s returned error: %v", e.Method, e.Path, e.Err)
+	}
+}
+
+// AssertError will check that the last captured error matches the target with
+// errors.Is, and was responded to with the status.
+func (s *ErrorSink) AssertError(t testing.TB, target error, status int) {
+	t.Helper()
+
+	errs := s.Errors()
+	if len(errs) == 0 {
+		t.Errorf("muxtest: no errors captured, want %v", target)
+		return
+	}
+
+	last := errs[len(errs)-1]
+	if !errors.Is(last.Err, target) {
+		t.Errorf("muxtest: %s %s returned error %v, want %v", last.Method, last.Path, last.Err, target)
+	}
+
+	if last.Status != status {
+		t.Errorf("muxtest: %s %s responded to error with status %d, want %d", last.Method, last.Path, last.Status, status)
+	}
+}
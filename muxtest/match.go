@@ -0,0 +1,36 @@
+package muxtest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kevinfalting/mux"
+)
+
+// AssertMatches will check that a request with the method and target would be
+// dispatched by the Mux to the route registered with the pattern, without
+// invoking any handler. The pattern is the full pattern reported by
+// mux.Mux.Routes, including the prefixes of groups.
+func AssertMatches(t testing.TB, m *mux.Mux, method, target, pattern string) {
+	t.Helper()
+
+	route, ok := m.Match(httptest.NewRequest(method, target, nil))
+	if !ok {
+		t.Errorf("muxtest: %s %s matches no route, want %q", method, target, pattern)
+		return
+	}
+
+	if route.Pattern != pattern {
+		t.Errorf("muxtest: %s %s matches %q, want %q", method, target, route.Pattern, pattern)
+	}
+}
+
+// AssertNoMatch will check that a request with the method and target isn't
+// dispatched to any route of the Mux.
+func AssertNoMatch(t testing.TB, m *mux.Mux, method, target string) {
+	t.Helper()
+
+	if route, ok := m.Match(httptest.NewRequest(method, target, nil)); ok {
+		t.Errorf("muxtest: %s %s matches %q, want no match", method, target, route.Pattern)
+	}
+}
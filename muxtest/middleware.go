@@ -0,0 +1,51 @@
+package muxtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kevinfalting/mux"
+)
+
+// AssertMiddleware will check that every route of the Mux whose pattern begins
+// with the prefix is wrapped in the middleware with the name, such as every
+// "/admin/" route having "RequireAdmin". Name middleware with mux.Named. It
+// fails if no route has the prefix, so a typo can't pass silently.
+func AssertMiddleware(t testing.TB, m *mux.Mux, prefix, name string) {
+	t.Helper()
+
+	var matched bool
+	for _, route := range m.Routes() {
+		if !strings.HasPrefix(route.Pattern, prefix) {
+			continue
+		}
+
+		matched = true
+		if !route.HasMiddleware(name) {
+			t.Errorf("muxtest: route %q is missing middleware %q, has %q", route.Pattern, name, route.Middleware)
+		}
+	}
+
+	if !matched {
+		t.Errorf("muxtest: no route has the prefix %q", prefix)
+	}
+}
+
+// AssertMiddlewareOrder will check that the route with the pattern is wrapped
+// in exactly the named middleware, in the order they're envoked.
+func AssertMiddlewareOrder(t testing.TB, m *mux.Mux, pattern string, names ...string) {
+	t.Helper()
+
+	for _, route := range m.Routes() {
+		if route.Pattern != pattern {
+			continue
+		}
+
+		if strings.Join(route.Middleware, "\x00") != strings.Join(names, "\x00") {
+			t.Errorf("muxtest: route %q has middleware %q, want %q", pattern, route.Middleware, names)
+		}
+		return
+	}
+
+	t.Errorf("muxtest: no route has the pattern %q", pattern)
+}
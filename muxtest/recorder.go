@@ -0,0 +1,99 @@
+package muxtest
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/kevinfalting/mux"
+)
+
+// Record is a request seen by a RequestRecorder.
+type Record struct {
+	Method string
+	Path   string
+	Header http.Header
+
+	// Values holds the context values of the requested keys, as seen at the
+	// point the recorder's middleware is in the chain.
+	Values map[any]any
+
+	// Status is the status code the rest of the chain responded with.
+	Status int
+}
+
+// RequestRecorder is middleware that records every request passing through it,
+// for asserting the side effects of middleware registered before it, such as
+// an identity injected into the context by authentication middleware.
+type RequestRecorder struct {
+	keys []any
+
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewRequestRecorder will return a RequestRecorder that captures the context
+// values of the keys for every request.
+func NewRequestRecorder(keys ...any) *RequestRecorder {
+	return &RequestRecorder{keys: keys}
+}
+
+// Middleware will record the requests passing through it. Register it after
+// the middleware whose effects should be seen, usually as the last middleware
+// of a route.
+func (rr *RequestRecorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := make(map[any]any, len(rr.keys))
+		for _, key := range rr.keys {
+			values[key] = r.Context().Value(key)
+		}
+
+		sw := mux.WrapResponseWriter(w)
+		next.ServeHTTP(sw, r)
+
+		status := sw.Status()
+		mux.ReleaseResponseWriter(sw)
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		rr.mu.Lock()
+		defer rr.mu.Unlock()
+
+		rr.records = append(rr.records, Record{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header.Clone(),
+			Values: values,
+			Status: status,
+		})
+	})
+}
+
+// Records will return the requests recorded so far, in the order they finished.
+func (rr *RequestRecorder) Records() []Record {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	return append([]Record(nil), rr.records...)
+}
+
+// Last will return the most recently recorded request, and false if none have
+// been recorded.
+func (rr *RequestRecorder) Last() (Record, bool) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if len(rr.records) == 0 {
+		return Record{}, false
+	}
+
+	return rr.records[len(rr.records)-1], true
+}
+
+// Reset will discard the recorded requests.
+func (rr *RequestRecorder) Reset() {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rr.records = nil
+}
@@ -0,0 +1,31 @@
+package muxtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kevinfalting/mux"
+)
+
+// Replay will send the request of the trace, such as one captured in
+// production by a mux.Tracer and saved from its debug endpoint, to the
+// handler, through all of its middleware, and return the recorded response,
+// so a bug can be reproduced in development. The header is sent on top of the
+// trace's, such as with the credentials the Tracer didn't keep.
+func Replay(t testing.TB, h http.Handler, trace mux.Trace, header http.Header) *Response {
+	t.Helper()
+
+	req := trace.Request()
+	for key, values := range header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return &Response{
+		t:        t,
+		Recorder: rec,
+	}
+}
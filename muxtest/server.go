@@ -0,0 +1,75 @@
+package muxtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// Server is a running httptest.Server whose client resolves relative URLs
+// against the server, so requests can be made with just a path.
+type Server struct {
+	*httptest.Server
+	client *http.Client
+}
+
+// StartServer will start an httptest.Server serving the handler, such as a
+// mux.Mux, and close it when the test and its subtests finish.
+func StartServer(t testing.TB, h http.Handler) *Server {
+	t.Helper()
+
+	return newServer(t, httptest.NewServer(h))
+}
+
+// StartTLSServer will start an httptest.Server serving the handler over TLS,
+// like StartServer. The client trusts the server's certificate.
+func StartTLSServer(t testing.TB, h http.Handler) *Server {
+	t.Helper()
+
+	return newServer(t, httptest.NewTLSServer(h))
+}
+
+func newServer(t testing.TB, srv *httptest.Server) *Server {
+	t.Cleanup(srv.Close)
+
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		srv.Close()
+		t.Fatalf("muxtest: parsing server URL: %v", err)
+	}
+
+	client := *srv.Client()
+	client.Transport = &baseURLTransport{
+		base: base,
+		next: srv.Client().Transport,
+	}
+
+	return &Server{
+		Server: srv,
+		client: &client,
+	}
+}
+
+// Client will return a client for the server that resolves relative URLs, such
+// as "/users/42", against the server's URL.
+func (s *Server) Client() *http.Client {
+	return s.client
+}
+
+// baseURLTransport resolves the URLs of requests without a host against the
+// base URL.
+type baseURLTransport struct {
+	base *url.URL
+	next http.RoundTripper
+}
+
+func (t *baseURLTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host == "" {
+		r = r.Clone(r.Context())
+		r.URL = t.base.ResolveReference(r.URL)
+		r.Host = ""
+	}
+
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,167 @@
+package mux
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ndjsonFlushInterval is how long an encoded object may wait in the buffer of
+// an NDJSONStream before it's flushed to the client.
+const ndjsonFlushInterval = 100 * time.Millisecond
+
+var errNDJSONClosed = errors.New("mux: ndjson stream closed")
+
+// NDJSONStream writes a response of newline-delimited JSON, one object per
+// line, as returned by StreamNDJSON.
+type NDJSONStream struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	ctx context.Context
+
+	mu      sync.Mutex
+	buf     *bufio.Writer
+	started bool
+	closed  bool
+	timer   *time.Timer
+	err     error
+}
+
+// StreamNDJSON will return a stream writing the response to the request as
+// newline-delimited JSON, such as for large exports. The response is sent with
+// the first object, so a handler can still return an error before then. The
+// objects are buffered, and flushed at least every 100 milliseconds, and the
+// server's write timeout is lifted for the length of the stream. It must be
+// closed before the handler returns:
+//
+//	s := mux.StreamNDJSON(w, r)
+//	defer s.Close()
+//	for row := range rows {
+//		if err := s.Send(row); err != nil {
+//			return err
+//		}
+//	}
+//	return s.Close()
+func StreamNDJSON(w http.ResponseWriter, r *http.Request) *NDJSONStream {
+	return &NDJSONStream{
+		w:   w,
+		rc:  http.NewResponseController(w),
+		ctx: r.Context(),
+		buf: bufio.NewWriterSize(w, 32<<10),
+	}
+}
+
+// Send will write the value as a line of JSON. It returns the request's
+// context error once the client has gone away, so the handler stops producing
+// objects, and the error of writing or encoding the value otherwise. A value
+// that fails to encode isn't written, and the stream can go on.
+func (s *NDJSONStream) Send(v any) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.closed {
+		return errNDJSONClosed
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.start()
+	if _, err := s.buf.Write(append(b, '\n')); err != nil {
+		s.err = err
+		return err
+	}
+
+	if s.timer == nil {
+		s.timer = time.AfterFunc(ndjsonFlushInterval, s.flushTimer)
+	}
+
+	return nil
+}
+
+// Flush will send the objects buffered to the client now.
+func (s *NDJSONStream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.flush()
+}
+
+// Close will send the objects still buffered and end the stream, so the
+// response ends once the handler returns. A stream closed without any object
+// sends an empty response. Close is safe to call more than once.
+func (s *NDJSONStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return s.err
+	}
+
+	s.start()
+	err := s.flush()
+	s.closed = true
+
+	return err
+}
+
+// start will send the headers of the response, with the stream locked.
+func (s *NDJSONStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	_ = s.rc.SetWriteDeadline(time.Time{})
+
+	h := s.w.Header()
+	h.Set("Content-Type", "application/x-ndjson")
+	h.Set("Cache-Control", "no-store")
+	h.Set("X-Accel-Buffering", "no")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// flush will flush the buffer to the client, with the stream locked.
+func (s *NDJSONStream) flush() error {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	if s.err != nil || s.closed {
+		return s.err
+	}
+
+	if err := s.buf.Flush(); err != nil {
+		s.err = err
+		return err
+	}
+
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.err = err
+		return err
+	}
+
+	return nil
+}
+
+func (s *NDJSONStream) flushTimer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.flush()
+}
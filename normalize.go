@@ -0,0 +1,132 @@
+package mux
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+type normalizeOption func(*pathNormalizer)
+
+// pathNormalizer is how the paths of requests are normalized before they're
+// matched.
+type pathNormalizer struct {
+	decodeSlashes bool
+	reject        bool
+	fn            func(string) string
+}
+
+// NormalizePaths will normalize the paths of requests before they're matched,
+// so every handler sees the same path and path values whatever the proxies in
+// front of the Mux did to them. Without options, paths are matched as the
+// http.ServeMux does: an encoded "%2F" stays part of its segment, so
+// "/files/a%2Fb" matches "/files/{name}" with the name "a/b". It replaces the
+// normalization set before, and NormalizePaths() with no options turns it off.
+func (m *Mux) NormalizePaths(options ...normalizeOption) {
+	if len(options) == 0 {
+		m.normalizer.Store(nil)
+		return
+	}
+
+	p := &pathNormalizer{}
+	for _, opt := range options {
+		opt(p)
+	}
+
+	m.normalizer.Store(p)
+}
+
+// WithDecodedSlashes will decode the path before it's matched, so an encoded
+// "%2F" separates segments like a slash, and "/files/a%2Fb" matches
+// "/files/{dir}/{name}".
+func WithDecodedSlashes() normalizeOption {
+	return func(p *pathNormalizer) {
+		p.decodeSlashes = true
+	}
+}
+
+// WithRejectInvalidPaths will answer the requests whose decoded path isn't
+// valid UTF-8, such as one with the overlong encoding "%C0%AF" of a slash, or
+// that contains a control character such as "%00", with a 400.
+func WithRejectInvalidPaths() normalizeOption {
+	return func(p *pathNormalizer) {
+		p.reject = true
+	}
+}
+
+// WithPathNormalizer will apply the function to each decoded segment of the
+// path, such as norm.NFC.String of golang.org/x/text/unicode/norm to match
+// paths in Unicode normalization form C, however their client composed them.
+func WithPathNormalizer(fn func(string) string) normalizeOption {
+	if fn == nil {
+		panic("normalizer must not be nil")
+	}
+
+	return func(p *pathNormalizer) {
+		p.fn = fn
+	}
+}
+
+// normalize will return the request with its path normalized, reporting false
+// if it was rejected.
+func (p *pathNormalizer) normalize(r *http.Request) (*http.Request, bool) {
+	if p.reject && !validPath(r.URL.Path) {
+		return nil, false
+	}
+
+	if !p.decodeSlashes && p.fn == nil {
+		return r, true
+	}
+
+	u := *r.URL
+	switch {
+	case p.decodeSlashes:
+		u.RawPath = ""
+		if p.fn != nil {
+			segments := strings.Split(u.Path, "/")
+			for i, seg := range segments {
+				segments[i] = p.fn(seg)
+			}
+			u.Path = strings.Join(segments, "/")
+		}
+
+	default:
+		// The segments are normalized as they were escaped, so encoded
+		// slashes stay part of their segment.
+		segments := strings.Split(u.EscapedPath(), "/")
+		decoded := make([]string, len(segments))
+		for i, seg := range segments {
+			s, err := url.PathUnescape(seg)
+			if err != nil {
+				return nil, false
+			}
+
+			decoded[i] = p.fn(s)
+			segments[i] = url.PathEscape(decoded[i])
+		}
+		u.Path, u.RawPath = strings.Join(decoded, "/"), strings.Join(segments, "/")
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = &u
+
+	return r2, true
+}
+
+// validPath reports whether the decoded path is valid UTF-8 without control
+// characters.
+func validPath(path string) bool {
+	if !utf8.ValidString(path) {
+		return false
+	}
+
+	for _, c := range path {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,13 @@
+/*
+Package openapi generates OpenAPI 3.0 documents from the routes registered on
+a mux.Mux, and checks the requests and responses it serves against a
+document, so the implementation and the spec can't drift apart unnoticed.
+
+Generate and Handler describe the route table, using the Docs routes were
+registered with by mux.Describe. A Validator's Middleware checks traffic in
+production, optionally in shadow mode to only report violations, and Assert
+fails tests on them. Scaffold goes the other way for spec-first APIs,
+generating the routes, handler stubs, and types of a document, see the
+mux-openapi command.
+*/
+package openapi
@@ -0,0 +1,280 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Document is an OpenAPI 3.0 document. Only the parts used to validate
+// requests and responses are decoded.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components,omitempty"`
+}
+
+// Info is the metadata of the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Components holds the reusable objects of the document, referenced with
+// "#/components/<kind>/<name>".
+type Components struct {
+	Schemas       map[string]*Schema      `json:"schemas,omitempty"`
+	Parameters    map[string]*Parameter   `json:"parameters,omitempty"`
+	RequestBodies map[string]*RequestBody `json:"requestBodies,omitempty"`
+	Responses     map[string]*Response    `json:"responses,omitempty"`
+
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how clients authenticate, referenced by name from
+// the security requirements of operations.
+type SecurityScheme struct {
+	Type         string      `json:"type"`
+	Description  string      `json:"description,omitempty"`
+	Scheme       string      `json:"scheme,omitempty"`
+	BearerFormat string      `json:"bearerFormat,omitempty"`
+	Flows        *OAuthFlows `json:"flows,omitempty"`
+}
+
+// OAuthFlows are the OAuth 2.0 flows of an "oauth2" SecurityScheme.
+type OAuthFlows struct {
+	Implicit          *OAuthFlow `json:"implicit,omitempty"`
+	Password          *OAuthFlow `json:"password,omitempty"`
+	ClientCredentials *OAuthFlow `json:"clientCredentials,omitempty"`
+	AuthorizationCode *OAuthFlow `json:"authorizationCode,omitempty"`
+}
+
+// OAuthFlow describes the URLs and scopes of an OAuth 2.0 flow.
+type OAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl,omitempty"`
+	RefreshURL       string            `json:"refreshUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+// PathItem describes the operations available on a path.
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+
+	Parameters []*Parameter `json:"parameters,omitempty"`
+}
+
+// Operation describes the operation for a method on a path.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+
+	// Security are the alternative requirements of the operation, each
+	// naming security schemes with the scopes they need.
+	Security []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a path, query, or header parameter of an operation.
+type Parameter struct {
+	Ref string `json:"$ref,omitempty"`
+
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody describes the body of a request.
+type RequestBody struct {
+	Ref string `json:"$ref,omitempty"`
+
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Ref string `json:"$ref,omitempty"`
+
+	Description string                `json:"description,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes the body of a content type.
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is the subset of an OpenAPI 3.0 schema object used for validation.
+type Schema struct {
+	Ref string `json:"$ref,omitempty"`
+
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+
+	AllOf []*Schema `json:"allOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+	OneOf []*Schema `json:"oneOf,omitempty"`
+
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	MinItems  *int     `json:"minItems,omitempty"`
+	MaxItems  *int     `json:"maxItems,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+}
+
+// Parse will decode an OpenAPI 3.0 document from JSON. Convert YAML documents
+// to JSON first.
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("openapi: %w", err)
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("openapi: unsupported version %q, expected 3.x", doc.OpenAPI)
+	}
+
+	return &doc, nil
+}
+
+// Load will read and parse the OpenAPI 3.0 JSON document at the path.
+func Load(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// operation will return the operation for the method, or nil if there isn't
+// one.
+func (p *PathItem) operation(method string) *Operation {
+	switch method {
+	case http.MethodGet:
+		return p.Get
+	case http.MethodPut:
+		return p.Put
+	case http.MethodPost:
+		return p.Post
+	case http.MethodDelete:
+		return p.Delete
+	case http.MethodOptions:
+		return p.Options
+	case http.MethodHead:
+		return p.Head
+	case http.MethodPatch:
+		return p.Patch
+	}
+
+	return nil
+}
+
+// setOperation will set the operation for the method, ignoring methods there's
+// no field for.
+func (p *PathItem) setOperation(method string, op *Operation) {
+	switch method {
+	case http.MethodGet:
+		p.Get = op
+	case http.MethodPut:
+		p.Put = op
+	case http.MethodPost:
+		p.Post = op
+	case http.MethodDelete:
+		p.Delete = op
+	case http.MethodOptions:
+		p.Options = op
+	case http.MethodHead:
+		p.Head = op
+	case http.MethodPatch:
+		p.Patch = op
+	}
+}
+
+// component will return the name of the component a local reference points
+// to, such as "User" for "#/components/schemas/User".
+func component(ref, kind string) (string, bool) {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(ref, prefix), true
+}
+
+func (d *Document) schema(s *Schema) (*Schema, error) {
+	for s != nil && s.Ref != "" {
+		name, ok := component(s.Ref, "schemas")
+		if !ok || d.Components.Schemas[name] == nil {
+			return nil, fmt.Errorf("unresolved reference %q", s.Ref)
+		}
+		s = d.Components.Schemas[name]
+	}
+
+	return s, nil
+}
+
+func (d *Document) parameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+
+	name, ok := component(p.Ref, "parameters")
+	if !ok || d.Components.Parameters[name] == nil {
+		return nil, fmt.Errorf("unresolved reference %q", p.Ref)
+	}
+
+	return d.Components.Parameters[name], nil
+}
+
+func (d *Document) requestBody(b *RequestBody) (*RequestBody, error) {
+	if b == nil || b.Ref == "" {
+		return b, nil
+	}
+
+	name, ok := component(b.Ref, "requestBodies")
+	if !ok || d.Components.RequestBodies[name] == nil {
+		return nil, fmt.Errorf("unresolved reference %q", b.Ref)
+	}
+
+	return d.Components.RequestBodies[name], nil
+}
+
+func (d *Document) response(r *Response) (*Response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+
+	name, ok := component(r.Ref, "responses")
+	if !ok || d.Components.Responses[name] == nil {
+		return nil, fmt.Errorf("unresolved reference %q", r.Ref)
+	}
+
+	return d.Components.Responses[name], nil
+}
@@ -0,0 +1,359 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kevinfalting/mux"
+)
+
+// Generate will return an OpenAPI 3.0 document describing the routes
+// registered on the Mux, with their methods, deprecations, the Docs they were
+// registered with by mux.Describe, and the errors of their ErrorHandler. The
+// hosts of patterns aren't representable and are dropped, and subtree patterns
+// are documented as their prefix.
+//
+// The scopes routes require by mux.RequireScope are documented as security
+// requirements of the "oauth2" scheme, added to the components without any
+// flows. Set the flows of the authorization server on it.
+func Generate(m *mux.Mux, info Info) *Document {
+	g := &generator{
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Info:    info,
+			Paths:   map[string]*PathItem{},
+		},
+		names: map[reflect.Type]string{},
+	}
+
+	for _, ri := range m.Routes() {
+		g.route(ri)
+	}
+
+	return g.doc
+}
+
+// Handler will return a handler serving the OpenAPI document of the Mux as
+// JSON, such as at "/openapi.json". The document is generated on the first
+// request, so routes registered after the handler are included.
+func Handler(m *mux.Mux, info Info) http.Handler {
+	var once sync.Once
+	var body []byte
+	var err error
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			body, err = json.MarshalIndent(Generate(m, info), "", "  ")
+		})
+
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// WriteJSON will write the document as indented JSON, such as to export it at
+// build time.
+func (d *Document) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+type generator struct {
+	doc   *Document
+	names map[reflect.Type]string
+}
+
+var wildcard = regexp.MustCompile(`\{([^}.]*)(\.\.\.)?\}`)
+
+func (g *generator) route(ri mux.RouteInfo) {
+	method, path := splitPattern(ri.Pattern)
+	path = strings.ReplaceAll(path, "{$}", "")
+	if path == "" {
+		path = "/"
+	}
+
+	var params []*Parameter
+	for _, match := range wildcard.FindAllStringSubmatch(path, -1) {
+		params = append(params, &Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	path = wildcard.ReplaceAllString(path, "{$1}")
+
+	item, ok := g.doc.Paths[path]
+	if !ok {
+		item = &PathItem{Parameters: params}
+		g.doc.Paths[path] = item
+	}
+
+	for _, method := range routeMethods(method, ri) {
+		op := item.operation(method)
+		if op != nil {
+			continue
+		}
+
+		op = g.operation(method, ri)
+		item.setOperation(method, op)
+	}
+}
+
+func (g *generator) operation(method string, ri mux.RouteInfo) *Operation {
+	op := &Operation{
+		Deprecated: ri.Deprecation != nil,
+		Responses:  map[string]*Response{},
+	}
+
+	var request any
+	responses := map[int]any{}
+	for _, d := range ri.Docs {
+		if d.Method != "" && !strings.EqualFold(d.Method, method) {
+			continue
+		}
+
+		if d.Summary != "" {
+			op.Summary = d.Summary
+		}
+		if d.Description != "" {
+			op.Description = d.Description
+		}
+		if d.OperationID != "" {
+			op.OperationID = d.OperationID
+		}
+		for _, tag := range d.Tags {
+			if !contains(op.Tags, tag) {
+				op.Tags = append(op.Tags, tag)
+			}
+		}
+		if d.Request != nil {
+			request = d.Request
+		}
+		for status, body := range d.Responses {
+			responses[status] = body
+		}
+	}
+
+	if request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(request))}},
+		}
+	}
+
+	if len(responses) == 0 {
+		responses[http.StatusOK] = nil
+	}
+
+	for status, body := range responses {
+		resp := &Response{Description: http.StatusText(status)}
+		if body != nil {
+			resp.Content = map[string]*MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(body))}}
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+	}
+
+	if len(ri.Scopes) > 0 {
+		op.Security = []map[string][]string{{"oauth2": ri.Scopes}}
+		g.oauth2()
+	}
+
+	if eh := ri.ErrorHandler; eh != nil {
+		content := map[string]*MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+		if eh.ErrBody != nil {
+			content = map[string]*MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(eh.ErrBody))}}
+		}
+		op.Responses["default"] = &Response{Description: "Error", Content: content}
+	}
+
+	return op
+}
+
+// oauth2 will add the "oauth2" security scheme to the components, unless it's
+// there already.
+func (g *generator) oauth2() {
+	c := &g.doc.Components
+	if c.SecuritySchemes == nil {
+		c.SecuritySchemes = map[string]*SecurityScheme{}
+	}
+
+	if _, ok := c.SecuritySchemes["oauth2"]; !ok {
+		c.SecuritySchemes["oauth2"] = &SecurityScheme{Type: "oauth2", Flows: &OAuthFlows{}}
+	}
+}
+
+// schema will return the schema of the Go type, adding the schemas of named
+// structs to the components and referencing them.
+func (g *generator) schema(t reflect.Type) *Schema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if s.Ref != "" {
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+
+	return &Schema{}
+}
+
+// component will return the name of the component holding the schema of the
+// named struct, adding it the first time the type is seen.
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if g.doc.Components.Schemas[name] != nil {
+		// Another package has a type of the same name.
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	g.names[t] = name
+
+	if g.doc.Components.Schemas == nil {
+		g.doc.Components.Schemas = map[string]*Schema{}
+	}
+	// Reserve the name before describing the fields, so recursive types
+	// reference it instead of recursing forever.
+	g.doc.Components.Schemas[name] = &Schema{}
+	*g.doc.Components.Schemas[name] = *g.structSchema(t)
+
+	return name
+}
+
+// structSchema will return the schema of the struct as encoding/json encodes
+// it. Fields without omitempty are required.
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := g.structSchema(f.Type)
+			for prop, ps := range embedded.Properties {
+				s.Properties[prop] = ps
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return s
+}
+
+// splitPattern will split the pattern of a route into its method, if it has
+// one, and its path without the host.
+func splitPattern(pattern string) (method, path string) {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		method, pattern = pattern[:i], strings.TrimLeft(pattern[i:], " \t")
+	}
+
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		pattern = pattern[i:]
+	}
+
+	return method, pattern
+}
+
+// routeMethods will return the methods to document for the route. HEAD and
+// OPTIONS are only documented if a Doc names them, since they're usually
+// answered automatically.
+func routeMethods(method string, ri mux.RouteInfo) []string {
+	if method != "" {
+		return []string{method}
+	}
+
+	var methods []string
+	for _, m := range ri.Methods {
+		if m != http.MethodHead && m != http.MethodOptions {
+			methods = append(methods, m)
+		}
+	}
+
+	for _, d := range ri.Docs {
+		m := strings.ToUpper(d.Method)
+		if m != "" && !contains(methods, m) && (len(ri.Methods) == 0 || contains(ri.Methods, m)) {
+			methods = append(methods, m)
+		}
+	}
+
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet}
+	}
+
+	return methods
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,119 @@
+package openapi
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kevinfalting/mux"
+)
+
+// maxResponseBody is the most of a response body that's buffered to validate
+// it. Larger bodies have only their status and content type checked.
+const maxResponseBody = 1 << 20
+
+// maxRequestBody is the most of a request body that's buffered to validate it.
+// Larger bodies have only their content type checked.
+const maxRequestBody = 1 << 20
+
+// Middleware will validate every request and response passing through it. Any
+// violations are reported, and unless the Validator is in shadow mode, invalid
+// requests are answered with a 400 describing the violations instead of being
+// served.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return v.middleware(next, v.report, !v.shadow)
+}
+
+// Assert will return middleware that fails the test with every violation of
+// the requests and responses passing through it. Requests are always served,
+// so the response can be checked too.
+func (v *Validator) Assert(t testing.TB) mux.Middleware {
+	return func(next http.Handler) http.Handler {
+		return v.middleware(next, func(_ *http.Request, violations []*Violation) {
+			t.Helper()
+			for _, violation := range violations {
+				t.Error(violation)
+			}
+		}, false)
+	}
+}
+
+func (v *Validator) middleware(next http.Handler, report func(*http.Request, []*Violation), enforce bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		violations := v.ValidateRequest(r)
+		if len(violations) > 0 {
+			if report != nil {
+				report(r, violations)
+			}
+
+			if enforce {
+				problems := make([]string, 0, len(violations))
+				for _, violation := range violations {
+					problems = append(problems, violation.Problem)
+				}
+				http.Error(w, strings.Join(problems, "\n"), http.StatusBadRequest)
+				return
+			}
+		}
+
+		cw := &captureWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		if report == nil {
+			return
+		}
+
+		status := cw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		body := cw.body.Bytes()
+		if cw.truncated {
+			body = nil
+		}
+
+		if violations := v.ValidateResponse(r, status, w.Header(), body); len(violations) > 0 {
+			report(r, violations)
+		}
+	})
+}
+
+// captureWriter captures the status code and the start of the body written to
+// the ResponseWriter.
+type captureWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *captureWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.truncated {
+		if w.body.Len()+len(b) > maxResponseBody {
+			w.truncated = true
+			w.body.Reset()
+		} else {
+			w.body.Write(b)
+		}
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
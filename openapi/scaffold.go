@@ -0,0 +1,409 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scaffold will return the Go source of package pkg implementing the document
+// with this package's mux: a struct for every schema, a Handlers interface with
+// an ErrHandlerFunc shaped method per operation, an Unimplemented type to
+// embed that answers every operation with a 501, and a Register function that
+// registers the operations on a mux.Router. The source is meant to be
+// generated, see the mux-openapi command.
+func Scaffold(doc *Document, pkg string) ([]byte, error) {
+	s := &scaffolder{doc: doc, types: map[string]string{}, comments: map[string]string{}}
+	if err := s.collect(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	s.write(&buf, pkg)
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("openapi: formatting scaffold: %w", err)
+	}
+
+	return src, nil
+}
+
+type scaffolder struct {
+	doc *Document
+
+	// types maps the names of the generated types to their definition, and
+	// order holds the names in the order they're written.
+	types    map[string]string
+	order    []string
+	comments map[string]string
+
+	paths []*scaffoldPath
+	time  bool
+}
+
+type scaffoldPath struct {
+	path       string
+	operations []*scaffoldOperation
+}
+
+type scaffoldOperation struct {
+	method    string
+	name      string
+	id        string
+	summary   string
+	doc       string
+	deprecate bool
+}
+
+var methodOrder = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+func (s *scaffolder) collect() error {
+	names := make([]string, 0, len(s.doc.Components.Schemas))
+	for name := range s.doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema := s.doc.Components.Schemas[name]
+		s.define(identifier(name), schema)
+		s.comments[identifier(name)] = schema.Description
+	}
+
+	paths := make([]string, 0, len(s.doc.Paths))
+	for path := range s.doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	seen := map[string]string{}
+	for _, path := range paths {
+		sp := &scaffoldPath{path: path}
+		item := s.doc.Paths[path]
+
+		for _, method := range methodOrder {
+			op := item.operation(method)
+			if op == nil {
+				continue
+			}
+
+			name := identifier(op.OperationID)
+			if name == "" {
+				name = identifier(strings.ToLower(method) + " " + path)
+			}
+			if other, ok := seen[name]; ok {
+				return fmt.Errorf("openapi: operations %s and %s %s have the same name %s", other, method, path, name)
+			}
+			seen[name] = method + " " + path
+
+			if err := s.operationTypes(name, op); err != nil {
+				return err
+			}
+
+			sp.operations = append(sp.operations, &scaffoldOperation{
+				method:    method,
+				name:      name,
+				id:        op.OperationID,
+				summary:   op.Summary,
+				doc:       op.Description,
+				deprecate: op.Deprecated,
+			})
+		}
+
+		if len(sp.operations) > 0 {
+			s.paths = append(s.paths, sp)
+		}
+	}
+
+	return nil
+}
+
+// operationTypes will define the types of the operation's inline request and
+// response schemas. Referenced schemas are already defined as components.
+func (s *scaffolder) operationTypes(name string, op *Operation) error {
+	if rb, err := s.doc.requestBody(op.RequestBody); err != nil {
+		return fmt.Errorf("openapi: %s: %w", name, err)
+	} else if rb != nil {
+		if schema := jsonSchema(rb.Content); schema != nil && schema.Ref == "" {
+			s.define(name+"Request", schema)
+		}
+	}
+
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		resp, err := s.doc.response(op.Responses[code])
+		if err != nil {
+			return fmt.Errorf("openapi: %s: %w", name, err)
+		}
+
+		if schema := jsonSchema(resp.Content); schema != nil && schema.Ref == "" {
+			s.define(name+strings.ToUpper(code[:1])+code[1:]+"Response", schema)
+		}
+	}
+
+	return nil
+}
+
+// define will add a named type for the schema.
+func (s *scaffolder) define(name string, schema *Schema) {
+	if _, ok := s.types[name]; ok {
+		return
+	}
+
+	// Reserve the name first, so nested types defined along the way are
+	// written after it.
+	s.types[name] = ""
+	s.order = append(s.order, name)
+
+	if schema.Type == "object" || len(schema.Properties) > 0 {
+		s.types[name] = s.structType(name, schema)
+		return
+	}
+
+	s.types[name] = s.goType(name, schema)
+}
+
+func (s *scaffolder) structType(name string, schema *Schema) string {
+	props := make([]string, 0, len(schema.Properties))
+	for prop := range schema.Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+
+	if len(props) == 0 {
+		return "map[string]any"
+	}
+
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, prop := range props {
+		ps := schema.Properties[prop]
+		field := identifier(prop)
+		if field == "" {
+			field = "Field"
+		}
+
+		tag := prop
+		if !contains(schema.Required, prop) {
+			tag += ",omitempty"
+		}
+
+		if ps.Description != "" {
+			writeComment(&b, ps.Description)
+		}
+		fmt.Fprintf(&b, "%s %s `json:%q`\n", field, s.goType(name+field, ps), tag)
+	}
+	b.WriteString("}")
+
+	return b.String()
+}
+
+// goType will return the Go type of the schema, defining a named type for
+// inline objects.
+func (s *scaffolder) goType(name string, schema *Schema) string {
+	if schema == nil {
+		return "any"
+	}
+
+	if schema.Ref != "" {
+		if ref, ok := component(schema.Ref, "schemas"); ok {
+			return s.nullable(schema, identifier(ref))
+		}
+		return "any"
+	}
+
+	if len(schema.AllOf) == 1 {
+		return s.nullable(schema, strings.TrimPrefix(s.goType(name, schema.AllOf[0]), "*"))
+	}
+
+	var t string
+	switch schema.Type {
+	case "string":
+		t = "string"
+		switch schema.Format {
+		case "date-time":
+			s.time = true
+			t = "time.Time"
+		case "byte", "binary":
+			t = "[]byte"
+		}
+	case "integer":
+		t = "int64"
+		if schema.Format == "int32" {
+			t = "int32"
+		}
+	case "number":
+		t = "float64"
+		if schema.Format == "float" {
+			t = "float32"
+		}
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + s.goType(name+"Item", schema.Items)
+	case "object":
+		if len(schema.Properties) == 0 {
+			return "map[string]any"
+		}
+		s.define(name, schema)
+		t = name
+	default:
+		return "any"
+	}
+
+	return s.nullable(schema, t)
+}
+
+func (s *scaffolder) nullable(schema *Schema, t string) string {
+	if schema.Nullable {
+		return "*" + t
+	}
+
+	return t
+}
+
+func (s *scaffolder) write(b *bytes.Buffer, pkg string) {
+	b.WriteString("// Code generated by mux-openapi. DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+
+	b.WriteString("import (\n\"errors\"\n\"net/http\"\n")
+	if s.time {
+		b.WriteString("\"time\"\n")
+	}
+	b.WriteString("\n\"github.com/kevinfalting/mux\"\n)\n\n")
+
+	for _, name := range s.order {
+		if comment := s.comments[name]; comment != "" {
+			writeComment(b, comment)
+		}
+		fmt.Fprintf(b, "type %s %s\n\n", name, s.types[name])
+	}
+
+	b.WriteString("// Handlers implements the operations of the API.\ntype Handlers interface {\n")
+	for _, sp := range s.paths {
+		for _, op := range sp.operations {
+			writeComment(b, op.name+" handles "+op.method+" "+sp.path+".")
+			if op.summary != "" {
+				writeComment(b, op.summary)
+			}
+			fmt.Fprintf(b, "%s(w http.ResponseWriter, r *http.Request) error\n", op.name)
+		}
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("// ErrNotImplemented is returned by the operations of Unimplemented.\n")
+	b.WriteString("var ErrNotImplemented = errors.New(\"not implemented\")\n\n")
+	b.WriteString("// Unimplemented answers every operation with a 501. Embed it to implement\n")
+	b.WriteString("// Handlers one operation at a time.\ntype Unimplemented struct{}\n\n")
+	for _, sp := range s.paths {
+		for _, op := range sp.operations {
+			fmt.Fprintf(b, "func (Unimplemented) %s(w http.ResponseWriter, r *http.Request) error {\n", op.name)
+			b.WriteString("return mux.Error(ErrNotImplemented, http.StatusNotImplemented, http.StatusText(http.StatusNotImplemented))\n}\n\n")
+		}
+	}
+
+	b.WriteString("// Register will register the operations of the API on the router.\n")
+	b.WriteString("func Register(r mux.Router, h Handlers) {\n")
+	for _, sp := range s.paths {
+		fmt.Fprintf(b, "r.Handle(%q, mux.Methods(\n", sp.path)
+		for _, op := range sp.operations {
+			fmt.Fprintf(b, "mux.WithMethod(%q, mux.ErrHandlerFunc(h.%s)),\n", op.method, op.name)
+		}
+		b.WriteString(")")
+
+		for _, op := range sp.operations {
+			if op.id == "" && op.summary == "" && op.doc == "" {
+				continue
+			}
+
+			fmt.Fprintf(b, ",\nmux.Describe(mux.Doc{Method: %q", op.method)
+			if op.id != "" {
+				fmt.Fprintf(b, ", OperationID: %q", op.id)
+			}
+			if op.summary != "" {
+				fmt.Fprintf(b, ", Summary: %q", op.summary)
+			}
+			if op.doc != "" {
+				fmt.Fprintf(b, ", Description: %q", op.doc)
+			}
+			b.WriteString("})")
+		}
+
+		if deprecated(sp.operations) {
+			b.WriteString(",\nmux.Deprecated(mux.Deprecation{})")
+		}
+		b.WriteString(")\n")
+	}
+	b.WriteString("}\n")
+}
+
+// deprecated reports whether every operation of a path is deprecated, since
+// deprecation applies to a whole route.
+func deprecated(ops []*scaffoldOperation) bool {
+	for _, op := range ops {
+		if !op.deprecate {
+			return false
+		}
+	}
+
+	return true
+}
+
+// jsonSchema will return the schema of the JSON content, if there is one.
+func jsonSchema(content map[string]*MediaType) *Schema {
+	mt, ok := content["application/json"]
+	if !ok || mt == nil {
+		return nil
+	}
+
+	return mt.Schema
+}
+
+// identifier will convert the name to an exported Go identifier, such as
+// "GetUsersID" for "get /users/{id}".
+func identifier(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+
+	id := b.String()
+	if id != "" && unicode.IsDigit([]rune(id)[0]) {
+		id = "Status" + id
+	}
+
+	return id
+}
+
+var initialisms = map[string]bool{
+	"API": true, "HTTP": true, "ID": true, "JSON": true, "URL": true, "URI": true, "UUID": true,
+}
+
+func writeComment(b io.StringWriter, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		b.WriteString("// " + strings.TrimSpace(line) + "\n")
+	}
+}
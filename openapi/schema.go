@@ -0,0 +1,227 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// validate will check the decoded JSON value against the schema, returning a
+// problem for each way it doesn't conform. The location names the value in
+// the problems, such as "body.items[2].name".
+func (d *Document) validate(s *Schema, v any, location string) []string {
+	s, err := d.schema(s)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", location, err)}
+	}
+	if s == nil {
+		return nil
+	}
+
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: must not be null", location)}
+	}
+
+	var problems []string
+	for _, sub := range s.AllOf {
+		problems = append(problems, d.validate(sub, v, location)...)
+	}
+
+	if len(s.AnyOf) > 0 && d.matching(s.AnyOf, v, location) == 0 {
+		problems = append(problems, fmt.Sprintf("%s: must match at least one of anyOf", location))
+	}
+
+	if len(s.OneOf) > 0 {
+		if n := d.matching(s.OneOf, v, location); n != 1 {
+			problems = append(problems, fmt.Sprintf("%s: must match exactly one of oneOf, matched %d", location, n))
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", location, v, s.Enum))
+	}
+
+	switch s.Type {
+	case "":
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return append(problems, typeProblem(location, s.Type, v))
+		}
+		problems = append(problems, d.validateObject(s, obj, location)...)
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return append(problems, typeProblem(location, s.Type, v))
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			problems = append(problems, fmt.Sprintf("%s: must have at least %d items", location, *s.MinItems))
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			problems = append(problems, fmt.Sprintf("%s: must have at most %d items", location, *s.MaxItems))
+		}
+		for i, item := range arr {
+			problems = append(problems, d.validate(s.Items, item, fmt.Sprintf("%s[%d]", location, i))...)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return append(problems, typeProblem(location, s.Type, v))
+		}
+		problems = append(problems, validateString(s, str, location)...)
+	case "number", "integer":
+		n, ok := number(v)
+		if !ok || (s.Type == "integer" && n != math.Trunc(n)) {
+			return append(problems, typeProblem(location, s.Type, v))
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: must be at least %v", location, *s.Minimum))
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: must be at most %v", location, *s.Maximum))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return append(problems, typeProblem(location, s.Type, v))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("%s: unknown schema type %q", location, s.Type))
+	}
+
+	return problems
+}
+
+func (d *Document) validateObject(s *Schema, obj map[string]any, location string) []string {
+	var problems []string
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: missing required property %q", location, name))
+		}
+	}
+
+	// Validate the properties in a stable order, so the problems are too.
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if v, ok := obj[name]; ok {
+			problems = append(problems, d.validate(s.Properties[name], v, location+"."+name)...)
+		}
+	}
+
+	return problems
+}
+
+func validateString(s *Schema, str, location string) []string {
+	var problems []string
+	n := utf8.RuneCountInString(str)
+	if s.MinLength != nil && n < *s.MinLength {
+		problems = append(problems, fmt.Sprintf("%s: must be at least %d characters", location, *s.MinLength))
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		problems = append(problems, fmt.Sprintf("%s: must be at most %d characters", location, *s.MaxLength))
+	}
+
+	if s.Pattern != "" {
+		re, err := compile(s.Pattern)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid pattern %q", location, s.Pattern))
+		} else if !re.MatchString(str) {
+			problems = append(problems, fmt.Sprintf("%s: must match pattern %q", location, s.Pattern))
+		}
+	}
+
+	return problems
+}
+
+// matching will return how many of the schemas the value conforms to.
+func (d *Document) matching(schemas []*Schema, v any, location string) int {
+	var n int
+	for _, s := range schemas {
+		if len(d.validate(s, v, location)) == 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+func inEnum(enum []any, v any) bool {
+	for _, e := range enum {
+		if en, ok := number(e); ok {
+			if vn, ok := number(v); ok && en == vn {
+				return true
+			}
+			continue
+		}
+
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// number will return the value as a float64 if it's a JSON number.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+func typeProblem(location, want string, v any) string {
+	return fmt.Sprintf("%s: must be %s, got %s", location, want, jsonType(v))
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+var patterns sync.Map
+
+// compile will return the compiled pattern, caching it since the same schemas
+// are validated for every request.
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+
+	return re, nil
+}
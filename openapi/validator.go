@@ -0,0 +1,485 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type validatorOption func(*Validator)
+
+// Validator checks requests and responses against the operations of a
+// Document.
+type Validator struct {
+	doc   *Document
+	paths []*template
+
+	shadow       bool
+	undocumented bool
+	report       func(r *http.Request, violations []*Violation)
+}
+
+// Violation is a way a request or response doesn't conform to the Document.
+type Violation struct {
+	Method string
+	Path   string
+
+	// Operation is the path of the Document the request matched, or empty if
+	// it matched none.
+	Operation string
+
+	// Response reports whether the violation is in the response rather than
+	// the request.
+	Response bool
+	Problem  string
+}
+
+// Error satisfies the error interface
+func (v *Violation) Error() string {
+	side := "request"
+	if v.Response {
+		side = "response"
+	}
+
+	operation := v.Operation
+	if operation == "" {
+		operation = "undocumented"
+	}
+
+	return fmt.Sprintf("openapi: %s %s (%s): %s: %s", v.Method, v.Path, operation, side, v.Problem)
+}
+
+// NewValidator will return a Validator for the document. By default its
+// Middleware rejects invalid requests with a 400, and violations are only
+// visible through WithReporter.
+func NewValidator(doc *Document, options ...validatorOption) *Validator {
+	if doc == nil {
+		panic("document must not be nil")
+	}
+
+	v := &Validator{doc: doc}
+	for path := range doc.Paths {
+		v.paths = append(v.paths, newTemplate(path))
+	}
+
+	for _, opt := range options {
+		opt(v)
+	}
+
+	return v
+}
+
+// Shadow will only report violations, never rejecting a request, for checking
+// production traffic against the document without affecting it.
+func Shadow() validatorOption {
+	return func(v *Validator) {
+		v.shadow = true
+	}
+}
+
+// WithReporter will call report with the violations of every request and
+// response that doesn't conform, such as to log them.
+func WithReporter(report func(r *http.Request, violations []*Violation)) validatorOption {
+	if report == nil {
+		panic("report must not be nil")
+	}
+
+	return func(v *Validator) {
+		v.report = report
+	}
+}
+
+// AllowUndocumented will let requests for paths and methods missing from the
+// document through without a violation, for documents describing only part of
+// the Mux.
+func AllowUndocumented() validatorOption {
+	return func(v *Validator) {
+		v.undocumented = true
+	}
+}
+
+// ValidateRequest will return the violations of the request. The body is read
+// and replaced, so the request can still be served. Bodies longer than 1 MiB
+// only have their content type checked, and are left to stream to the handler
+// rather than buffered whole.
+func (v *Validator) ValidateRequest(r *http.Request) []*Violation {
+	op, tmpl, params := v.match(r)
+	if op == nil {
+		if v.undocumented {
+			return nil
+		}
+		return []*Violation{v.violation(r, tmpl, false, "method and path are not documented")}
+	}
+
+	var problems []string
+	for _, p := range v.parameters(tmpl, op) {
+		problems = append(problems, v.validateParameter(r, p, params)...)
+	}
+
+	body, complete, err := readBody(r)
+	switch {
+	case err != nil:
+		problems = append(problems, fmt.Sprintf("body: %s", err))
+	case !complete:
+		problems = append(problems, v.validateRequestContentType(r, op)...)
+	default:
+		problems = append(problems, v.validateRequestBody(r, op, body)...)
+	}
+
+	return v.violations(r, tmpl, false, problems)
+}
+
+// ValidateResponse will return the violations of the response to the request,
+// given its status code, headers, and body.
+func (v *Validator) ValidateResponse(r *http.Request, status int, header http.Header, body []byte) []*Violation {
+	op, tmpl, _ := v.match(r)
+	if op == nil {
+		return nil
+	}
+
+	resp := responseFor(op, status)
+	if resp == nil {
+		return []*Violation{v.violation(r, tmpl, true, fmt.Sprintf("status %d is not documented", status))}
+	}
+
+	resp, err := v.doc.response(resp)
+	if err != nil {
+		return []*Violation{v.violation(r, tmpl, true, err.Error())}
+	}
+
+	if len(body) == 0 || r.Method == http.MethodHead {
+		return nil
+	}
+
+	if len(resp.Content) == 0 {
+		return []*Violation{v.violation(r, tmpl, true, fmt.Sprintf("status %d has no documented body", status))}
+	}
+
+	return v.violations(r, tmpl, true, v.validateBody(resp.Content, header.Get("Content-Type"), body))
+}
+
+// match will return the operation for the request, the path of the document
+// it matched, and the values of its path parameters.
+func (v *Validator) match(r *http.Request) (*Operation, *template, map[string]string) {
+	var best *template
+	var bestParams map[string]string
+	for _, t := range v.paths {
+		params, ok := t.match(r.URL.Path)
+		if ok && (best == nil || t.literals > best.literals) {
+			best, bestParams = t, params
+		}
+	}
+
+	if best == nil {
+		return nil, nil, nil
+	}
+
+	return v.doc.Paths[best.path].operation(r.Method), best, bestParams
+}
+
+// parameters will return the parameters of the operation, including those of
+// its path it doesn't override.
+func (v *Validator) parameters(t *template, op *Operation) []*Parameter {
+	var params []*Parameter
+	seen := map[string]bool{}
+	for _, list := range [][]*Parameter{op.Parameters, v.doc.Paths[t.path].Parameters} {
+		for _, p := range list {
+			resolved, err := v.doc.parameter(p)
+			if err != nil {
+				resolved = &Parameter{Name: p.Ref, In: "unresolved"}
+			}
+
+			key := resolved.In + ":" + strings.ToLower(resolved.Name)
+			if !seen[key] {
+				seen[key] = true
+				params = append(params, resolved)
+			}
+		}
+	}
+
+	return params
+}
+
+func (v *Validator) validateParameter(r *http.Request, p *Parameter, pathParams map[string]string) []string {
+	location := p.In + "." + p.Name
+
+	var values []string
+	switch p.In {
+	case "path":
+		if value, ok := pathParams[p.Name]; ok {
+			values = []string{value}
+		}
+	case "query":
+		values = r.URL.Query()[p.Name]
+	case "header":
+		values = r.Header.Values(p.Name)
+	case "cookie":
+		if c, err := r.Cookie(p.Name); err == nil {
+			values = []string{c.Value}
+		}
+	default:
+		return []string{fmt.Sprintf("%s: unsupported parameter location", location)}
+	}
+
+	if len(values) == 0 {
+		if p.Required || p.In == "path" {
+			return []string{fmt.Sprintf("%s: missing required parameter", location)}
+		}
+		return nil
+	}
+
+	schema, err := v.doc.schema(p.Schema)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", location, err)}
+	}
+
+	return v.doc.validate(schema, coerce(schema, values), location)
+}
+
+func (v *Validator) validateRequestBody(r *http.Request, op *Operation, body []byte) []string {
+	rb, err := v.doc.requestBody(op.RequestBody)
+	if err != nil {
+		return []string{fmt.Sprintf("body: %s", err)}
+	}
+
+	if rb == nil {
+		return nil
+	}
+
+	if len(body) == 0 {
+		if rb.Required {
+			return []string{"body: missing required body"}
+		}
+		return nil
+	}
+
+	return v.validateBody(rb.Content, r.Header.Get("Content-Type"), body)
+}
+
+// validateRequestContentType will check the content type of a body too long to
+// be validated against its schema.
+func (v *Validator) validateRequestContentType(r *http.Request, op *Operation) []string {
+	rb, err := v.doc.requestBody(op.RequestBody)
+	if err != nil {
+		return []string{fmt.Sprintf("body: %s", err)}
+	}
+
+	if rb == nil {
+		return nil
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if _, ok := mediaTypeFor(rb.Content, mediaType); !ok {
+		return []string{fmt.Sprintf("body: content type %q is not documented", contentType)}
+	}
+
+	return nil
+}
+
+// validateBody will check the body against the schema documented for its
+// content type. Only JSON bodies are checked against their schema.
+func (v *Validator) validateBody(content map[string]*MediaType, contentType string, body []byte) []string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	mt, ok := mediaTypeFor(content, mediaType)
+	if !ok {
+		return []string{fmt.Sprintf("body: content type %q is not documented", contentType)}
+	}
+
+	if mt == nil || mt.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("body: invalid JSON: %s", err)}
+	}
+
+	return v.doc.validate(mt.Schema, value, "body")
+}
+
+func (v *Validator) violation(r *http.Request, t *template, response bool, problem string) *Violation {
+	var operation string
+	if t != nil {
+		operation = t.path
+	}
+
+	return &Violation{
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Operation: operation,
+		Response:  response,
+		Problem:   problem,
+	}
+}
+
+func (v *Validator) violations(r *http.Request, t *template, response bool, problems []string) []*Violation {
+	var violations []*Violation
+	for _, problem := range problems {
+		violations = append(violations, v.violation(r, t, response, problem))
+	}
+
+	return violations
+}
+
+// responseFor will return the response documented for the status, falling
+// back to its range, such as "4XX", and then the default response.
+func responseFor(op *Operation, status int) *Response {
+	code := strconv.Itoa(status)
+	if resp, ok := op.Responses[code]; ok {
+		return resp
+	}
+
+	for key, resp := range op.Responses {
+		if len(key) == 3 && key[0] == code[0] && strings.EqualFold(key[1:], "XX") {
+			return resp
+		}
+	}
+
+	return op.Responses["default"]
+}
+
+// mediaTypeFor will return the media type documented for the content type,
+// falling back to wildcards such as "application/*" and "*/*".
+func mediaTypeFor(content map[string]*MediaType, mediaType string) (*MediaType, bool) {
+	if mt, ok := content[mediaType]; ok {
+		return mt, true
+	}
+
+	if i := strings.Index(mediaType, "/"); i > 0 {
+		if mt, ok := content[mediaType[:i]+"/*"]; ok {
+			return mt, true
+		}
+	}
+
+	mt, ok := content["*/*"]
+	return mt, ok
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// coerce will convert the string values of a parameter to the type of its
+// schema, leaving values that don't convert as strings for validate to
+// report.
+func coerce(s *Schema, values []string) any {
+	if s == nil {
+		return values[0]
+	}
+
+	if s.Type == "array" {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+
+		items := make([]any, 0, len(values))
+		for _, value := range values {
+			items = append(items, coerceValue(s.Items, value))
+		}
+		return items
+	}
+
+	return coerceValue(s, values[0])
+}
+
+func coerceValue(s *Schema, value string) any {
+	if s == nil {
+		return value
+	}
+
+	switch s.Type {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+
+	return value
+}
+
+// readBody will read the request's body and replace it, so it can be read
+// again by the handler. It reports false, without reading the rest, if the
+// body is longer than maxRequestBody, so what was read is replayed before the
+// rest of the body.
+func readBody(r *http.Request) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBody+1))
+	if err != nil || len(body) > maxRequestBody {
+		r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+		return nil, false, err
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, true, nil
+}
+
+// readCloser joins the reader of a body with the Closer of the original.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// template is a path of the document, such as "/users/{id}".
+type template struct {
+	path     string
+	segments []string
+	literals int
+}
+
+func newTemplate(path string) *template {
+	t := &template{path: path, segments: strings.Split(path, "/")}
+	for _, segment := range t.segments {
+		if !isParam(segment) {
+			t.literals++
+		}
+	}
+
+	return t
+}
+
+// match reports whether the request path matches the template, and the values
+// of its parameters.
+func (t *template) match(path string) (map[string]string, bool) {
+	segments := strings.Split(path, "/")
+	if len(segments) != len(t.segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range t.segments {
+		if isParam(segment) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[segment[1:len(segment)-1]] = segments[i]
+			continue
+		}
+
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func isParam(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
@@ -0,0 +1,37 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// splitMethod will split a pattern of the http.ServeMux into its method, if it
+// has one, and the rest of the pattern, such as "GET" and "/users/{id}" for
+// "GET /users/{id}".
+func splitMethod(pattern string) (method, rest string) {
+	i := strings.IndexAny(pattern, " \t")
+	if i < 0 {
+		return "", pattern
+	}
+
+	return pattern[:i], strings.TrimLeft(pattern[i:], " \t")
+}
+
+// joinMethod will put the method back on a pattern split by splitMethod.
+func joinMethod(method, rest string) string {
+	if method == "" {
+		return rest
+	}
+
+	return method + " " + rest
+}
+
+// patternMethods will return the methods the http.ServeMux serves a pattern
+// with a method for. A GET pattern also serves HEAD requests.
+func patternMethods(method string) []string {
+	if method == http.MethodGet {
+		return []string{http.MethodGet, http.MethodHead}
+	}
+
+	return []string{method}
+}
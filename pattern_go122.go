@@ -0,0 +1,8 @@
+//go:build !go1.23
+
+package mux
+
+import "net/http"
+
+// setPattern does nothing, the request has no pattern before Go 1.23.
+func setPattern(r *http.Request, pattern string) {}
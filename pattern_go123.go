@@ -0,0 +1,11 @@
+//go:build go1.23
+
+package mux
+
+import "net/http"
+
+// setPattern will set the pattern matched by the request, as the
+// http.ServeMux does.
+func setPattern(r *http.Request, pattern string) {
+	r.Pattern = pattern
+}
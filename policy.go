@@ -0,0 +1,213 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Policies are the limits of a service that can be changed while it's
+// running, such as to tighten them during an attack, read from a PolicySource
+// and applied by a PolicySet.
+type Policies struct {
+	// Bulkheads are the limits of the Bulkheads by their names. Those left
+	// out keep their limits.
+	Bulkheads map[string]int `json:"bulkheads,omitempty"`
+
+	// Internal is what the requests for Internal routes are trusted by, as by
+	// SetInternalPolicy. If nil, the internal policy is left as it is.
+	Internal *InternalTrust `json:"internal,omitempty"`
+}
+
+// InternalTrust is the internal policy of Policies, each field being the
+// option of SetInternalPolicy of the name.
+type InternalTrust struct {
+	TrustedCIDRs       []string `json:"trustedCIDRs,omitempty"`
+	TrustedHeader      string   `json:"trustedHeader,omitempty"`
+	TrustedHeaderValue string   `json:"trustedHeaderValue,omitempty"`
+	TrustedUnixSockets bool     `json:"trustedUnixSockets,omitempty"`
+}
+
+// PolicySource is where the Policies of a PolicySet are read from, such as a
+// file or a remote configuration service.
+type PolicySource interface {
+	Policies(ctx context.Context) (Policies, error)
+}
+
+// PolicySourceFunc is a function used as a PolicySource, such as one polling a
+// remote configuration service.
+type PolicySourceFunc func(ctx context.Context) (Policies, error)
+
+// Policies satisfies the PolicySource interface.
+func (f PolicySourceFunc) Policies(ctx context.Context) (Policies, error) {
+	return f(ctx)
+}
+
+// PolicyFile will return a PolicySource reading the Policies from the JSON
+// file of the path every time, rejecting fields it doesn't know so a
+// misspelled one isn't silently ignored.
+func PolicyFile(path string) PolicySource {
+	return PolicySourceFunc(func(ctx context.Context) (Policies, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return Policies{}, err
+		}
+		defer f.Close()
+
+		var p Policies
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&p); err != nil {
+			return Policies{}, fmt.Errorf("mux: parsing policies %s: %w", path, err)
+		}
+
+		return p, nil
+	})
+}
+
+// PolicySet applies Policies to the internal policy of a Mux and to
+// Bulkheads.
+type PolicySet struct {
+	mux       *Mux
+	bulkheads map[string]*Bulkhead
+
+	mu      sync.Mutex
+	current Policies
+}
+
+// NewPolicySet will return a PolicySet applying Policies to the Mux and the
+// Bulkheads, by their names.
+func NewPolicySet(m *Mux, bulkheads ...*Bulkhead) *PolicySet {
+	if m == nil {
+		panic("mux must not be nil")
+	}
+
+	p := &PolicySet{mux: m, bulkheads: make(map[string]*Bulkhead, len(bulkheads))}
+	for _, b := range bulkheads {
+		if _, ok := p.bulkheads[b.name]; ok {
+			panic(fmt.Sprintf("bulkhead %q given twice", b.name))
+		}
+		p.bulkheads[b.name] = b
+	}
+
+	return p
+}
+
+// Apply will apply the policies, reporting every problem with them without
+// applying any if there is one, such as the name of an unknown Bulkhead or an
+// invalid CIDR.
+func (p *PolicySet) Apply(policies Policies) error {
+	var errs []error
+	for name, limit := range policies.Bulkheads {
+		if _, ok := p.bulkheads[name]; !ok {
+			errs = append(errs, fmt.Errorf("mux: unknown bulkhead %q", name))
+		} else if limit < 1 {
+			errs = append(errs, fmt.Errorf("mux: bulkhead %q: limit must be at least 1", name))
+		}
+	}
+
+	var internal []internalOption
+	if trust := policies.Internal; trust != nil {
+		for _, cidr := range trust.TrustedCIDRs {
+			if _, err := netip.ParsePrefix(cidr); err != nil {
+				errs = append(errs, fmt.Errorf("mux: invalid CIDR %q: %w", cidr, err))
+			}
+		}
+
+		if trust.TrustedHeader != "" && trust.TrustedHeaderValue == "" {
+			errs = append(errs, errors.New("mux: trusted header value must not be empty"))
+		}
+
+		if len(errs) == 0 {
+			internal = trust.options()
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, limit := range policies.Bulkheads {
+		// The slots of an unchanged limit are kept, so the requests being
+		// served still count against it.
+		if b := p.bulkheads[name]; b.Stats().Limit != limit {
+			b.SetLimit(limit)
+		}
+	}
+
+	if policies.Internal != nil {
+		p.mux.SetInternalPolicy(internal...)
+	}
+
+	p.current = policies
+	return nil
+}
+
+func (t *InternalTrust) options() []internalOption {
+	var options []internalOption
+	if len(t.TrustedCIDRs) > 0 {
+		options = append(options, WithTrustedCIDRs(t.TrustedCIDRs...))
+	}
+
+	if t.TrustedHeader != "" {
+		options = append(options, WithTrustedHeader(t.TrustedHeader, t.TrustedHeaderValue))
+	}
+
+	if t.TrustedUnixSockets {
+		options = append(options, WithTrustedUnixSockets())
+	}
+
+	return options
+}
+
+// Watch will read the Policies from the source, and again every interval
+// until the context ends, applying them whenever they've changed. The errors
+// reading or applying them are passed to the function, if not nil, and the
+// policies applied last are kept until the source recovers. It blocks, so run
+// it in its own goroutine.
+func (p *PolicySet) Watch(ctx context.Context, src PolicySource, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		panic("interval must be positive")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *Policies
+	for {
+		policies, err := src.Policies(ctx)
+		if err == nil && (last == nil || !reflect.DeepEqual(*last, policies)) {
+			if err = p.Apply(policies); err == nil {
+				last = &policies
+			}
+		}
+
+		if err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Current will return the Policies applied last, or the zero Policies if
+// none have been.
+func (p *PolicySet) Current() Policies {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.current
+}
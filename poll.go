@@ -0,0 +1,112 @@
+package mux
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// PollResult is the response to a long-polling request of a Broker.
+type PollResult struct {
+	// Events are the events of the topics since the cursor of the request,
+	// in the order they were published.
+	Events []Event `json:"events"`
+
+	// Cursor is the cursor of the next request, the ID of the last event
+	// returned, or of the last one published on the Broker if none were.
+	Cursor string `json:"cursor"`
+}
+
+// PollHandler will return a handler long-polling the events of the topics,
+// waiting at most the timeout for one, such as for "GET /events/poll".
+func (b *Broker) PollHandler(timeout time.Duration, topics ...string) http.Handler {
+	if len(topics) == 0 {
+		panic("topics must not be empty")
+	}
+
+	if timeout <= 0 {
+		panic("timeout must be positive")
+	}
+
+	return ErrHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return b.Poll(w, r, timeout, topics...)
+	})
+}
+
+// Poll will respond to the long-polling request with the JSON PollResult of
+// the events of the topics published since its cursor, for clients that
+// can't stream, such as those behind proxies buffering the responses. The
+// cursor is the "cursor" query parameter, or the Last-Event-ID header. If
+// there are none yet, it waits at most the timeout for one, responding with
+// no events if none are published or the Broker is closed meanwhile.
+//
+// A request without a cursor only waits for the events published from then
+// on, so the clients should send the cursor of every response with the next
+// request. It returns ErrBrokerClosed if the Broker is closed, and an error
+// without responding if the Backlog fails.
+func (b *Broker) Poll(w http.ResponseWriter, r *http.Request, timeout time.Duration, topics ...string) error {
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		cursor = r.Header.Get("Last-Event-ID")
+	}
+
+	s, missed, err := b.open(r.Context(), cursor, topics)
+	if err != nil {
+		return err
+	}
+	defer b.remove(s, topics)
+
+	result := PollResult{Events: missed, Cursor: cursor}
+	if result.Cursor == "" {
+		result.Cursor = s.last
+	}
+
+	if len(result.Events) == 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+	wait:
+		for {
+			select {
+			case e := <-s.events:
+				if !s.sent(e) {
+					result.Events = append(result.Events, e)
+					break wait
+				}
+			case <-timer.C:
+				break wait
+			case <-s.gone:
+				break wait
+			case <-b.done:
+				break wait
+			case <-r.Context().Done():
+				return nil
+			}
+		}
+	}
+
+	// The events published along with the first are returned with it.
+	for more := true; more; {
+		select {
+		case e := <-s.events:
+			if !s.sent(e) {
+				result.Events = append(result.Events, e)
+			}
+		default:
+			more = false
+		}
+	}
+
+	if n := len(result.Events); n > 0 {
+		result.Cursor = result.Events[n-1].ID
+	}
+
+	if result.Events == nil {
+		result.Events = []Event{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(result)
+	return nil
+}
@@ -0,0 +1,14 @@
+package mux
+
+// Priority will order the route among the routes sharing its pattern that are
+// constrained, such as by RequireQuery, RequireHeader or MatchHost. They're
+// tried by descending priority, and those of the same priority in the order
+// they were registered, which is the default of zero. The route without any
+// constraint is always tried last, so its priority has no effect. Routes with
+// different patterns are chosen between by the http.ServeMux, which prefers
+// the most specific pattern regardless of their priorities.
+func Priority(n int) Middleware {
+	return option(func(rt *route) {
+		rt.priority = n
+	})
+}
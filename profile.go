@@ -0,0 +1,125 @@
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxProfileDuration limits the CPU profiles and execution traces requested
+// from the profiling routes.
+const maxProfileDuration = 5 * time.Minute
+
+// AttachProfiling will register the routes of the runtime profiles under the
+// prefix, at the paths of net/http/pprof so "go tool pprof" works against
+// them: an index of the profiles at the prefix, a CPU profile at "profile",
+// an execution trace at "trace", both for the "seconds" query parameter or
+// 30 seconds, and every other profile at its name, such as "heap", with the
+// "debug" and "gc" parameters of net/http/pprof.
+//
+// Unlike importing net/http/pprof, it registers nothing on the
+// http.DefaultServeMux. Register them on an admin Mux, never a public one.
+func AttachProfiling(r Router, prefix string, mw ...Middleware) {
+	g := r.Group(prefix, nil, mw...)
+	g.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		profiles := pprof.Profiles()
+		sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name() < profiles[j].Name() })
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, p := range profiles {
+			fmt.Fprintf(w, "%d\t%s\n", p.Count(), p.Name())
+		}
+		fmt.Fprintln(w, "-\tprofile")
+		fmt.Fprintln(w, "-\ttrace")
+	})
+
+	g.HandleErr("GET /profile", func(w http.ResponseWriter, r *http.Request) error {
+		d, err := profileDuration(r)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return Error(err, http.StatusInternalServerError, "CPU profiling is already running")
+		}
+		defer pprof.StopCPUProfile()
+
+		profileSleep(r, d)
+		return nil
+	})
+
+	g.HandleErr("GET /trace", func(w http.ResponseWriter, r *http.Request) error {
+		d, err := profileDuration(r)
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+		if err := trace.Start(w); err != nil {
+			return Error(err, http.StatusInternalServerError, "tracing is already running")
+		}
+		defer trace.Stop()
+
+		profileSleep(r, d)
+		return nil
+	})
+
+	g.HandleErr("GET /{name}", func(w http.ResponseWriter, r *http.Request) error {
+		name := r.PathValue("name")
+		p := pprof.Lookup(name)
+		if p == nil {
+			return Error(fmt.Errorf("mux: unknown profile %q", name), http.StatusNotFound, "unknown profile "+name)
+		}
+
+		debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+		if name == "heap" && r.URL.Query().Get("gc") != "" {
+			runtime.GC()
+		}
+
+		if debug == 0 {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+
+		_ = p.WriteTo(w, debug)
+		return nil
+	})
+}
+
+// profileDuration will return the duration of the CPU profile or execution
+// trace the request is for.
+func profileDuration(r *http.Request) (time.Duration, error) {
+	seconds := r.URL.Query().Get("seconds")
+	if seconds == "" {
+		return 30 * time.Second, nil
+	}
+
+	n, err := strconv.ParseFloat(seconds, 64)
+	d := time.Duration(n * float64(time.Second))
+	if err != nil || d <= 0 || d > maxProfileDuration {
+		return 0, Error(fmt.Errorf("mux: invalid profile duration %q", seconds), http.StatusBadRequest, "invalid seconds")
+	}
+
+	return d, nil
+}
+
+// profileSleep will wait for the duration, or until the request is canceled.
+func profileSleep(r *http.Request, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+}
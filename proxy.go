@@ -0,0 +1,254 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type proxyOption func(*proxy)
+
+// proxy holds the configuration of a Proxy.
+type proxy struct {
+	target *url.URL
+
+	originalPath bool
+	preserveHost bool
+	forward      []string
+	drop         []string
+	set          http.Header
+	timeout      time.Duration
+	transport    http.RoundTripper
+	modify       func(*http.Response) error
+	retry        *retryPolicy
+	hedge        *hedgePolicy
+}
+
+type proxyErrCtxKey struct{}
+
+// Proxy will return a handler forwarding requests to the target, built on
+// httputil.ReverseProxy. The path the handler sees is appended to the path of
+// the target, so a Proxy registered on a Group forwards the path with the
+// group's prefix stripped, unless registered with KeepPrefix or
+// WithOriginalPath. The upstream receives the target's host, and the
+// X-Forwarded-For, X-Forwarded-Host, and X-Forwarded-Proto headers.
+//
+// Failing to reach the upstream is returned as an error, so when registered
+// on a Mux or Group it's handled by their ErrorHandler, with a 504 status if
+// the upstream timed out and a 502 otherwise.
+func Proxy(target *url.URL, options ...proxyOption) ErrHandlerFunc {
+	if target == nil {
+		panic("target must not be nil")
+	}
+
+	p := &proxy{target: target, set: http.Header{}}
+	for _, opt := range options {
+		opt(p)
+	}
+
+	if len(p.forward) > 0 {
+		p.forward = append(p.forward, "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto")
+	}
+
+	transport := p.transport
+	if p.hedge != nil {
+		transport = p.hedge.transport(transport)
+	}
+	if p.retry != nil {
+		transport = p.retry.transport(transport)
+	}
+
+	rp := &httputil.ReverseProxy{
+		Director:       p.direct,
+		Transport:      transport,
+		ModifyResponse: p.modify,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if slot, ok := r.Context().Value(proxyErrCtxKey{}).(*error); ok {
+				*slot = proxyError(err)
+			}
+		},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var err error
+		ctx := context.WithValue(r.Context(), proxyErrCtxKey{}, &err)
+		if p.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, p.timeout)
+			defer cancel()
+		}
+
+		r = r.WithContext(ctx)
+		if p.retry != nil {
+			p.retry.buffer(r)
+		}
+
+		rp.ServeHTTP(w, r)
+		return err
+	}
+}
+
+// WithOriginalPath will forward the request's path from before any Group
+// stripped its prefix, see OriginalPath.
+func WithOriginalPath() proxyOption {
+	return func(p *proxy) {
+		p.originalPath = true
+	}
+}
+
+// WithPreserveHost will send the request's Host header to the upstream,
+// instead of the target's host.
+func WithPreserveHost() proxyOption {
+	return func(p *proxy) {
+		p.preserveHost = true
+	}
+}
+
+// WithForwardHeaders will only forward the named request headers to the
+// upstream, along with the X-Forwarded headers. By default every header but
+// the hop-by-hop ones is forwarded.
+func WithForwardHeaders(names ...string) proxyOption {
+	return func(p *proxy) {
+		p.forward = append(p.forward, names...)
+	}
+}
+
+// WithDropHeaders will remove the named request headers before forwarding the
+// request, such as credentials meant only for this server.
+func WithDropHeaders(names ...string) proxyOption {
+	return func(p *proxy) {
+		p.drop = append(p.drop, names...)
+	}
+}
+
+// WithUpstreamHeader will set the request header to the value on every request
+// forwarded to the upstream.
+func WithUpstreamHeader(name, value string) proxyOption {
+	return func(p *proxy) {
+		p.set.Set(name, value)
+	}
+}
+
+// WithUpstreamTimeout will limit how long a request to the upstream may take,
+// including reading its response body.
+func WithUpstreamTimeout(d time.Duration) proxyOption {
+	return func(p *proxy) {
+		p.timeout = d
+	}
+}
+
+// WithTransport will make requests to the upstream with the RoundTripper,
+// instead of http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) proxyOption {
+	if rt == nil {
+		panic("transport must not be nil")
+	}
+
+	return func(p *proxy) {
+		p.transport = rt
+	}
+}
+
+// WithModifyResponse will call modify with every response from the upstream,
+// like httputil.ReverseProxy.ModifyResponse. An error is handled like failing
+// to reach the upstream.
+func WithModifyResponse(modify func(*http.Response) error) proxyOption {
+	return func(p *proxy) {
+		p.modify = modify
+	}
+}
+
+// direct will rewrite the request to be sent to the target.
+func (p *proxy) direct(r *http.Request) {
+	path, rawPath := r.URL.Path, r.URL.RawPath
+	if p.originalPath {
+		path, rawPath = OriginalPath(r), ""
+	}
+
+	r.Header.Set("X-Forwarded-Host", r.Host)
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	r.Header.Set("X-Forwarded-Proto", proto)
+
+	r.URL.Scheme = p.target.Scheme
+	r.URL.Host = p.target.Host
+	r.URL.Path, r.URL.RawPath = joinURLPath(p.target, path, rawPath)
+	if p.target.RawQuery == "" || r.URL.RawQuery == "" {
+		r.URL.RawQuery = p.target.RawQuery + r.URL.RawQuery
+	} else {
+		r.URL.RawQuery = p.target.RawQuery + "&" + r.URL.RawQuery
+	}
+
+	if !p.preserveHost {
+		r.Host = p.target.Host
+	}
+
+	if len(p.forward) > 0 {
+		kept := http.Header{}
+		for _, name := range p.forward {
+			if values := r.Header.Values(name); len(values) > 0 {
+				kept[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		r.Header = kept
+	}
+
+	for _, name := range p.drop {
+		r.Header.Del(name)
+	}
+
+	for name, values := range p.set {
+		r.Header[name] = values
+	}
+
+	// Stop the ReverseProxy adding a default User-Agent when the client
+	// didn't send one.
+	if _, ok := r.Header["User-Agent"]; !ok {
+		r.Header.Set("User-Agent", "")
+	}
+}
+
+// joinURLPath will append the path to the target's path, like
+// httputil.NewSingleHostReverseProxy.
+func joinURLPath(target *url.URL, path, rawPath string) (string, string) {
+	if target.RawPath == "" && rawPath == "" {
+		return singleJoiningSlash(target.Path, path), ""
+	}
+
+	targetRaw := target.EscapedPath()
+	reqRaw := rawPath
+	if reqRaw == "" {
+		reqRaw = (&url.URL{Path: path}).EscapedPath()
+	}
+
+	return singleJoiningSlash(target.Path, path), singleJoiningSlash(targetRaw, reqRaw)
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash && b != "":
+		return a + "/" + b
+	}
+
+	return a + b
+}
+
+// proxyError will convert an error reaching the upstream to one the
+// ErrorHandler responds to with a gateway status.
+func proxyError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Error(err, http.StatusGatewayTimeout, http.StatusText(http.StatusGatewayTimeout))
+	}
+
+	return Error(err, http.StatusBadGateway, http.StatusText(http.StatusBadGateway))
+}
@@ -0,0 +1,20 @@
+package mux
+
+import "net/http"
+
+// RequireQuery will constrain the route to requests whose query has the
+// parameter with the value, such as RequireQuery("action", "delete") for
+// "?action=delete", so several routes can share a pattern and be told apart by
+// their query. Requests it doesn't match are dispatched to the next route of
+// the pattern, trying the route without any constraint last.
+func RequireQuery(name, value string) Middleware {
+	if len(name) == 0 {
+		panic("query parameter name must not be empty")
+	}
+
+	return option(func(rt *route) {
+		rt.matchers = append(rt.matchers, func(r *http.Request) (*http.Request, bool) {
+			return r, contains(r.URL.Query()[name], value)
+		})
+	})
+}
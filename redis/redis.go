@@ -0,0 +1,86 @@
+/*
+Package redis provides a mux.CacheStore and a mux.SessionStore kept in Redis,
+so the replicas of a service share a cache and sessions. It lives in its own
+module so the mux package doesn't depend on the Redis client.
+
+	client := goredis.NewClient(&goredis.Options{Addr: "localhost:6379"})
+	cache := mux.NewResponseCache(time.Minute, mux.WithCacheStore(redis.NewStore(client)))
+	sessions := mux.NewSessions(redis.NewSessionStore(client))
+*/
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kevinfalting/mux"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+type storeOption func(*config)
+
+// config is shared by the stores, so they take the same options.
+type config struct {
+	prefix string
+}
+
+func newConfig(options []storeOption) config {
+	c := config{prefix: "mux:"}
+	for _, opt := range options {
+		opt(&c)
+	}
+
+	return c
+}
+
+// Store is a mux.CacheStore keeping its values in Redis, expiring them with
+// their TTL.
+type Store struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+var _ mux.CacheStore = (*Store)(nil)
+
+// NewStore will return a Store using the client, which may be a single node, a
+// cluster, or a failover client. Its keys are prefixed with "mux:".
+func NewStore(client goredis.UniversalClient, options ...storeOption) *Store {
+	if client == nil {
+		panic("client must not be nil")
+	}
+
+	return &Store{client: client, prefix: newConfig(options).prefix}
+}
+
+// WithPrefix will prefix the store's keys with prefix, so several stores can
+// share a database.
+func WithPrefix(prefix string) storeOption {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// Get satisfies the mux.CacheStore interface.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+// Set satisfies the mux.CacheStore interface.
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+// Delete satisfies the mux.CacheStore interface.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
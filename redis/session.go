@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/kevinfalting/mux"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// SessionStore is a mux.SessionStore keeping sessions in Redis, as JSON under
+// "<prefix>session:<id>". The IDs of each user's sessions are indexed in a set
+// under "<prefix>user:<id>", so DeleteUser doesn't scan the database.
+type SessionStore struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+var _ mux.SessionStore = (*SessionStore)(nil)
+
+// NewSessionStore will return a SessionStore using the client. Its keys are
+// prefixed with "mux:".
+func NewSessionStore(client goredis.UniversalClient, options ...storeOption) *SessionStore {
+	if client == nil {
+		panic("client must not be nil")
+	}
+
+	return &SessionStore{client: client, prefix: newConfig(options).prefix}
+}
+
+// Load satisfies the mux.SessionStore interface.
+func (s *SessionStore) Load(ctx context.Context, id string) (mux.SessionRecord, bool, error) {
+	b, err := s.client.Get(ctx, s.sessionKey(id)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return mux.SessionRecord{}, false, nil
+	}
+
+	if err != nil {
+		return mux.SessionRecord{}, false, err
+	}
+
+	var rec mux.SessionRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return mux.SessionRecord{}, false, err
+	}
+
+	return rec, true, nil
+}
+
+// Save satisfies the mux.SessionStore interface.
+func (s *SessionStore) Save(ctx context.Context, id string, rec mux.SessionRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(rec.Expires)
+	if ttl <= 0 {
+		return s.Delete(ctx, id)
+	}
+
+	// The keys may live on different nodes of a cluster, so they're set in
+	// a pipeline rather than a transaction.
+	_, err = s.client.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		p.Set(ctx, s.sessionKey(id), b, ttl)
+		if rec.UserID != "" {
+			// The index lives as long as the session saved last, and may
+			// list IDs that already expired.
+			p.SAdd(ctx, s.userKey(rec.UserID), id)
+			p.Expire(ctx, s.userKey(rec.UserID), ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Delete satisfies the mux.SessionStore interface.
+func (s *SessionStore) Delete(ctx context.Context, id string) error {
+	return s.client.Del(ctx, s.sessionKey(id)).Err()
+}
+
+// DeleteUser satisfies the mux.SessionStore interface.
+func (s *SessionStore) DeleteUser(ctx context.Context, userID string) error {
+	ids, err := s.client.SMembers(ctx, s.userKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		keys = append(keys, s.sessionKey(id))
+	}
+	keys = append(keys, s.userKey(userID))
+
+	_, err = s.client.Pipelined(ctx, func(p goredis.Pipeliner) error {
+		for _, key := range keys {
+			p.Del(ctx, key)
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (s *SessionStore) sessionKey(id string) string {
+	return s.prefix + "session:" + id
+}
+
+func (s *SessionStore) userKey(userID string) string {
+	return s.prefix + "user:" + userID
+}
@@ -0,0 +1,369 @@
+package mux
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RememberToken is a remember-me series as it's kept by a RememberStore. Only
+// hashes of its tokens are kept, so a leaked store can't be used to log in.
+type RememberToken struct {
+	// UserID is the user the series logs in.
+	UserID string `json:"userId"`
+
+	// Hash is the hash of the series' current token.
+	Hash string `json:"hash"`
+
+	// PreviousHash is the hash of the token it was rotated from, accepted
+	// briefly after Rotated for requests the client sent in parallel.
+	PreviousHash string    `json:"previousHash,omitempty"`
+	Rotated      time.Time `json:"rotated"`
+
+	// Expires is when the series expires.
+	Expires time.Time `json:"expires"`
+}
+
+// RememberStore keeps the series of RememberMe by their IDs. Implementations
+// must be safe for concurrent use, and MemoryRememberStore keeps them in
+// memory.
+type RememberStore interface {
+	// Load will return the series of the ID, reporting false when it's
+	// missing or has expired.
+	Load(ctx context.Context, series string) (RememberToken, bool, error)
+
+	// Save will keep the series under the ID until it expires.
+	Save(ctx context.Context, series string, t RememberToken) error
+
+	// Rotate will replace the series of the ID with t only if its current
+	// hash is still the hash given, reporting whether it did, so of the
+	// requests rotating the same token in parallel only one succeeds.
+	Rotate(ctx context.Context, series, hash string, t RememberToken) (bool, error)
+
+	// Delete will remove the series of the ID, if it's present.
+	Delete(ctx context.Context, series string) error
+
+	// DeleteUser will remove every series of the user.
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+type rememberOption func(*RememberMe)
+
+// RememberMe logs clients back in once their session has expired, by a
+// persistent cookie of a series and a token. The token is rotated every time
+// it's used, so a stolen cookie is only good until its owner uses it again.
+// When a series is presented with a token that's no longer current, its
+// cookie was stolen: every series and session of the user is ended.
+type RememberMe struct {
+	sessions *Sessions
+	store    RememberStore
+	cookie   string
+	ttl      time.Duration
+	grace    time.Duration
+	theft    func(r *http.Request, userID string)
+}
+
+// NewRememberMe will return the RememberMe logging users into the sessions, by
+// a cookie named "remember" expiring after 30 days.
+func NewRememberMe(sessions *Sessions, store RememberStore, options ...rememberOption) *RememberMe {
+	if sessions == nil {
+		panic("sessions must not be nil")
+	}
+
+	if store == nil {
+		panic("store must not be nil")
+	}
+
+	rm := &RememberMe{
+		sessions: sessions,
+		store:    store,
+		cookie:   "remember",
+		ttl:      30 * 24 * time.Hour,
+		grace:    30 * time.Second,
+	}
+	for _, opt := range options {
+		opt(rm)
+	}
+
+	return rm
+}
+
+// WithRememberCookie will keep the series and token in the cookie of the name.
+func WithRememberCookie(name string) rememberOption {
+	if len(name) == 0 {
+		panic("cookie name must not be empty")
+	}
+
+	return func(rm *RememberMe) {
+		rm.cookie = name
+	}
+}
+
+// WithRememberTTL will expire series the duration after they're created,
+// instead of 30 days. Rotating a token doesn't extend its series.
+func WithRememberTTL(d time.Duration) rememberOption {
+	if d <= 0 {
+		panic("ttl must be positive")
+	}
+
+	return func(rm *RememberMe) {
+		rm.ttl = d
+	}
+}
+
+// WithTheftHook will call the hook when a stolen cookie is detected, after
+// the user's series and sessions were ended, such as to log a security event
+// or notify the user.
+func WithTheftHook(hook func(r *http.Request, userID string)) rememberOption {
+	if hook == nil {
+		panic("hook must not be nil")
+	}
+
+	return func(rm *RememberMe) {
+		rm.theft = hook
+	}
+}
+
+// Remember will start a series for the user, setting its cookie, such as when
+// they log in with "remember me" checked.
+func (rm *RememberMe) Remember(w http.ResponseWriter, r *http.Request, userID string) error {
+	series, err := newSessionID()
+	if err != nil {
+		return err
+	}
+
+	token, err := newSessionID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	t := RememberToken{UserID: userID, Hash: hashToken(token), Rotated: now, Expires: now.Add(rm.ttl)}
+	if err := rm.store.Save(r.Context(), series, t); err != nil {
+		return err
+	}
+
+	rm.setCookie(w, series+":"+token, t.Expires)
+	return nil
+}
+
+// Forget will end the series of the request's cookie and clear it, such as
+// when the user logs out.
+func (rm *RememberMe) Forget(w http.ResponseWriter, r *http.Request) error {
+	rm.setCookie(w, "", time.Time{})
+	c, err := r.Cookie(rm.cookie)
+	if err != nil {
+		return nil
+	}
+
+	series, _, _ := strings.Cut(c.Value, ":")
+	return rm.store.Delete(r.Context(), series)
+}
+
+// Middleware will log the user of a request's cookie into its session when
+// it has none, rotating the cookie's token. Register it after the Middleware
+// of the Sessions. A failure of the store is responded to with a 500 of
+// ErrSessionStore by the route's ErrorHandler.
+func (rm *RememberMe) Middleware(next http.Handler) http.Handler {
+	return &rememberHandler{rm: rm, next: next, eh: DefaultErrorHandler}
+}
+
+type rememberHandler struct {
+	rm   *RememberMe
+	next http.Handler
+	eh   *ErrorHandler
+}
+
+func (h *rememberHandler) configure(rt *route) {
+	h.eh = rt.errorHandler()
+}
+
+func (h *rememberHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if sess := SessionOf(r); sess != nil && sess.UserID() == "" {
+		if c, err := r.Cookie(h.rm.cookie); err == nil && c.Value != "" {
+			if err := h.rm.login(w, r, sess, c.Value); err != nil {
+				h.eh.respond(w, r, Error(errors.Join(ErrSessionStore, err), http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)))
+				return
+			}
+		}
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// login will log the user of the cookie's series into the session if its
+// token is current, rotating it, and end the user's series and sessions if it
+// isn't. Of the requests presenting the current token in parallel, only one
+// rotates it, and the others are let in as if sent alongside it.
+func (rm *RememberMe) login(w http.ResponseWriter, r *http.Request, sess *Session, value string) error {
+	ctx := r.Context()
+	series, token, _ := strings.Cut(value, ":")
+	t, ok, err := rm.store.Load(ctx, series)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if !ok || !now.Before(t.Expires) {
+		rm.setCookie(w, "", time.Time{})
+		return nil
+	}
+
+	hash := hashToken(token)
+	if equalHash(hash, t.Hash) {
+		next, err := newSessionID()
+		if err != nil {
+			return err
+		}
+
+		rotated := t
+		rotated.PreviousHash, rotated.Hash, rotated.Rotated = t.Hash, hashToken(next), now
+		swapped, err := rm.store.Rotate(ctx, series, t.Hash, rotated)
+		if err != nil {
+			return err
+		}
+
+		if swapped {
+			rm.setCookie(w, series+":"+next, rotated.Expires)
+			sess.SetUser(t.UserID)
+			return nil
+		}
+
+		// A request sent in parallel rotated the token first, so it's
+		// checked against the series as that one left it.
+		if t, ok, err = rm.store.Load(ctx, series); err != nil {
+			return err
+		}
+		if !ok {
+			rm.setCookie(w, "", time.Time{})
+			return nil
+		}
+	}
+
+	// A request sent alongside the one that rotated the token is let in,
+	// the client already has the new one.
+	if !equalHash(hash, t.PreviousHash) || now.Sub(t.Rotated) >= rm.grace {
+		rm.setCookie(w, "", time.Time{})
+		if err := rm.store.DeleteUser(ctx, t.UserID); err != nil {
+			return err
+		}
+		if err := rm.sessions.InvalidateUser(ctx, t.UserID); err != nil {
+			return err
+		}
+		if rm.theft != nil {
+			rm.theft(r, t.UserID)
+		}
+		return nil
+	}
+
+	sess.SetUser(t.UserID)
+	return nil
+}
+
+// setCookie will set the cookie to the value until it expires, or clear it if
+// the value is empty.
+func (rm *RememberMe) setCookie(w http.ResponseWriter, value string, expires time.Time) {
+	c := &http.Cookie{
+		Name:     rm.cookie,
+		Value:    value,
+		Path:     "/",
+		Secure:   !rm.sessions.insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if value == "" {
+		c.MaxAge = -1
+	} else {
+		c.Expires = expires
+	}
+
+	http.SetCookie(w, c)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func equalHash(a, b string) bool {
+	return b != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// MemoryRememberStore is a RememberStore keeping series in memory, for a
+// single instance or for tests.
+type MemoryRememberStore struct {
+	mu     sync.Mutex
+	series map[string]RememberToken
+}
+
+// NewMemoryRememberStore will return an empty MemoryRememberStore.
+func NewMemoryRememberStore() *MemoryRememberStore {
+	return &MemoryRememberStore{series: map[string]RememberToken{}}
+}
+
+// Load satisfies the RememberStore interface.
+func (s *MemoryRememberStore) Load(_ context.Context, series string) (RememberToken, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.series[series]
+	if ok && !time.Now().Before(t.Expires) {
+		delete(s.series, series)
+		return RememberToken{}, false, nil
+	}
+
+	return t, ok, nil
+}
+
+// Save satisfies the RememberStore interface.
+func (s *MemoryRememberStore) Save(_ context.Context, series string, t RememberToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.series[series] = t
+	return nil
+}
+
+// Rotate satisfies the RememberStore interface.
+func (s *MemoryRememberStore) Rotate(_ context.Context, series, hash string, t RememberToken) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.series[series]
+	if !ok || current.Hash != hash {
+		return false, nil
+	}
+
+	s.series[series] = t
+	return true, nil
+}
+
+// Delete satisfies the RememberStore interface.
+func (s *MemoryRememberStore) Delete(_ context.Context, series string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.series, series)
+	return nil
+}
+
+// DeleteUser satisfies the RememberStore interface.
+func (s *MemoryRememberStore) DeleteUser(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for series, t := range s.series {
+		if t.UserID == userID {
+			delete(s.series, series)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+)
+
+type resolveCtxKey struct{}
+
+// resolution receives the outcome of resolving a request.
+type resolution struct {
+	r     *http.Request
+	route *route
+}
+
+// Resolve will return the request as the handler of the route it matches would
+// receive it, without invoking the middleware or handler: with its path
+// values set, the labels captured by MatchHost, and the prefix of its group
+// stripped. It's meant for testing handlers in isolation from their chain. It
+// ignores maintenance mode and draining, and reports false when the request
+// would be redirected or not found.
+func (m *Mux) Resolve(r *http.Request) (*http.Request, RouteInfo, bool) {
+	m.resolving.Add(1)
+	defer m.resolving.Add(-1)
+
+	res := &resolution{}
+	m.mux.ServeHTTP(discardWriter{header: http.Header{}}, r.WithContext(context.WithValue(r.Context(), resolveCtxKey{}, res)))
+	if res.route == nil {
+		return nil, RouteInfo{}, false
+	}
+
+	// Clear the resolution, so a Mux mounted on the matched route serves the
+	// request instead of resolving it.
+	req := res.r.WithContext(context.WithValue(res.r.Context(), resolveCtxKey{}, (*resolution)(nil)))
+
+	return req, res.route.info(), true
+}
+
+// resolve will record the request as the matched candidate would receive it, if
+// the request is being resolved. It reports whether it was.
+func (e *entry) resolve(r *http.Request) bool {
+	res, ok := r.Context().Value(resolveCtxKey{}).(*resolution)
+	if !ok || res == nil {
+		return false
+	}
+
+	// The resolved request outlives serving it, so its route context isn't
+	// pooled.
+	ctx, rc, _ := withRouteContext(r.Context(), false)
+	r = r.WithContext(ctx)
+	if c, req, ok := e.table.Load().dispatch(r, rc); ok {
+		res.record(c, req)
+	}
+
+	return true
+}
+
+func (res *resolution) record(c *candidate, r *http.Request) {
+	if c.stripper != nil {
+		var ok bool
+		if r, _, ok = c.stripper.strip(r, false); !ok {
+			return
+		}
+	}
+
+	res.r = r
+	res.route = c.route
+}
+
+// discardWriter is a ResponseWriter that discards what's written to it.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w discardWriter) WriteHeader(int) {}
@@ -0,0 +1,505 @@
+package mux
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type responseCacheOption func(*ResponseCache)
+
+// ResponseCache keeps the responses of the routes it wraps in a CacheStore,
+// serving them again without invoking the handler while they're fresh.
+// Register its Middleware on the routes or groups to cache.
+//
+// Only the responses to GET requests are cached, when their status is below
+// 400, other than 206 and 304, and they don't set a cookie, vary by "*", or
+// forbid it with "Cache-Control: no-store" or "private". Responses to requests
+// with Authorization are only cached when they allow it with "public",
+// "s-maxage" or "must-revalidate". A response listing fields in Vary is only
+// served to the requests with the same values of them as the one it was cached
+// for. An Age header is added to the responses served from the cache.
+// Responses are buffered while they're served, so it doesn't suit streaming. A
+// handler fails when it responds with a status of 500 or above.
+type ResponseCache struct {
+	ttl          time.Duration
+	serveStale   time.Duration
+	staleOnError time.Duration
+	key          KeyFunc
+	store        CacheStore
+
+	// refreshing holds the keys being refreshed in the background, so only
+	// one request refreshes each.
+	mu         sync.Mutex
+	refreshing map[string]struct{}
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	stale       atomic.Uint64
+	refreshes   atomic.Uint64
+	errors      atomic.Uint64
+	storeErrors atomic.Uint64
+}
+
+// ResponseCacheStats counts what a ResponseCache did with the requests it saw,
+// such as for exporting metrics.
+type ResponseCacheStats struct {
+	// Hits are the requests served a fresh response from the cache.
+	Hits uint64 `json:"hits"`
+
+	// Misses are the requests served by the handler.
+	Misses uint64 `json:"misses"`
+
+	// Stale are the requests served a stale response, while it was
+	// refreshed or because the handler failed.
+	Stale uint64 `json:"stale"`
+
+	// Refreshes are the stale responses refreshed in the background.
+	Refreshes uint64 `json:"refreshes"`
+
+	// Errors are the requests served a stale response because the handler
+	// failed.
+	Errors uint64 `json:"errors"`
+
+	// StoreErrors are the failures of the CacheStore. A response that
+	// couldn't be read from it is served by the handler.
+	StoreErrors uint64 `json:"storeErrors"`
+}
+
+// cachedResponse is a response kept by a ResponseCache.
+type cachedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+
+	// vary holds the values the request had of the fields listed by the
+	// response's Vary, so it's only served to the requests having them too.
+	vary http.Header
+}
+
+// NewResponseCache will return a ResponseCache keeping responses fresh for the
+// ttl. Unless WithCacheStore is given, they're kept in a MemoryStore of 1000.
+func NewResponseCache(ttl time.Duration, options ...responseCacheOption) *ResponseCache {
+	if ttl <= 0 {
+		panic("ttl must be positive")
+	}
+
+	c := &ResponseCache{
+		ttl:        ttl,
+		refreshing: map[string]struct{}{},
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+
+	if c.store == nil {
+		c.store = NewMemoryStore(1000)
+	}
+
+	return c
+}
+
+// WithServeStale will serve a stale response for the duration after it
+// expired, immediately, while it's refreshed in the background, like the
+// stale-while-revalidate directive of Cache-Control.
+func WithServeStale(d time.Duration) responseCacheOption {
+	return func(c *ResponseCache) {
+		c.serveStale = d
+	}
+}
+
+// WithStaleOnError will serve a stale response for the duration after it
+// expired when the handler responds with a status of 500 or above, like the
+// stale-if-error directive of Cache-Control.
+func WithStaleOnError(d time.Duration) responseCacheOption {
+	return func(c *ResponseCache) {
+		c.staleOnError = d
+	}
+}
+
+// WithCacheKey will cache the responses by the key, instead of by the host,
+// path and query of the request.
+func WithCacheKey(key KeyFunc) responseCacheOption {
+	if key == nil {
+		panic("key must not be nil")
+	}
+
+	return func(c *ResponseCache) {
+		c.key = key
+	}
+}
+
+// WithCacheStore will keep the responses in the store, such as one shared by
+// the replicas of a service. Keys are shared by everything using the store, so
+// use WithCacheKey to namespace them if it's shared with more than the cache.
+func WithCacheStore(store CacheStore) responseCacheOption {
+	if store == nil {
+		panic("store must not be nil")
+	}
+
+	return func(c *ResponseCache) {
+		c.store = store
+	}
+}
+
+// Stats will return the counts of the ResponseCache.
+func (c *ResponseCache) Stats() ResponseCacheStats {
+	return ResponseCacheStats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Stale:       c.stale.Load(),
+		Refreshes:   c.refreshes.Load(),
+		Errors:      c.errors.Load(),
+		StoreErrors: c.storeErrors.Load(),
+	}
+}
+
+// Middleware will serve the requests of the routes it wraps from the cache.
+func (c *ResponseCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := c.cacheKey(r)
+		cached, ok := c.get(r.Context(), key)
+		if !ok || !cached.matches(r) {
+			c.fetch(next, w, r, key, nil)
+			return
+		}
+
+		age := time.Since(cached.stored)
+		switch {
+		case age < c.ttl:
+			c.hits.Add(1)
+			cached.writeTo(w, age)
+		case age < c.ttl+c.serveStale:
+			c.stale.Add(1)
+			cached.writeTo(w, age)
+			if c.startRefresh(key) {
+				c.refreshes.Add(1)
+				// The request is detached before the handler returns,
+				// while its route context is still the request's own.
+				go c.refresh(next, r.Clone(detachRouteContext(context.WithoutCancel(r.Context()))), key)
+			}
+		default:
+			c.fetch(next, w, r, key, cached)
+		}
+	})
+}
+
+// fetch will serve the request with the handler, caching its response. If it
+// fails and the stale response may be served instead, it is.
+func (c *ResponseCache) fetch(next http.Handler, w http.ResponseWriter, r *http.Request, key string, stale *cachedResponse) {
+	buf := newBufferedResponse()
+	next.ServeHTTP(buf, r)
+
+	if stale != nil && buf.status >= http.StatusInternalServerError {
+		if age := time.Since(stale.stored); age < c.ttl+c.staleOnError {
+			c.errors.Add(1)
+			c.stale.Add(1)
+			stale.writeTo(w, age)
+			return
+		}
+	}
+
+	c.misses.Add(1)
+	c.put(r, key, buf)
+	buf.writeTo(w)
+}
+
+// refresh will replace the stale response with a new one from the handler,
+// keeping the stale one if the handler fails. It's run in the background,
+// with the request detached from the one being served.
+func (c *ResponseCache) refresh(next http.Handler, r *http.Request, key string) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.refreshing, key)
+		c.mu.Unlock()
+	}()
+
+	// There's no one left to pass a panic on to, the stale response is kept
+	// instead.
+	defer func() { _ = recover() }()
+
+	buf := newBufferedResponse()
+	next.ServeHTTP(buf, r)
+	if buf.status >= http.StatusInternalServerError {
+		return
+	}
+
+	c.put(r, key, buf)
+}
+
+// startRefresh reports whether the key should be refreshed, because it isn't
+// being refreshed already.
+func (c *ResponseCache) startRefresh(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.refreshing[key]; ok {
+		return false
+	}
+
+	c.refreshing[key] = struct{}{}
+	return true
+}
+
+func (c *ResponseCache) cacheKey(r *http.Request) string {
+	if c.key != nil {
+		return c.key(r)
+	}
+
+	return r.Host + r.URL.RequestURI()
+}
+
+func (c *ResponseCache) get(ctx context.Context, key string) (*cachedResponse, bool) {
+	b, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		c.storeErrors.Add(1)
+		return nil, false
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	cached, ok := decodeCachedResponse(b)
+	if !ok {
+		c.storeErrors.Add(1)
+	}
+
+	return cached, ok
+}
+
+// put will cache the response to the request, if it can be. It's kept for as
+// long as it may be served stale.
+func (c *ResponseCache) put(r *http.Request, key string, buf *bufferedResponse) {
+	if !cacheable(r, buf.status, buf.header) {
+		return
+	}
+
+	var vary http.Header
+	for _, field := range varyFields(buf.header.Values("Vary")) {
+		if vary == nil {
+			vary = http.Header{}
+		}
+		vary[field] = r.Header.Values(field)
+	}
+
+	status := buf.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	cached := &cachedResponse{
+		status: status,
+		header: buf.header,
+		body:   buf.body.Bytes(),
+		stored: time.Now(),
+		vary:   vary,
+	}
+
+	ttl := c.ttl + max(c.serveStale, c.staleOnError)
+	if err := c.store.Set(r.Context(), key, cached.encode(), ttl); err != nil {
+		c.storeErrors.Add(1)
+	}
+}
+
+// cacheable reports whether the response to the request may be kept in a cache
+// shared by all clients. A response to a request with Authorization is only
+// kept if its Cache-Control allows it, as public, s-maxage or must-revalidate.
+func cacheable(r *http.Request, status int, h http.Header) bool {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if status >= http.StatusBadRequest || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+
+	if _, ok := h["Set-Cookie"]; ok {
+		return false
+	}
+
+	for _, field := range varyFields(h.Values("Vary")) {
+		if field == "*" {
+			return false
+		}
+	}
+
+	shared := false
+	for _, value := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(directive, "=")
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "no-store", "private":
+				return false
+			case "public", "s-maxage", "must-revalidate":
+				shared = true
+			}
+		}
+	}
+
+	if _, ok := r.Header["Authorization"]; ok && !shared {
+		return false
+	}
+
+	return true
+}
+
+// matches reports whether the request has the values of the fields the cached
+// response varies by that the request it was cached for had.
+func (cr *cachedResponse) matches(r *http.Request) bool {
+	for field, values := range cr.vary {
+		if !slices.Equal(r.Header.Values(field), values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeTo will write the cached response to w, with its age.
+func (cr *cachedResponse) writeTo(w http.ResponseWriter, age time.Duration) {
+	h := w.Header()
+	for key, values := range cr.header {
+		h[key] = append([]string(nil), values...)
+	}
+	h.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+
+	w.WriteHeader(cr.status)
+	w.Write(cr.body)
+}
+
+// cachedVersion is the first byte of an encoded cachedResponse, so the format
+// can change without misreading what a shared store holds.
+const cachedVersion = 2
+
+// encode will return the response as it's kept in a CacheStore: its version,
+// the time it was stored, its status, its header, the request's values of the
+// fields it varies by, and its body.
+func (cr *cachedResponse) encode() []byte {
+	size := 1 + 4*binary.MaxVarintLen64 + len(cr.body)
+	for _, h := range []http.Header{cr.header, cr.vary} {
+		for key, values := range h {
+			size += 2*binary.MaxVarintLen64 + len(key)
+			for _, v := range values {
+				size += binary.MaxVarintLen64 + len(v)
+			}
+		}
+	}
+
+	b := make([]byte, 0, size)
+	b = append(b, cachedVersion)
+	b = binary.AppendVarint(b, cr.stored.UnixNano())
+	b = binary.AppendUvarint(b, uint64(cr.status))
+	b = appendHeader(b, cr.header)
+	b = appendHeader(b, cr.vary)
+
+	return append(b, cr.body...)
+}
+
+func appendHeader(b []byte, h http.Header) []byte {
+	b = binary.AppendUvarint(b, uint64(len(h)))
+	for key, values := range h {
+		b = appendString(b, key)
+		b = binary.AppendUvarint(b, uint64(len(values)))
+		for _, v := range values {
+			b = appendString(b, v)
+		}
+	}
+
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// decodeCachedResponse will decode a response encoded by encode, reporting
+// false when it's malformed or of another version.
+func decodeCachedResponse(b []byte) (*cachedResponse, bool) {
+	d := cacheDecoder{b: b, ok: true}
+	if len(d.b) == 0 || d.b[0] != cachedVersion {
+		return nil, false
+	}
+	d.b = d.b[1:]
+
+	stored := d.varint()
+	cr := &cachedResponse{
+		stored: time.Unix(0, stored),
+		status: int(d.uvarint()),
+		header: d.header(),
+		vary:   d.header(),
+	}
+
+	if !d.ok {
+		return nil, false
+	}
+
+	cr.body = d.b
+	return cr, true
+}
+
+// cacheDecoder reads an encoded cachedResponse, clearing ok once it runs out.
+type cacheDecoder struct {
+	b  []byte
+	ok bool
+}
+
+func (d *cacheDecoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	d.advance(n)
+	return v
+}
+
+func (d *cacheDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	d.advance(n)
+	return v
+}
+
+func (d *cacheDecoder) advance(n int) {
+	if n <= 0 {
+		d.ok = false
+		return
+	}
+
+	d.b = d.b[n:]
+}
+
+func (d *cacheDecoder) header() http.Header {
+	h := http.Header{}
+	for n := d.uvarint(); n > 0 && d.ok; n-- {
+		key := d.string()
+		var values []string
+		for m := d.uvarint(); m > 0 && d.ok; m-- {
+			values = append(values, d.string())
+		}
+		h[key] = values
+	}
+
+	return h
+}
+
+func (d *cacheDecoder) string() string {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.ok = false
+		return ""
+	}
+
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
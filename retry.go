@@ -0,0 +1,302 @@
+package mux
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxRetryBody is the largest request body a Proxy keeps to send again when
+// retrying.
+const maxRetryBody = 64 << 10
+
+// retryPolicy holds the configuration of the retries of a Proxy.
+type retryPolicy struct {
+	retries    int
+	base, max  time.Duration
+	tryTimeout time.Duration
+	statuses   map[int]bool
+	budget     *retryBudget
+}
+
+// WithRetries will retry a failed request to the upstream up to the number of
+// times, if it's idempotent: a GET, HEAD, OPTIONS, TRACE, PUT or DELETE
+// request, or a request with an Idempotency-Key or X-Idempotency-Key header.
+// A request is retried when the upstream couldn't be reached, or responded
+// with a 502, 503 or 504. A request body is only sent again if it's at most
+// 64KB with a known length. Retries wait with an exponential backoff from
+// 50ms to 1s, and may be at most a fifth of the requests, so a struggling
+// upstream isn't overwhelmed by them.
+func WithRetries(n int) proxyOption {
+	if n < 1 {
+		panic("retries must be at least 1")
+	}
+
+	return func(p *proxy) {
+		p.retryPolicy().retries = n
+	}
+}
+
+// WithRetryBackoff will wait from base, doubling up to max, with jitter,
+// between attempts.
+func WithRetryBackoff(base, max time.Duration) proxyOption {
+	if base <= 0 || max < base {
+		panic("backoff must be positive, with max at least base")
+	}
+
+	return func(p *proxy) {
+		rp := p.retryPolicy()
+		rp.base, rp.max = base, max
+	}
+}
+
+// WithTryTimeout will limit how long each attempt may take, including reading
+// the response body, so a slow attempt can be retried within the
+// WithUpstreamTimeout of the whole request.
+func WithTryTimeout(d time.Duration) proxyOption {
+	if d <= 0 {
+		panic("try timeout must be positive")
+	}
+
+	return func(p *proxy) {
+		p.retryPolicy().tryTimeout = d
+	}
+}
+
+// WithRetryStatuses will retry the responses with the statuses, instead of
+// 502, 503 and 504.
+func WithRetryStatuses(statuses ...int) proxyOption {
+	return func(p *proxy) {
+		rp := p.retryPolicy()
+		rp.statuses = map[int]bool{}
+		for _, status := range statuses {
+			rp.statuses[status] = true
+		}
+	}
+}
+
+// WithRetryBudget will limit the retries to the ratio of the requests, from 0
+// to 1, instead of a fifth. A reserve of 10 retries is always allowed.
+func WithRetryBudget(ratio float64) proxyOption {
+	if ratio < 0 || ratio > 1 {
+		panic("retry budget must be between 0 and 1")
+	}
+
+	return func(p *proxy) {
+		p.retryPolicy().budget = newRetryBudget(ratio)
+	}
+}
+
+// retryPolicy will return the retry policy of the proxy, with the defaults if
+// it has none yet. Only WithRetries enables retrying.
+func (p *proxy) retryPolicy() *retryPolicy {
+	if p.retry == nil {
+		p.retry = &retryPolicy{
+			base: 50 * time.Millisecond,
+			max:  time.Second,
+			statuses: map[int]bool{
+				http.StatusBadGateway:         true,
+				http.StatusServiceUnavailable: true,
+				http.StatusGatewayTimeout:     true,
+			},
+			budget: newRetryBudget(0.2),
+		}
+	}
+
+	return p.retry
+}
+
+// buffer will keep the body of the request so it can be sent again, if it's
+// going to be retried.
+func (rp *retryPolicy) buffer(r *http.Request) {
+	if rp.retries == 0 || !idempotent(r) || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+
+	if r.ContentLength <= 0 || r.ContentLength > maxRetryBody {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	r.Body.Close()
+	if err != nil {
+		// Pass on what was read along with the error, the attempt fails
+		// the same as it would have.
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+// transport will return the RoundTripper retrying the requests made with next.
+func (rp *retryPolicy) transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &retryTransport{policy: rp, next: next}
+}
+
+type retryTransport struct {
+	policy *retryPolicy
+	next   http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rp := t.policy
+	rp.budget.deposit()
+
+	retryable := rp.retries > 0 && idempotent(r) && (r.Body == nil || r.Body == http.NoBody || r.GetBody != nil)
+	for attempt := 0; ; attempt++ {
+		resp, err := t.try(r)
+		last := !retryable || attempt == rp.retries || r.Context().Err() != nil
+		if err == nil && !rp.statuses[resp.StatusCode] {
+			return resp, nil
+		}
+
+		if last || !rp.budget.withdraw() {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxRetryBody))
+			resp.Body.Close()
+		}
+
+		if !sleep(r.Context(), rp.backoff(attempt)) {
+			return nil, r.Context().Err()
+		}
+
+		if r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = r.Clone(r.Context())
+			r.Body = body
+		}
+	}
+}
+
+// try will make one attempt, limited by the try timeout until its response
+// body is closed.
+func (t *retryTransport) try(r *http.Request) (*http.Response, error) {
+	if t.policy.tryTimeout == 0 {
+		return t.next.RoundTrip(r)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), t.policy.tryTimeout)
+	resp, err := t.next.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff will return how long to wait before the attempt after the one
+// given, with full jitter.
+func (rp *retryPolicy) backoff(attempt int) time.Duration {
+	d := rp.base << attempt
+	if d > rp.max || d <= 0 {
+		d = rp.max
+	}
+
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// idempotent reports whether the request can be sent again, like the
+// http.Transport decides it.
+func idempotent(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	_, key := r.Header["Idempotency-Key"]
+	_, xKey := r.Header["X-Idempotency-Key"]
+	return key || xKey
+}
+
+// sleep will wait for the duration, and report false if the context ended
+// first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// retryBudget limits the retries to a ratio of the requests. Every request
+// deposits the ratio, and every retry withdraws one, down to nothing.
+type retryBudget struct {
+	ratio float64
+
+	mu     sync.Mutex
+	tokens float64
+}
+
+// retryReserve is the number of retries a budget allows before any requests
+// are seen, and the most it keeps.
+const retryReserve = 10
+
+func newRetryBudget(ratio float64) *retryBudget {
+	return &retryBudget{ratio: ratio, tokens: retryReserve}
+}
+
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += b.ratio
+	if b.tokens > retryReserve {
+		b.tokens = retryReserve
+	}
+}
+
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// cancelBody cancels the context of an attempt once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,240 @@
+package mux
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// route holds the configuration of a route being registered, as set by the
+// options found in its middleware.
+type route struct {
+	pattern     string
+	host        string
+	keepPrefix  bool
+	matchers    []matcher
+	deprecation *Deprecation
+	docs        []Doc
+	errors      *ErrorHandler
+
+	// eh is the ErrorHandler of the mux or group the route was registered
+	// on, for middleware responding with an error.
+	eh         *ErrorHandler
+	methods    []string
+	middleware []string
+	hits       atomic.Uint64
+
+	// roles, permissions and scopes are required of the requests' Identity
+	// by the Authorize middleware, which sets authorized when it's in the
+	// chain.
+	roles       []string
+	permissions []string
+	scopes      []string
+	authorized  bool
+
+	// priority orders the route among the routes sharing its pattern, set by
+	// Priority, and order is its position among them once it's registered.
+	priority int
+	order    atomic.Int32
+
+	// aliasOf is the canonical pattern of a route registered by Alias.
+	aliasOf string
+
+	// internal is set when the route only serves the requests trusted by the
+	// internal policy of the mux.
+	internal bool
+
+	// strict is set when the route's pattern must be well-formed, by Strict.
+	strict bool
+
+	// site is where the route was registered, for the problems found with
+	// it.
+	site string
+
+	// registered are called once the route is registered, with its final
+	// pattern, and not at all if it's rejected.
+	registered []func()
+}
+
+// errorHandler will return the ErrorHandler middleware of the route responds
+// to errors with: that of its ErrHandlerFunc, or else of where it was
+// registered.
+func (rt *route) errorHandler() *ErrorHandler {
+	if rt.errors != nil {
+		return rt.errors
+	}
+
+	if rt.eh != nil {
+		return rt.eh
+	}
+
+	return DefaultErrorHandler
+}
+
+// routeOption is implemented by the handlers returned from middleware that
+// configure the route they're registered on.
+type routeOption interface {
+	http.Handler
+	configure(*route)
+}
+
+// optionHandler is returned by middleware that only configure the route. It's
+// removed from the chain once the route is configured, so it costs nothing
+// per request.
+type optionHandler struct {
+	http.Handler
+	apply func(*route)
+}
+
+func (o *optionHandler) configure(rt *route) {
+	o.apply(rt)
+}
+
+// option will return middleware that configures the route it's registered on
+// instead of wrapping the handler.
+func option(apply func(*route)) Middleware {
+	return func(next http.Handler) http.Handler {
+		return &optionHandler{Handler: next, apply: apply}
+	}
+}
+
+// wrapRoute will wrap the handler in the middleware like WrapMiddleware, and
+// configure the route with the options found along the way. Options are
+// applied from the outermost inwards, so the route's own options override
+// those of its groups and the mux.
+func wrapRoute(rt *route, mw []Middleware, handler http.Handler) http.Handler {
+	var options []routeOption
+	names := make([]string, 0, len(mw))
+	for i := len(mw) - 1; i >= 0; i-- {
+		h := mw[i]
+		if h == nil {
+			continue
+		}
+
+		handler = h(handler)
+		if opt, ok := handler.(routeOption); ok {
+			options = append(options, opt)
+			if o, ok := opt.(*optionHandler); ok {
+				handler = o.Handler
+				continue
+			}
+		}
+
+		if n, ok := handler.(*namedHandler); ok {
+			// Named middleware that configures the route, such as Named
+			// Authorize, still does.
+			if opt, ok := n.Handler.(routeOption); ok {
+				options = append(options, opt)
+			}
+			handler = n.Handler
+			names = append(names, n.name)
+			continue
+		}
+
+		names = append(names, middlewareName(h))
+	}
+
+	for i := len(options) - 1; i >= 0; i-- {
+		options[i].configure(rt)
+	}
+
+	rt.middleware = make([]string, len(names))
+	for i, name := range names {
+		rt.middleware[len(names)-1-i] = name
+	}
+
+	return handler
+}
+
+// namedHandler is returned by Named middleware, carrying the name to the route
+// being registered. It's removed from the chain once the name is recorded.
+type namedHandler struct {
+	http.Handler
+	name string
+}
+
+// Named will return the middleware with a name, reported for every route it
+// wraps by Routes instead of the name of the function implementing it. It
+// costs nothing per request.
+func Named(name string, mw Middleware) Middleware {
+	if len(name) == 0 {
+		panic("name must not be empty")
+	}
+
+	if mw == nil {
+		panic("middleware must not be nil")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return &namedHandler{Handler: mw(next), name: name}
+	}
+}
+
+// middlewareName will return the name of the function implementing the
+// middleware, such as "mux.Deprecated" or "main.requireAdmin", without its
+// package path or the suffixes of closures. It's used for middleware that
+// isn't Named.
+func middlewareName(mw Middleware) string {
+	return funcName(mw)
+}
+
+// funcNames caches the names found by funcName, since the same middleware is
+// usually registered on many routes.
+var funcNames sync.Map
+
+// funcName will return the name of the function like middlewareName.
+func funcName(f any) string {
+	pc := reflect.ValueOf(f).Pointer()
+	if name, ok := funcNames.Load(pc); ok {
+		return name.(string)
+	}
+
+	name := resolveFuncName(pc)
+	funcNames.Store(pc, name)
+
+	return name
+}
+
+func resolveFuncName(pc uintptr) string {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return "unknown"
+	}
+
+	// Method values, such as the Middleware method of a Bulkhead, are named
+	// with a "-fm" suffix.
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	for {
+		i := strings.LastIndex(name, ".")
+		if i < 0 || !isClosureSuffix(name[i+1:]) {
+			break
+		}
+		name = name[:i]
+	}
+
+	return name
+}
+
+// isClosureSuffix reports whether the element of a function name was added by
+// the compiler for a closure, such as "func1" or "1".
+func isClosureSuffix(elem string) bool {
+	elem = strings.TrimPrefix(elem, "func")
+	if elem == "" {
+		return false
+	}
+
+	for _, r := range elem {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
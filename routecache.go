@@ -0,0 +1,161 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheRoutes will cache the routes matched by the recently requested paths, up
+// to the size, so the http.ServeMux isn't searched again for them. It suits
+// services where most requests are for a handful of paths. Only the patterns
+// without wildcards are cached, since the http.ServeMux must match the others
+// to set their path values. The cache is cleared whenever a route is
+// registered. A size of zero or less disables it.
+func (m *Mux) CacheRoutes(size int) {
+	if size <= 0 {
+		m.routeCache.Store(nil)
+		return
+	}
+
+	m.routeCache.Store(newRouteCache(size))
+}
+
+// serveCached will serve the request with the cached route for it, or with the
+// http.ServeMux, caching the route it matches.
+func (m *Mux) serveCached(c *routeCache, w http.ResponseWriter, r *http.Request) {
+	if r.RequestURI == "*" {
+		m.mux.ServeHTTP(w, r)
+		return
+	}
+
+	key := routeCacheKey{method: r.Method, host: r.Host, path: r.URL.Path, rawPath: r.URL.RawPath}
+	item, ok := c.get(key)
+	if !ok {
+		gen := c.generation()
+		item = &routeCacheItem{key: key}
+		h, pattern := m.mux.Handler(r)
+		if e, isEntry := h.(*entry); isEntry && !strings.Contains(pattern, "{") {
+			item.entry = e
+		}
+		c.add(item, gen)
+
+		// Without wildcards, the handler is served as the http.ServeMux
+		// would, without matching the request again.
+		if !strings.Contains(pattern, "{") {
+			setPattern(r, pattern)
+			h.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	// Patterns with wildcards are cached as having no entry, so they're
+	// matched once, by the http.ServeMux setting their path values.
+	if item.entry == nil {
+		m.mux.ServeHTTP(w, r)
+		return
+	}
+
+	setPattern(r, item.entry.pattern)
+	item.entry.ServeHTTP(w, r)
+}
+
+type routeCacheKey struct {
+	method, host, path, rawPath string
+}
+
+// routeCache caches the entries matched by requests, evicting by the clock
+// algorithm: a hit only marks its item as referenced, under a read lock, and
+// adding to a full cache evicts the first item the clock's hand finds
+// unreferenced since it last passed, clearing the marks it passes. Hits never
+// wait on each other, and the items requested often stay cached.
+type routeCache struct {
+	size int
+
+	mu      sync.RWMutex
+	entries map[routeCacheKey]*routeCacheItem
+	ring    []*routeCacheItem
+	hand    int
+
+	// gen counts the times the cache was cleared, so an entry looked up
+	// before a route was registered isn't added after the cache was cleared.
+	gen uint64
+}
+
+// routeCacheItem is the entry a request matched, or nil if its pattern has
+// wildcards and it must be served by the http.ServeMux.
+type routeCacheItem struct {
+	key        routeCacheKey
+	entry      *entry
+	referenced atomic.Bool
+}
+
+func newRouteCache(size int) *routeCache {
+	return &routeCache{
+		size:    size,
+		entries: make(map[routeCacheKey]*routeCacheItem, size),
+		ring:    make([]*routeCacheItem, 0, size),
+	}
+}
+
+func (c *routeCache) get(key routeCacheKey) (*routeCacheItem, bool) {
+	c.mu.RLock()
+	item, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if ok && !item.referenced.Load() {
+		item.referenced.Store(true)
+	}
+
+	return item, ok
+}
+
+func (c *routeCache) generation() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.gen
+}
+
+// add will cache the item, unless the cache was cleared since the generation
+// the item was looked up in.
+func (c *routeCache) add(item *routeCacheItem, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gen != gen {
+		return
+	}
+
+	if _, ok := c.entries[item.key]; ok {
+		return
+	}
+
+	if len(c.ring) < c.size {
+		c.ring = append(c.ring, item)
+		c.entries[item.key] = item
+		return
+	}
+
+	for c.ring[c.hand].referenced.Load() {
+		c.ring[c.hand].referenced.Store(false)
+		c.hand = (c.hand + 1) % len(c.ring)
+	}
+
+	delete(c.entries, c.ring[c.hand].key)
+	c.ring[c.hand] = item
+	c.entries[item.key] = item
+	c.hand = (c.hand + 1) % len(c.ring)
+}
+
+func (c *routeCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.ring)
+	c.ring = c.ring[:0]
+	clear(c.entries)
+	c.hand = 0
+	c.gen++
+}
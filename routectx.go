@@ -0,0 +1,98 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type routeCtxKey struct{}
+
+// routeContext holds what the Mux records about a request as it's routed: the
+// path before a Group stripped its prefix, and the host labels captured by
+// MatchHost. It's added to the request's context once, with a single key, by
+// whichever needs it first, and returned to a pool once the request has been
+// served. Routes recording nothing never get one. The values must not be read
+// once the route's handler has returned, so a goroutine it starts must be
+// given a context from detachRouteContext.
+type routeContext struct {
+	originalPath    string
+	hasOriginalPath bool
+
+	// hostParams holds the captured host labels as name and value pairs,
+	// since there are only ever a few.
+	hostParams []string
+}
+
+var routeContexts = sync.Pool{
+	New: func() any {
+		return &routeContext{}
+	},
+}
+
+// withRouteContext will return the context with a route context, if it doesn't
+// already have one, such as when a Mux is mounted on the route of another.
+// When pooled, the new route context is taken from the pool and returned, to
+// be released once the request has been served.
+func withRouteContext(ctx context.Context, pooled bool) (context.Context, *routeContext, *routeContext) {
+	if rc, ok := ctx.Value(routeCtxKey{}).(*routeContext); ok {
+		return ctx, rc, nil
+	}
+
+	if !pooled {
+		rc := &routeContext{}
+		return context.WithValue(ctx, routeCtxKey{}, rc), rc, nil
+	}
+
+	rc := routeContexts.Get().(*routeContext)
+	return context.WithValue(ctx, routeCtxKey{}, rc), rc, rc
+}
+
+// detachRouteContext will return the context with a copy of its route context,
+// if it has one, for work that outlives the request such as a goroutine
+// started by middleware. The copy is never pooled, so it can be read and
+// recorded to after the request's own route context is released.
+func detachRouteContext(ctx context.Context) context.Context {
+	rc, ok := ctx.Value(routeCtxKey{}).(*routeContext)
+	if !ok {
+		return ctx
+	}
+
+	detached := &routeContext{
+		originalPath:    rc.originalPath,
+		hasOriginalPath: rc.hasOriginalPath,
+		hostParams:      append([]string(nil), rc.hostParams...),
+	}
+
+	return context.WithValue(ctx, routeCtxKey{}, detached)
+}
+
+// release will return the route context to the pool, if it came from it.
+func (rc *routeContext) release() {
+	if rc == nil {
+		return
+	}
+
+	*rc = routeContext{hostParams: rc.hostParams[:0]}
+	routeContexts.Put(rc)
+}
+
+func routeContextOf(r *http.Request) *routeContext {
+	rc, _ := r.Context().Value(routeCtxKey{}).(*routeContext)
+	return rc
+}
+
+func (rc *routeContext) setHostParam(name, value string) {
+	rc.hostParams = append(rc.hostParams, name, value)
+}
+
+func (rc *routeContext) hostParam(name string) string {
+	// Search from the end, so the labels captured by the innermost Mux win.
+	for i := len(rc.hostParams) - 2; i >= 0; i -= 2 {
+		if rc.hostParams[i] == name {
+			return rc.hostParams[i+1]
+		}
+	}
+
+	return ""
+}
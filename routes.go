@@ -0,0 +1,182 @@
+package mux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes a route registered on a Mux.
+type RouteInfo struct {
+	// Pattern is the full pattern the route was registered with on the
+	// http.ServeMux, including the prefixes of its groups and its host.
+	Pattern string `json:"pattern"`
+
+	// Methods are the methods of the route's Methods gate, sorted, or those
+	// the method of its pattern serves. It's empty when the route accepts any
+	// method.
+	Methods []string `json:"methods,omitempty"`
+
+	// Middleware are the names of the middleware wrapping the route, in the
+	// order they're envoked, including those of the mux and its groups.
+	Middleware []string `json:"middleware,omitempty"`
+
+	// Roles are the roles the route requires of the Identity of requests,
+	// any one of which is enough, as set by RequireRole.
+	Roles []string `json:"roles,omitempty"`
+
+	// Permissions are the permissions the route requires of the Identity of
+	// requests, every one of them, as set by RequirePermission.
+	Permissions []string `json:"permissions,omitempty"`
+
+	// Scopes are the OAuth scopes the route requires of the Identity of
+	// requests, every one of them, as set by RequireScope.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Priority is the priority of the route among the routes sharing its
+	// pattern, as set by Priority.
+	Priority int `json:"priority,omitempty"`
+
+	// Order is the position the route is tried in among the routes sharing
+	// its pattern, from 1, when some of them are constrained. It's zero for
+	// a route whose pattern is its own.
+	Order int `json:"order,omitempty"`
+
+	// AliasOf is the canonical pattern of the route when it was registered
+	// as an alias of it by Alias.
+	AliasOf string `json:"aliasOf,omitempty"`
+
+	// Internal is set when the route was marked Internal.
+	Internal bool `json:"internal,omitempty"`
+
+	// Deprecation is set when the route was registered as Deprecated.
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
+
+	// Docs are the Docs the route was registered with by Describe, outermost
+	// first.
+	Docs []Doc `json:"-"`
+
+	// ErrorHandler handles the errors of the route if it was registered as an
+	// ErrHandlerFunc, and is nil otherwise.
+	ErrorHandler *ErrorHandler `json:"-"`
+
+	// Hits is the number of requests the route has served. It's left out of
+	// WriteRoutes, so the output stays deterministic.
+	Hits uint64 `json:"-"`
+}
+
+// HasMiddleware reports whether the route is wrapped in the middleware with the
+// name.
+func (ri RouteInfo) HasMiddleware(name string) bool {
+	for _, mw := range ri.Middleware {
+		if mw == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RoutesFormat is a format WriteRoutes can write the routes in.
+type RoutesFormat int
+
+const (
+	// RoutesText writes a line per route with its pattern, methods, and
+	// middleware aligned in columns.
+	RoutesText RoutesFormat = iota
+
+	// RoutesJSON writes an indented JSON array of RouteInfo.
+	RoutesJSON
+)
+
+// Routes will return the routes registered on the Mux, in the order they were
+// registered. Registrations reported by Validate as rejected are left out.
+func (m *Mux) Routes() []RouteInfo {
+	registered := m.routes()
+	routes := make([]RouteInfo, 0, len(registered))
+	for _, rt := range registered {
+		routes = append(routes, rt.info())
+	}
+
+	return routes
+}
+
+// WriteRoutes will write the routes of the Mux in the format, sorted by
+// pattern so the output is deterministic, and those sharing a pattern in the
+// order they're tried. It's suitable for golden files that make route changes
+// show up in code review.
+func (m *Mux) WriteRoutes(w io.Writer, format RoutesFormat) error {
+	routes := m.Routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+
+		return routes[i].Order < routes[j].Order
+	})
+
+	switch format {
+	case RoutesText:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, rt := range routes {
+			methods := strings.Join(rt.Methods, ",")
+			if methods == "" {
+				methods = "*"
+			}
+
+			line := rt.Pattern + "\t" + methods + "\t" + strings.Join(rt.Middleware, ",")
+			if rt.Deprecation != nil {
+				line += "\tdeprecated"
+			}
+
+			if rt.Internal {
+				line += "\tinternal"
+			}
+
+			if rt.AliasOf != "" {
+				line += "\talias of " + rt.AliasOf
+			}
+
+			if _, err := fmt.Fprintln(tw, strings.TrimRight(line, "\t")); err != nil {
+				return err
+			}
+		}
+		return tw.Flush()
+
+	case RoutesJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(routes)
+
+	default:
+		return fmt.Errorf("mux: unknown routes format %d", format)
+	}
+}
+
+func (rt *route) info() RouteInfo {
+	info := RouteInfo{
+		Pattern:      rt.pattern,
+		Methods:      append([]string(nil), rt.methods...),
+		Middleware:   append([]string(nil), rt.middleware...),
+		Roles:        append([]string(nil), rt.roles...),
+		Permissions:  append([]string(nil), rt.permissions...),
+		Scopes:       append([]string(nil), rt.scopes...),
+		Priority:     rt.priority,
+		Order:        int(rt.order.Load()),
+		AliasOf:      rt.aliasOf,
+		Internal:     rt.internal,
+		Docs:         append([]Doc(nil), rt.docs...),
+		ErrorHandler: rt.errors,
+		Hits:         rt.hits.Load(),
+	}
+
+	if rt.deprecation != nil {
+		d := *rt.deprecation
+		info.Deprecation = &d
+	}
+
+	return info
+}
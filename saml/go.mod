@@ -0,0 +1,18 @@
+module github.com/kevinfalting/mux/saml
+
+go 1.22
+
+require (
+	github.com/crewjam/saml v0.4.14
+	github.com/kevinfalting/mux v0.0.0
+)
+
+require (
+	github.com/beevik/etree v1.1.0 // indirect
+	github.com/jonboulle/clockwork v0.2.2 // indirect
+	github.com/mattermost/xml-roundtrip-validator v0.1.0 // indirect
+	github.com/russellhaering/goxmldsig v1.3.0 // indirect
+	golang.org/x/crypto v0.14.0 // indirect
+)
+
+replace github.com/kevinfalting/mux => ../
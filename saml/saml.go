@@ -0,0 +1,372 @@
+/*
+Package saml provides a SAML 2.0 service provider for the mux, logging users in
+with an identity provider into the mux.Sessions of the service. It lives in its
+own module so the mux package doesn't depend on an XML signature library.
+
+	sp := saml.New("https://app.example.com/saml/", key, cert, idpMetadata)
+	sessions := mux.NewSessions(store)
+	sp.Mount(m.Group("/saml/", nil, sessions.Middleware))
+	m.Handle("GET /account", account, sessions.Middleware, sp.RequireLogin)
+
+The identity provider is given the metadata served under the group's prefix,
+and posts its responses to the assertion consumer service there. Responses are
+validated by their signature, audience, destination, and validity window, and
+must answer a login started by the service provider unless WithIDPInitiated.
+*/
+package saml
+
+import (
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	gosaml "github.com/crewjam/saml"
+	"github.com/kevinfalting/mux"
+)
+
+// ErrInvalidResponse is the error the assertion consumer service responds
+// with when the identity provider's response doesn't validate.
+var ErrInvalidResponse = errors.New("saml: invalid response")
+
+// ErrNoSession is the error the assertion consumer service responds with when
+// it's mounted without the Middleware of mux.Sessions in their chain.
+var ErrNoSession = errors.New("saml: no session, mount the handlers with the Middleware of mux.Sessions")
+
+// trackingTTL is how long a login started by the service provider may take to
+// be answered by the identity provider.
+const trackingTTL = 10 * time.Minute
+
+// maxResponseSize limits the form the identity provider posts to the assertion
+// consumer service.
+const maxResponseSize = 1 << 20
+
+type spOption func(*ServiceProvider)
+
+// ServiceProvider logs users in with a SAML identity provider, establishing
+// the user of their mux.Session once the identity provider's response
+// validates.
+//
+// The session cookie isn't sent with the response the identity provider posts
+// from its own site, since it's SameSite=Lax, so the login being answered is
+// tracked by a short-lived cookie of its own, signed with a key derived from
+// the service provider's.
+type ServiceProvider struct {
+	sp       gosaml.ServiceProvider
+	base     *url.URL
+	cookie   string
+	trackKey []byte
+	redirect string
+	user     func(r *http.Request, a *gosaml.Assertion) (string, error)
+}
+
+// New will return a ServiceProvider served under the base URL, which must be
+// absolute and end with a slash, signing its requests with the key and
+// certificate, for the identity provider of the metadata. The user of a
+// session is the NameID of the assertion the user logged in with.
+func New(baseURL string, key *rsa.PrivateKey, cert *x509.Certificate, idp *gosaml.EntityDescriptor, options ...spOption) *ServiceProvider {
+	base, err := url.Parse(baseURL)
+	if err != nil || !base.IsAbs() || !strings.HasSuffix(base.Path, "/") {
+		panic("base URL must be absolute and end with a slash")
+	}
+
+	if key == nil || cert == nil {
+		panic("key and certificate must not be nil")
+	}
+
+	if idp == nil {
+		panic("identity provider metadata must not be nil")
+	}
+
+	mac := hmac.New(sha256.New, []byte("mux/saml tracking"))
+	mac.Write(x509.MarshalPKCS1PrivateKey(key))
+
+	p := &ServiceProvider{
+		sp: gosaml.ServiceProvider{
+			Key:         key,
+			Certificate: cert,
+			MetadataURL: *base.JoinPath("metadata"),
+			AcsURL:      *base.JoinPath("acs"),
+			IDPMetadata: idp,
+		},
+		base:     base,
+		cookie:   "saml_request",
+		trackKey: mac.Sum(nil),
+		redirect: "/",
+		user:     nameID,
+	}
+
+	for _, opt := range options {
+		opt(p)
+	}
+
+	return p
+}
+
+// WithUser will establish sessions for the user the function returns for the
+// validated assertion, instead of its NameID, such as to look up or provision
+// the account of one of its attributes.
+func WithUser(fn func(r *http.Request, a *gosaml.Assertion) (string, error)) spOption {
+	if fn == nil {
+		panic("user function must not be nil")
+	}
+
+	return func(p *ServiceProvider) {
+		p.user = fn
+	}
+}
+
+// WithIDPInitiated will accept responses the identity provider sends
+// unsolicited, such as when the user starts from its portal. They're
+// redirected to the default redirect once logged in.
+func WithIDPInitiated() spOption {
+	return func(p *ServiceProvider) {
+		p.sp.AllowIDPInitiated = true
+	}
+}
+
+// WithDefaultRedirect will redirect users logged in without a path to return
+// to, to the path instead of "/".
+func WithDefaultRedirect(path string) spOption {
+	if !localPath(path) {
+		panic("redirect must be a path on this host")
+	}
+
+	return func(p *ServiceProvider) {
+		p.redirect = path
+	}
+}
+
+// WithEntityID will identify the service provider to the identity provider by
+// the ID, instead of the URL of its metadata.
+func WithEntityID(id string) spOption {
+	if len(id) == 0 {
+		panic("entity ID must not be empty")
+	}
+
+	return func(p *ServiceProvider) {
+		p.sp.EntityID = id
+	}
+}
+
+// Mount will register the handlers of the service provider on the group, whose
+// prefix must be the path of the base URL:
+//
+//   - GET metadata serves the service provider's metadata.
+//   - GET login redirects to the identity provider to log in, returning to the
+//     path of the "return" parameter afterward.
+//   - POST acs is the assertion consumer service the identity provider posts
+//     its responses to.
+//
+// The group's middleware must include that of the mux.Sessions.
+func (p *ServiceProvider) Mount(g *mux.Group) {
+	if g.Prefix() != p.base.Path {
+		panic(fmt.Sprintf("group prefix %q must be the path of the base URL %q", g.Prefix(), p.base.Path))
+	}
+
+	g.HandleFunc("GET /metadata", p.metadata)
+	g.HandleErr("GET /login", p.login)
+	g.HandleErr("POST /acs", p.acs)
+}
+
+// RequireLogin will redirect GET and HEAD requests without a logged in user to
+// the login of the service provider, returning to the requested URL. Other
+// requests are responded to with a 401.
+func (p *ServiceProvider) RequireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sess := mux.SessionOf(r); sess != nil && sess.UserID() != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		login := p.base.JoinPath("login")
+		login.RawQuery = url.Values{"return": {r.URL.RequestURI()}}.Encode()
+		http.Redirect(w, r, login.RequestURI(), http.StatusFound)
+	})
+}
+
+// Metadata will return the metadata of the service provider, for configuring
+// it with the identity provider.
+func (p *ServiceProvider) Metadata() *gosaml.EntityDescriptor {
+	return p.sp.Metadata()
+}
+
+func (p *ServiceProvider) metadata(w http.ResponseWriter, r *http.Request) {
+	b, err := xml.MarshalIndent(p.sp.Metadata(), "", "  ")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = w.Write(b)
+}
+
+func (p *ServiceProvider) login(w http.ResponseWriter, r *http.Request) error {
+	ret := r.URL.Query().Get("return")
+	if !localPath(ret) {
+		ret = p.redirect
+	}
+
+	sso := p.sp.GetSSOBindingLocation(gosaml.HTTPRedirectBinding)
+	if sso == "" {
+		return mux.Error(errors.New("saml: identity provider has no SSO location for the redirect binding"), http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	req, err := p.sp.MakeAuthenticationRequest(sso, gosaml.HTTPRedirectBinding, gosaml.HTTPPostBinding)
+	if err != nil {
+		return mux.Error(err, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	to, err := req.Redirect("", &p.sp)
+	if err != nil {
+		return mux.Error(err, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	p.track(w, req.ID, ret)
+	http.Redirect(w, r, to.String(), http.StatusFound)
+	return nil
+}
+
+func (p *ServiceProvider) acs(w http.ResponseWriter, r *http.Request) error {
+	sess := mux.SessionOf(r)
+	if sess == nil {
+		return mux.Error(ErrNoSession, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxResponseSize)
+	if err := r.ParseForm(); err != nil {
+		return mux.Error(err, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+	}
+
+	var ids []string
+	ret := p.redirect
+	if id, path, ok := p.tracked(r); ok {
+		ids, ret = []string{id}, path
+	}
+
+	assertion, err := p.sp.ParseResponse(r, ids)
+	if err != nil {
+		// The reason is kept from the response, so it doesn't help forging
+		// one, but it's wrapped for the ErrorHandler to log.
+		var invalid *gosaml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		return mux.Error(errors.Join(ErrInvalidResponse, err), http.StatusForbidden, http.StatusText(http.StatusForbidden))
+	}
+
+	userID, err := p.user(r, assertion)
+	if err != nil {
+		return err
+	}
+
+	if userID == "" {
+		return mux.Error(errors.Join(ErrInvalidResponse, errors.New("saml: no user for the assertion")), http.StatusForbidden, http.StatusText(http.StatusForbidden))
+	}
+
+	sess.SetUser(userID)
+	p.untrack(w)
+	http.Redirect(w, r, ret, http.StatusSeeOther)
+	return nil
+}
+
+func nameID(_ *http.Request, a *gosaml.Assertion) (string, error) {
+	if a.Subject == nil || a.Subject.NameID == nil {
+		return "", nil
+	}
+
+	return a.Subject.NameID.Value, nil
+}
+
+// track will set the cookie tracking the login request of the ID, for the
+// assertion consumer service to validate the response to it, and redirect to
+// the path afterward. It's SameSite=None so it's sent with the identity
+// provider's post, and only to the assertion consumer service.
+func (p *ServiceProvider) track(w http.ResponseWriter, id, path string) {
+	expires := strconv.FormatInt(time.Now().Add(trackingTTL).Unix(), 10)
+	payload := enc(id) + "." + enc(path) + "." + expires
+	http.SetCookie(w, &http.Cookie{
+		Name:     p.cookie,
+		Value:    payload + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload)),
+		Path:     p.sp.AcsURL.Path,
+		MaxAge:   int(trackingTTL / time.Second),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+func (p *ServiceProvider) untrack(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     p.cookie,
+		Path:     p.sp.AcsURL.Path,
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+// tracked will return the ID of the login request tracked for the request and
+// the path to redirect to, reporting false if there's none or its signature
+// doesn't match or it has expired.
+func (p *ServiceProvider) tracked(r *http.Request) (string, string, bool) {
+	c, err := r.Cookie(p.cookie)
+	if err != nil {
+		return "", "", false
+	}
+
+	parts := strings.Split(c.Value, ".")
+	if len(parts) != 4 {
+		return "", "", false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil || !hmac.Equal(sig, p.sign(strings.Join(parts[:3], "."))) {
+		return "", "", false
+	}
+
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return "", "", false
+	}
+
+	id, err1 := base64.RawURLEncoding.DecodeString(parts[0])
+	path, err2 := base64.RawURLEncoding.DecodeString(parts[1])
+	if err1 != nil || err2 != nil || !localPath(string(path)) {
+		return "", "", false
+	}
+
+	return string(id), string(path), true
+}
+
+func (p *ServiceProvider) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, p.trackKey)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func enc(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// localPath reports whether the path stays on this host, so it's safe to
+// redirect to.
+func localPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.ContainsAny(path, "\\\r\n")
+}
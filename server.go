@@ -0,0 +1,466 @@
+package mux
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type serverOption func(*Server)
+
+// Server wraps an http.Server serving a Mux, and coordinates shutting down the
+// server with draining the Mux. The same Mux can be served on several
+// listeners at once, each with its own middleware.
+type Server struct {
+	mux       *Mux
+	server    *http.Server
+	listeners []*serverListener
+
+	// conns tracks the connections of the http.Server, for Connections.
+	conns connTracker
+
+	// admins are the admin Muxes served on their own listeners, as by
+	// WithAdmin.
+	admins []*Mux
+
+	onStart         []func(context.Context) error
+	onShutdown      []func(context.Context) error
+	shutdownTimeout time.Duration
+
+	// fcgi holds the bound FastCGI listeners, which are closed by Shutdown
+	// since the http.Server doesn't know about them.
+	fcgiMu  sync.Mutex
+	fcgi    []net.Listener
+	closing atomic.Bool
+
+	// clientCAs verify the certificates of clients when set, as by
+	// WithClientCerts.
+	clientCAs  *x509.CertPool
+	clientAuth tls.ClientAuthType
+
+	// err is a failure of an option to set the server up, returned when it
+	// starts.
+	err error
+}
+
+// serverListener is a listener the Server will serve the Mux on. If listener
+// is set, it's served instead of listening on the network address.
+type serverListener struct {
+	network  string
+	address  string
+	listener net.Listener
+	handler  http.Handler
+	tls      bool
+	fcgi     bool
+	admin    bool
+	bound    net.Addr
+}
+
+type listenerCtxKey struct{}
+
+// NewServer will return a Server that serves the Mux on addr. If addr is empty
+// the Mux is only served on the listeners provided with options such as
+// WithListener.
+func NewServer(addr string, m *Mux, options ...serverOption) *Server {
+	s := &Server{
+		mux:             m,
+		server:          &http.Server{},
+		shutdownTimeout: 30 * time.Second,
+	}
+
+	for _, opt := range options {
+		opt(s)
+	}
+
+	if addr != "" {
+		s.listeners = append([]*serverListener{{network: "tcp", address: addr, handler: m}}, s.listeners...)
+	}
+
+	s.applyClientCerts()
+	s.server.Addr = addr
+	s.server.Handler = http.HandlerFunc(s.serveHTTP)
+	if s.clientCAs != nil {
+		identify := ClientCertIdentity()
+		s.server.Handler = identify(s.server.Handler)
+	}
+
+	baseContext := s.server.BaseContext
+	s.server.BaseContext = func(l net.Listener) context.Context {
+		ctx := context.Background()
+		if baseContext != nil {
+			ctx = baseContext(l)
+		}
+
+		return context.WithValue(ctx, listenerCtxKey{}, l.Addr())
+	}
+
+	connState := s.server.ConnState
+	s.server.ConnState = func(c net.Conn, state http.ConnState) {
+		s.conns.track(c, state)
+		if connState != nil {
+			connState(c, state)
+		}
+	}
+
+	return s
+}
+
+// WithHTTPServer will use the provided http.Server for its configuration, such
+// as timeouts and TLS settings. The Addr and Handler are always replaced by
+// those given to NewServer, and any BaseContext and ConnState are wrapped.
+func WithHTTPServer(srv *http.Server) serverOption {
+	if srv == nil {
+		panic("server must not be nil")
+	}
+
+	return func(s *Server) {
+		s.server = srv
+	}
+}
+
+// WithListener will additionally serve the Mux on the network address, wrapped
+// in the provided middleware. The network is anything accepted by net.Listen,
+// such as "tcp" or "unix". Use the middleware to tailor what each listener
+// exposes, such as only allowing admin endpoints on a unix socket.
+func WithListener(network, address string, mw ...Middleware) serverOption {
+	if len(network) == 0 || len(address) == 0 {
+		panic("network and address must not be empty")
+	}
+
+	return func(s *Server) {
+		s.listeners = append(s.listeners, &serverListener{
+			network: network,
+			address: address,
+			handler: WrapMiddleware(mw, s.mux),
+		})
+	}
+}
+
+// WithTLSListener will additionally serve the Mux over TLS on the network
+// address, wrapped in the provided middleware. The certificates come from the
+// server's TLS config, see WithTLSConfig. Listeners added with WithListener
+// stay plain HTTP unless ListenAndServeTLS is used.
+func WithTLSListener(network, address string, mw ...Middleware) serverOption {
+	if len(network) == 0 || len(address) == 0 {
+		panic("network and address must not be empty")
+	}
+
+	return func(s *Server) {
+		s.listeners = append(s.listeners, &serverListener{
+			network: network,
+			address: address,
+			handler: WrapMiddleware(mw, s.mux),
+			tls:     true,
+		})
+	}
+}
+
+// WithNetListener will additionally serve the Mux on a listener that's
+// already open, wrapped in the provided middleware, such as one inherited
+// through systemd socket activation. The Server closes it on shutdown.
+func WithNetListener(l net.Listener, mw ...Middleware) serverOption {
+	if l == nil {
+		panic("listener must not be nil")
+	}
+
+	return func(s *Server) {
+		s.listeners = append(s.listeners, &serverListener{
+			listener: l,
+			handler:  WrapMiddleware(mw, s.mux),
+		})
+	}
+}
+
+// WithFastCGI will additionally serve the Mux over FastCGI on the network
+// address, wrapped in the provided middleware, for deployments behind web
+// servers such as Apache or nginx that speak FastCGI to their backends.
+func WithFastCGI(network, address string, mw ...Middleware) serverOption {
+	if len(network) == 0 || len(address) == 0 {
+		panic("network and address must not be empty")
+	}
+
+	return func(s *Server) {
+		s.listeners = append(s.listeners, &serverListener{
+			network: network,
+			address: address,
+			handler: WrapMiddleware(mw, s.mux),
+			fcgi:    true,
+		})
+	}
+}
+
+// WithFastCGIListener will additionally serve the Mux over FastCGI on a
+// listener that's already open, wrapped in the provided middleware, such as
+// the socket a web server passes to the FastCGI processes it spawns.
+func WithFastCGIListener(l net.Listener, mw ...Middleware) serverOption {
+	if l == nil {
+		panic("listener must not be nil")
+	}
+
+	return func(s *Server) {
+		s.listeners = append(s.listeners, &serverListener{
+			listener: l,
+			handler:  WrapMiddleware(mw, s.mux),
+			fcgi:     true,
+		})
+	}
+}
+
+// WithTLSConfig will set the TLS config used by TLS listeners, such as one
+// using GetCertificate to provide certificates at runtime.
+func WithTLSConfig(cfg *tls.Config) serverOption {
+	if cfg == nil {
+		panic("tls config must not be nil")
+	}
+
+	return func(s *Server) {
+		s.server.TLSConfig = cfg
+	}
+}
+
+// WithShutdownTimeout will set how long Run waits for the server to shut down
+// after receiving a signal. The default is 30 seconds.
+func WithShutdownTimeout(d time.Duration) serverOption {
+	return func(s *Server) {
+		s.shutdownTimeout = d
+	}
+}
+
+// ListenerAddr will return the address of the Server listener that accepted
+// the request's connection.
+func ListenerAddr(ctx context.Context) (net.Addr, bool) {
+	addr, ok := ctx.Value(listenerCtxKey{}).(net.Addr)
+	return addr, ok
+}
+
+// serveHTTP will pass the request to the handler of the listener it arrived
+// on.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if addr, ok := ListenerAddr(r.Context()); ok {
+		for _, l := range s.listeners {
+			if l.matches(addr) {
+				l.handler.ServeHTTP(w, r)
+				return
+			}
+		}
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// matches reports whether addr is the address this listener is bound to.
+func (l *serverListener) matches(addr net.Addr) bool {
+	return l.bound != nil && l.bound.Network() == addr.Network() && l.bound.String() == addr.String()
+}
+
+// ListenAndServe will listen on every configured address and serve the Mux.
+// Like http.Server, it always returns a non-nil error, and returns
+// http.ErrServerClosed after Shutdown. The Mux is validated before listening,
+// and if serving any listener fails, the server is closed and the error
+// returned.
+func (s *Server) ListenAndServe() error {
+	return s.serve(func(sl *serverListener, l net.Listener) error {
+		if sl.tls {
+			return s.server.ServeTLS(l, "", "")
+		}
+
+		return s.server.Serve(l)
+	})
+}
+
+// ListenAndServeTLS will listen on every configured address and serve the Mux
+// over TLS using the provided certificate and key files.
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	return s.serve(func(_ *serverListener, l net.Listener) error {
+		return s.server.ServeTLS(l, certFile, keyFile)
+	})
+}
+
+func (s *Server) serve(serve func(*serverListener, net.Listener) error) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if len(s.listeners) == 0 {
+		return errors.New("mux: server has no address or listeners")
+	}
+
+	if err := s.mux.Validate(); err != nil {
+		return err
+	}
+
+	for _, admin := range s.admins {
+		if err := admin.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, hook := range s.onStart {
+		if err := hook(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	listeners := make([]net.Listener, 0, len(s.listeners))
+	for _, sl := range s.listeners {
+		l := sl.listener
+		if l == nil {
+			var err error
+			if l, err = net.Listen(sl.network, sl.address); err != nil {
+				for _, l := range listeners {
+					l.Close()
+				}
+				return err
+			}
+		}
+
+		sl.bound = l.Addr()
+		listeners = append(listeners, l)
+	}
+
+	errs := make(chan error, len(listeners))
+	for i, l := range listeners {
+		sl := s.listeners[i]
+		if sl.fcgi {
+			s.fcgiMu.Lock()
+			s.fcgi = append(s.fcgi, l)
+			s.fcgiMu.Unlock()
+
+			go func(sl *serverListener, l net.Listener) {
+				errs <- s.serveFastCGI(sl, l)
+			}(sl, l)
+			continue
+		}
+
+		go func(sl *serverListener, l net.Listener) {
+			errs <- serve(sl, l)
+		}(sl, l)
+	}
+
+	// The listeners are bound, so connections are accepted from here on even
+	// if the goroutines serving them haven't started yet.
+	SystemdNotify("READY=1")
+
+	err := <-errs
+	if !errors.Is(err, http.ErrServerClosed) {
+		s.server.Close()
+		s.closeFastCGI()
+	}
+
+	return err
+}
+
+// serveFastCGI will serve the listener's handler over FastCGI. The FastCGI
+// server has no BaseContext, so the listener's address is added to each
+// request's context here.
+func (s *Server) serveFastCGI(sl *serverListener, l net.Listener) error {
+	addr := l.Addr()
+	err := fcgi.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), listenerCtxKey{}, addr)
+		sl.handler.ServeHTTP(w, r.WithContext(ctx))
+	}))
+
+	if s.closing.Load() {
+		return http.ErrServerClosed
+	}
+
+	return err
+}
+
+// closeFastCGI will close the FastCGI listeners, which stops serving them.
+// Requests already being served aren't interrupted.
+func (s *Server) closeFastCGI() {
+	s.closing.Store(true)
+
+	s.fcgiMu.Lock()
+	defer s.fcgiMu.Unlock()
+
+	for _, l := range s.fcgi {
+		l.Close()
+	}
+	s.fcgi = nil
+}
+
+// OnStart will register a hook that's run before the server starts listening,
+// such as priming caches. Hooks run in the order they were registered, and the
+// first error stops the server from starting and is returned from
+// ListenAndServe.
+func (s *Server) OnStart(hook func(ctx context.Context) error) {
+	s.onStart = append(s.onStart, hook)
+}
+
+// OnShutdown will register a hook that's run by Shutdown once the server has
+// stopped serving requests, such as flushing metrics. Hooks run in the reverse
+// order they were registered, like deferred calls, and all of them are run
+// even if one returns an error.
+func (s *Server) OnShutdown(hook func(ctx context.Context) error) {
+	s.onShutdown = append(s.onShutdown, hook)
+}
+
+// Shutdown will gracefully stop the server. The Mux is drained first, so
+// requests arriving during shutdown are turned away while the outstanding
+// ones finish, then the FastCGI listeners are closed and the underlying
+// http.Server is shut down, and finally the OnShutdown hooks are run. Every
+// step shares the deadline of the provided context, and the first error
+// encountered is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	SystemdNotify("STOPPING=1")
+
+	err := s.mux.Drain(ctx)
+	s.closeFastCGI()
+
+	if shutdownErr := s.server.Shutdown(ctx); shutdownErr != nil && err == nil {
+		err = shutdownErr
+	}
+
+	for i := len(s.onShutdown) - 1; i >= 0; i-- {
+		if hookErr := s.onShutdown[i](ctx); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+
+	return err
+}
+
+// Run will serve the Mux until the context is canceled or the process receives
+// SIGINT or SIGTERM, then gracefully shut the server down, waiting at most the
+// shutdown timeout. It returns nil after a clean shutdown.
+func (s *Server) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Restore the default signal behavior, so a second signal kills the
+	// process if shutting down is taking too long.
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	err := s.Shutdown(shutdownCtx)
+	if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) && err == nil {
+		err = serveErr
+	}
+
+	return err
+}
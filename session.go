@@ -0,0 +1,403 @@
+package mux
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type sessionCtxKey struct{}
+
+// ErrSessionStore is the error a request is responded to with when its
+// session can't be loaded from or saved to the SessionStore.
+var ErrSessionStore = errors.New("mux: session store failed")
+
+// SessionRecord is a session as it's kept by a SessionStore.
+type SessionRecord struct {
+	// UserID is the user the session was established for, or empty before
+	// they log in.
+	UserID string `json:"userId,omitempty"`
+
+	// Values are the values kept in the session.
+	Values map[string]string `json:"values,omitempty"`
+
+	// Expires is when the session expires, unless it's saved again.
+	Expires time.Time `json:"expires"`
+}
+
+// SessionStore keeps the sessions of Sessions by their IDs. Implementations
+// must be safe for concurrent use. The MemorySessionStore keeps them in
+// memory, and the redis and sqlstore modules keep them where the replicas of
+// a service can share them.
+type SessionStore interface {
+	// Load will return the session of the ID, reporting false when it's
+	// missing or has expired.
+	Load(ctx context.Context, id string) (SessionRecord, bool, error)
+
+	// Save will keep the session under the ID until it expires, replacing
+	// any session it had.
+	Save(ctx context.Context, id string, s SessionRecord) error
+
+	// Delete will remove the session of the ID, if it's present.
+	Delete(ctx context.Context, id string) error
+
+	// DeleteUser will remove every session of the user, such as when their
+	// password changes.
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+type sessionOption func(*Sessions)
+
+// Sessions keeps the state of clients across requests in a SessionStore, by
+// the ID of their session in a cookie. Its Middleware loads the session of
+// every request, available from SessionOf, and saves it before the response's
+// header is written if it was changed.
+//
+// The ID of a session is rotated whenever its user changes with SetUser, or
+// with Rotate, so an ID captured before a login or a change of privilege is
+// of no use afterward.
+type Sessions struct {
+	store    SessionStore
+	cookie   string
+	ttl      time.Duration
+	insecure bool
+}
+
+// NewSessions will return the Sessions kept in the store, in a cookie named
+// "session", expiring 24 hours after they were last saved.
+func NewSessions(store SessionStore, options ...sessionOption) *Sessions {
+	if store == nil {
+		panic("store must not be nil")
+	}
+
+	s := &Sessions{store: store, cookie: "session", ttl: 24 * time.Hour}
+	for _, opt := range options {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithSessionCookie will keep the ID of sessions in the cookie of the name.
+func WithSessionCookie(name string) sessionOption {
+	if len(name) == 0 {
+		panic("cookie name must not be empty")
+	}
+
+	return func(s *Sessions) {
+		s.cookie = name
+	}
+}
+
+// WithSessionTTL will expire sessions the duration after they were last saved,
+// instead of 24 hours.
+func WithSessionTTL(d time.Duration) sessionOption {
+	if d <= 0 {
+		panic("ttl must be positive")
+	}
+
+	return func(s *Sessions) {
+		s.ttl = d
+	}
+}
+
+// WithInsecureSessionCookie will send the session cookie over plain HTTP too,
+// such as for local development. It's only sent over HTTPS otherwise.
+func WithInsecureSessionCookie() sessionOption {
+	return func(s *Sessions) {
+		s.insecure = true
+	}
+}
+
+// InvalidateUser will end every session of the user, such as when their
+// password changes or their account is disabled.
+func (s *Sessions) InvalidateUser(ctx context.Context, userID string) error {
+	return s.store.DeleteUser(ctx, userID)
+}
+
+// Middleware will load the session of requests, responding with a 500 of
+// ErrSessionStore by the route's ErrorHandler if the store fails.
+func (s *Sessions) Middleware(next http.Handler) http.Handler {
+	return &sessionHandler{sessions: s, next: next, eh: DefaultErrorHandler}
+}
+
+type sessionHandler struct {
+	sessions *Sessions
+	next     http.Handler
+	eh       *ErrorHandler
+}
+
+func (h *sessionHandler) configure(rt *route) {
+	h.eh = rt.errorHandler()
+}
+
+func (h *sessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sess, err := h.sessions.load(r)
+	if err != nil {
+		h.eh.respond(w, r, Error(err, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)))
+		return
+	}
+
+	sw := &sessionWriter{ResponseWriter: w, r: r, sess: sess, eh: h.eh}
+	h.next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), sessionCtxKey{}, sess)))
+	if !sw.committed {
+		sw.commit()
+	}
+}
+
+// SessionOf will return the session of the request, or nil if it wasn't
+// served by the Middleware of Sessions.
+func SessionOf(r *http.Request) *Session {
+	sess, _ := r.Context().Value(sessionCtxKey{}).(*Session)
+	return sess
+}
+
+func (s *Sessions) load(r *http.Request) (*Session, error) {
+	sess := &Session{sessions: s, values: map[string]string{}}
+	c, err := r.Cookie(s.cookie)
+	if err != nil || c.Value == "" {
+		return sess, nil
+	}
+
+	rec, ok, err := s.store.Load(r.Context(), c.Value)
+	if err != nil {
+		return nil, errors.Join(ErrSessionStore, err)
+	}
+
+	if !ok || !time.Now().Before(rec.Expires) {
+		// The cookie is cleared once the response is written.
+		sess.stale = true
+		return sess, nil
+	}
+
+	sess.id, sess.userID = c.Value, rec.UserID
+	for k, v := range rec.Values {
+		sess.values[k] = v
+	}
+
+	return sess, nil
+}
+
+// Session is the state of a client kept by Sessions. It's safe for concurrent
+// use, but changes made once the response's header was written aren't saved.
+type Session struct {
+	sessions *Sessions
+
+	mu     sync.Mutex
+	id     string
+	userID string
+	values map[string]string
+
+	// dirty is set when the session must be saved, rotate when it must get a
+	// new ID, destroy when it must be deleted, and stale when the client
+	// sent the ID of a session that's gone.
+	dirty   bool
+	rotate  bool
+	destroy bool
+	stale   bool
+}
+
+// Get will return the value of the key, or an empty string if it's unset.
+func (sess *Session) Get(key string) string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.values[key]
+}
+
+// Set will set the value of the key.
+func (sess *Session) Set(key, value string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.values[key] = value
+	sess.dirty = true
+}
+
+// Delete will unset the key.
+func (sess *Session) Delete(key string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	delete(sess.values, key)
+	sess.dirty = true
+}
+
+// UserID will return the user the session was established for, or an empty
+// string before they log in.
+func (sess *Session) UserID() string {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	return sess.userID
+}
+
+// SetUser will establish the session for the user, such as once they log in,
+// rotating its ID.
+func (sess *Session) SetUser(userID string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.userID = userID
+	sess.dirty, sess.rotate = true, true
+}
+
+// Rotate will give the session a new ID, such as when the privileges of its
+// user change. The session under the old ID is deleted.
+func (sess *Session) Rotate() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.dirty, sess.rotate = true, true
+}
+
+// Destroy will end the session, such as when the user logs out, deleting it
+// from the store and clearing the cookie.
+func (sess *Session) Destroy() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	sess.destroy = true
+}
+
+// save will apply the changes of the session to the store and the cookie.
+func (sess *Session) save(w http.ResponseWriter, r *http.Request) error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	s := sess.sessions
+	ctx := r.Context()
+	switch {
+	case sess.destroy:
+		if sess.id != "" {
+			if err := s.store.Delete(ctx, sess.id); err != nil {
+				return err
+			}
+		}
+		sess.id, sess.userID, sess.values = "", "", map[string]string{}
+		s.setCookie(w, "", -1)
+		return nil
+
+	case !sess.dirty:
+		if sess.stale {
+			s.setCookie(w, "", -1)
+		}
+		return nil
+	}
+
+	old := sess.id
+	if sess.id == "" || sess.rotate {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		sess.id = id
+	}
+
+	values := make(map[string]string, len(sess.values))
+	for k, v := range sess.values {
+		values[k] = v
+	}
+
+	rec := SessionRecord{UserID: sess.userID, Values: values, Expires: time.Now().Add(s.ttl)}
+	if err := s.store.Save(ctx, sess.id, rec); err != nil {
+		return err
+	}
+
+	if old != "" && old != sess.id {
+		if err := s.store.Delete(ctx, old); err != nil {
+			return err
+		}
+	}
+
+	sess.dirty, sess.rotate = false, false
+	s.setCookie(w, sess.id, int(s.ttl/time.Second))
+	return nil
+}
+
+func (s *Sessions) setCookie(w http.ResponseWriter, id string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.cookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   !s.insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sessionWriter saves the session just before the response's header is
+// written, so its cookie can still be set. If saving fails, the response is
+// replaced with a 500 of ErrSessionStore.
+type sessionWriter struct {
+	http.ResponseWriter
+	r         *http.Request
+	sess      *Session
+	eh        *ErrorHandler
+	committed bool
+	failed    bool
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	if code >= 200 && !w.committed && !w.commit() {
+		return
+	}
+
+	if w.failed {
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	if !w.committed && !w.commit() {
+		return len(b), nil
+	}
+
+	if w.failed {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *sessionWriter) Flush() {
+	if !w.committed && !w.commit() {
+		return
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// commit will save the session, reporting false if it failed and the 500 was
+// written instead.
+func (w *sessionWriter) commit() bool {
+	w.committed = true
+	if err := w.sess.save(w.ResponseWriter, w.r); err != nil {
+		w.failed = true
+		w.eh.respond(w.ResponseWriter, w.r, Error(errors.Join(ErrSessionStore, err), http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)))
+		return false
+	}
+
+	return true
+}
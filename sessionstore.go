@@ -0,0 +1,111 @@
+package mux
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemorySessionStore is a SessionStore keeping sessions in memory, for a
+// single instance or for tests. Expired sessions are removed as they're
+// loaded, and by Prune.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]SessionRecord
+	users    map[string]map[string]struct{}
+}
+
+// NewMemorySessionStore will return an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: map[string]SessionRecord{},
+		users:    map[string]map[string]struct{}{},
+	}
+}
+
+// Load satisfies the SessionStore interface.
+func (s *MemorySessionStore) Load(_ context.Context, id string) (SessionRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.sessions[id]
+	if !ok {
+		return SessionRecord{}, false, nil
+	}
+
+	if !time.Now().Before(rec.Expires) {
+		s.remove(id)
+		return SessionRecord{}, false, nil
+	}
+
+	return rec, true, nil
+}
+
+// Save satisfies the SessionStore interface.
+func (s *MemorySessionStore) Save(_ context.Context, id string, rec SessionRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(id)
+	s.sessions[id] = rec
+	if rec.UserID != "" {
+		ids, ok := s.users[rec.UserID]
+		if !ok {
+			ids = map[string]struct{}{}
+			s.users[rec.UserID] = ids
+		}
+		ids[id] = struct{}{}
+	}
+
+	return nil
+}
+
+// Delete satisfies the SessionStore interface.
+func (s *MemorySessionStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(id)
+	return nil
+}
+
+// DeleteUser satisfies the SessionStore interface.
+func (s *MemorySessionStore) DeleteUser(_ context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.users[userID] {
+		delete(s.sessions, id)
+	}
+	delete(s.users, userID)
+
+	return nil
+}
+
+// Prune will remove the sessions that expired, such as on a ticker.
+func (s *MemorySessionStore) Prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, rec := range s.sessions {
+		if !now.Before(rec.Expires) {
+			s.remove(id)
+		}
+	}
+}
+
+func (s *MemorySessionStore) remove(id string) {
+	rec, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+
+	delete(s.sessions, id)
+	if ids := s.users[rec.UserID]; ids != nil {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(s.users, rec.UserID)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package mux
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// callSite will return the file and line of the code outside this package
+// that's registering a route, such as "main.go:42", or an empty string if
+// there's none.
+func callSite() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !inPackage(frame.Function) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
+
+// inPackage reports whether the function, as named by runtime.Frame, is of this
+// package rather than of a package importing it, such as the saml module.
+func inPackage(function string) bool {
+	rest, ok := strings.CutPrefix(function, "github.com/kevinfalting/mux.")
+	return ok && !strings.Contains(rest, "/")
+}
+
+// shadowing will panic, with where both were registered, when the route and one
+// registered before it are registered for the same method and path through
+// different means: one with the method in its pattern, and the other by a
+// Methods gate on the path without one. The http.ServeMux prefers the pattern
+// with the method, so the gate's handler for it would never be reached.
+func (m *Mux) shadowing(rt *route) {
+	method, path := splitMethod(rt.pattern)
+	if m.paths == nil {
+		m.paths = map[string][]*route{}
+	}
+
+	for _, other := range m.paths[path] {
+		if otherMethod, _ := splitMethod(other.pattern); (method == "") == (otherMethod == "") {
+			continue
+		}
+
+		explicit, gate := rt, other
+		if method == "" {
+			explicit, gate = other, rt
+		}
+
+		if gate.methods == nil {
+			continue
+		}
+
+		for _, mm := range explicit.methods {
+			if contains(gate.methods, mm) {
+				panic(fmt.Sprintf("mux: pattern %q: %s (registered at %s) shadows %s of the Methods gate of %q (registered at %s)",
+					rt.pattern, explicit.pattern, explicit.site, mm, gate.pattern, gate.site))
+			}
+		}
+	}
+
+	m.paths[path] = append(m.paths[path], rt)
+}
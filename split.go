@@ -0,0 +1,90 @@
+package mux
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+)
+
+// Splitter routes a percentage of the requests to a canary handler, and the
+// rest to the stable one. It's returned by Split.
+type Splitter struct {
+	stable, canary http.Handler
+	key            KeyFunc
+
+	// basisPoints is the percentage of requests for the canary, in
+	// hundredths of a percent.
+	basisPoints atomic.Uint32
+}
+
+// Split will return a handler routing the percentage of the requests, from 0
+// to 100, to the canary and the rest to the stable handler. With stickiness,
+// requests with the same key always go to the same handler while the
+// percentage is unchanged, and raising it only moves more keys to the canary,
+// so a user isn't switched back and forth. Requests without a key, or all of
+// them if stickiness is nil, are routed at random.
+func Split(stable, canary http.Handler, percent float64, stickiness KeyFunc) *Splitter {
+	if stable == nil || canary == nil {
+		panic("handlers must not be nil")
+	}
+
+	s := &Splitter{stable: stable, canary: canary, key: stickiness}
+	s.SetPercent(percent)
+
+	return s
+}
+
+// SetPercent will change the percentage of requests routed to the canary. It's
+// safe to call while the Splitter is serving requests.
+func (s *Splitter) SetPercent(percent float64) {
+	if percent < 0 || percent > 100 {
+		panic("percent must be between 0 and 100")
+	}
+
+	s.basisPoints.Store(uint32(percent*100 + 0.5))
+}
+
+// Percent will return the percentage of requests routed to the canary.
+func (s *Splitter) Percent() float64 {
+	return float64(s.basisPoints.Load()) / 100
+}
+
+// ServeHTTP satisfies the handler interface.
+func (s *Splitter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.isCanary(r) {
+		s.canary.ServeHTTP(w, r)
+		return
+	}
+
+	s.stable.ServeHTTP(w, r)
+}
+
+func (s *Splitter) isCanary(r *http.Request) bool {
+	bp := s.basisPoints.Load()
+	switch bp {
+	case 0:
+		return false
+	case 10000:
+		return true
+	}
+
+	return bucket(s.key, r) < bp
+}
+
+// bucket will return the bucket of the request, from 0 to 9999, by the hash
+// of its key, or at random if it has none.
+func bucket(key KeyFunc, r *http.Request) uint32 {
+	var k string
+	if key != nil {
+		k = key(r)
+	}
+
+	if k == "" {
+		return uint32(rand.Intn(10000))
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(k))
+	return h.Sum32() % 10000
+}
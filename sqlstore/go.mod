@@ -0,0 +1,7 @@
+module github.com/kevinfalting/mux/sqlstore
+
+go 1.22
+
+require github.com/kevinfalting/mux v0.0.0
+
+replace github.com/kevinfalting/mux => ../
@@ -0,0 +1,182 @@
+/*
+Package sqlstore provides a mux.SessionStore kept in a database/sql database,
+so the replicas of a service share sessions. It lives in its own module so the
+mux package doesn't carry it, and it doesn't depend on any driver.
+
+	store := sqlstore.NewSessionStore(db, sqlstore.WithDollarPlaceholders())
+	if err := store.CreateTable(ctx); err != nil {
+		log.Fatal(err)
+	}
+	sessions := mux.NewSessions(store)
+*/
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kevinfalting/mux"
+)
+
+type storeOption func(*SessionStore)
+
+// SessionStore is a mux.SessionStore keeping sessions in a table of their ID,
+// user ID, the JSON of the session, and when it expires in Unix nanoseconds.
+// Expired sessions are ignored, and removed by Prune.
+type SessionStore struct {
+	db     *sql.DB
+	table  string
+	dollar bool
+}
+
+var _ mux.SessionStore = (*SessionStore)(nil)
+
+// NewSessionStore will return a SessionStore using the table "mux_sessions"
+// of the database. Queries use "?" placeholders, as for SQLite and MySQL,
+// unless WithDollarPlaceholders is given.
+func NewSessionStore(db *sql.DB, options ...storeOption) *SessionStore {
+	if db == nil {
+		panic("db must not be nil")
+	}
+
+	s := &SessionStore{db: db, table: "mux_sessions"}
+	for _, opt := range options {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithTable will keep the sessions in the table of the name. The name isn't
+// quoted, so it must be a trusted identifier.
+func WithTable(name string) storeOption {
+	if len(name) == 0 {
+		panic("table name must not be empty")
+	}
+
+	return func(s *SessionStore) {
+		s.table = name
+	}
+}
+
+// WithDollarPlaceholders will write queries with "$1" placeholders, as for
+// PostgreSQL.
+func WithDollarPlaceholders() storeOption {
+	return func(s *SessionStore) {
+		s.dollar = true
+	}
+}
+
+// CreateTable will create the table and its index on the user ID, unless they
+// exist. It uses syntax shared by SQLite and PostgreSQL, other databases may
+// need the table created by a migration instead.
+func (s *SessionStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+s.table+` (
+	id VARCHAR(128) PRIMARY KEY,
+	user_id VARCHAR(255) NOT NULL,
+	data TEXT NOT NULL,
+	expires BIGINT NOT NULL
+)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS "+s.table+"_user_id ON "+s.table+" (user_id)")
+	return err
+}
+
+// Load satisfies the mux.SessionStore interface.
+func (s *SessionStore) Load(ctx context.Context, id string) (mux.SessionRecord, bool, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.query("SELECT data FROM %s WHERE id = ? AND expires > ?"), id, time.Now().UnixNano()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return mux.SessionRecord{}, false, nil
+	}
+
+	if err != nil {
+		return mux.SessionRecord{}, false, err
+	}
+
+	var rec mux.SessionRecord
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return mux.SessionRecord{}, false, err
+	}
+
+	return rec, true, nil
+}
+
+// Save satisfies the mux.SessionStore interface. The session is replaced by a
+// delete and an insert in a transaction, rather than an upsert, whose syntax
+// differs between databases.
+func (s *SessionStore) Save(ctx context.Context, id string, rec mux.SessionRecord) (err error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, s.query("DELETE FROM %s WHERE id = ?"), id); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, s.query("INSERT INTO %s (id, user_id, data, expires) VALUES (?, ?, ?, ?)"), id, rec.UserID, string(data), rec.Expires.UnixNano())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete satisfies the mux.SessionStore interface.
+func (s *SessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s WHERE id = ?"), id)
+	return err
+}
+
+// DeleteUser satisfies the mux.SessionStore interface.
+func (s *SessionStore) DeleteUser(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s WHERE user_id = ?"), userID)
+	return err
+}
+
+// Prune will remove the sessions that expired, such as on a ticker.
+func (s *SessionStore) Prune(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s WHERE expires <= ?"), time.Now().UnixNano())
+	return err
+}
+
+// query will return the query with the table in place of "%s", and its
+// placeholders in the style of the database.
+func (s *SessionStore) query(q string) string {
+	q = strings.Replace(q, "%s", s.table, 1)
+	if !s.dollar {
+		return q
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range q {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package mux
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a server-sent event, as by the HTML standard's EventSource.
+type Event struct {
+	// ID is the identifier of the event, which the client sends back in the
+	// Last-Event-ID header when it reconnects. It's omitted if empty.
+	ID string `json:"id,omitempty"`
+
+	// Event is the type of the event, dispatched to the listeners of the
+	// type. It's omitted if empty, for the "message" type.
+	Event string `json:"event,omitempty"`
+
+	// Data is the data of the event, sent as a "data" field per line.
+	Data string `json:"data"`
+
+	// Retry tells the client how long to wait before reconnecting. It's
+	// omitted if zero.
+	Retry time.Duration `json:"retry,omitempty"`
+}
+
+// sseReplacer removes the line breaks from the fields of an Event that must
+// be a single line, so they can't inject fields of their own.
+var sseReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// WriteEvent will write the event to w in the text/event-stream format. Line
+// breaks in its ID and Event are replaced by spaces. Flush w afterward for
+// the client to receive it.
+func WriteEvent(w io.Writer, e Event) error {
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + sseReplacer.Replace(e.ID) + "\n")
+	}
+
+	if e.Event != "" {
+		b.WriteString("event: " + sseReplacer.Replace(e.Event) + "\n")
+	}
+
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	data := strings.ReplaceAll(strings.ReplaceAll(e.Data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
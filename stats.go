@@ -0,0 +1,69 @@
+package mux
+
+import "reflect"
+
+// Stats describes the size of the route table of a Mux, for monitoring large
+// tables, such as those generated from specs, for bloat.
+type Stats struct {
+	// Routes is the number of registered routes.
+	Routes int `json:"routes"`
+
+	// Patterns is the number of patterns registered on the http.ServeMux.
+	// Routes sharing a pattern, constrained by matchers, count once.
+	Patterns int `json:"patterns"`
+
+	// Matchers is the number of matchers constraining the routes, such as
+	// those of MatchHost, tried for every request of their pattern.
+	Matchers int `json:"matchers"`
+
+	// Middleware is the number of middleware closures wrapping the routes.
+	// Every route has its own, so a middleware of the mux counts once per
+	// route. Options such as Describe or Named cost nothing and aren't
+	// counted.
+	Middleware int `json:"middleware"`
+
+	// Bytes is a rough estimate of the memory held by the route table: the
+	// Mux's records of the routes and patterns, and the middleware closures.
+	// The memory held by handlers, or captured by middleware, isn't known.
+	Bytes int64 `json:"bytes"`
+}
+
+// The sizes used to estimate the memory of a route table. A middleware
+// closure is assumed to hold the handler it wraps, and a pattern of the
+// http.ServeMux a few nodes of its tree.
+var (
+	routeSize     = int64(reflect.TypeOf(route{}).Size())
+	candidateSize = int64(reflect.TypeOf(candidate{}).Size())
+	entrySize     = int64(reflect.TypeOf(entry{}).Size() + reflect.TypeOf(entryTable{}).Size())
+)
+
+const (
+	closureSize      = 32
+	matcherSize      = 32
+	servemuxNodeSize = 256
+	stringHeaderSize = 16
+	pointerSize      = 8
+)
+
+// Stats will return the size of the route table of the Mux.
+func (m *Mux) Stats() Stats {
+	var s Stats
+	for _, rt := range m.routes() {
+		s.Routes++
+		s.Matchers += len(rt.matchers)
+		s.Middleware += len(rt.middleware)
+
+		s.Bytes += routeSize + candidateSize + int64(len(rt.pattern)+len(rt.host))
+		s.Bytes += int64(len(rt.matchers)) * (pointerSize + matcherSize)
+		s.Bytes += int64(len(rt.middleware)) * (stringHeaderSize + closureSize)
+		s.Bytes += int64(len(rt.methods)) * stringHeaderSize
+	}
+
+	m.entries.Range(func(key, _ any) bool {
+		s.Patterns++
+		s.Bytes += entrySize + servemuxNodeSize + int64(len(key.(string)))
+		return true
+	})
+
+	return s
+}
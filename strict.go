@@ -0,0 +1,77 @@
+package mux
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// Strict will make registering the route panic, with what's wrong, when its
+// pattern isn't strictly well-formed, instead of leaving it to the
+// http.ServeMux's leniency or to Validate. Pass it to New, or to Group, to make
+// every route registered beneath strict. A strict pattern:
+//
+//   - has an upper case method, if it has one
+//   - has a path that's clean, with no empty, "." or ".." segments
+//   - has wildcards that are whole segments, named by Go identifiers, with no
+//     name used twice, and "..." or "{$}" only in the last segment
+//   - is registered on a group whose prefix ends with a slash
+func Strict() Middleware {
+	return option(func(rt *route) {
+		rt.strict = true
+	})
+}
+
+// strictProblem will return what's wrong with the pattern registered under the
+// group prefix in strict mode, or an empty string if nothing is.
+func strictProblem(pattern, prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		return fmt.Sprintf("group prefix %q must end with a slash", prefix)
+	}
+
+	method, rest := splitMethod(pattern)
+	if method != "" && method != strings.ToUpper(method) {
+		return fmt.Sprintf("method %q must be upper case", method)
+	}
+
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return "pattern must have a path beginning with a slash"
+	}
+
+	segments := strings.Split(rest[i+1:], "/")
+	names := map[string]bool{}
+	for j, seg := range segments {
+		last := j == len(segments)-1
+		switch {
+		case seg == "" && !last:
+			return "path must not have empty segments"
+		case seg == "." || seg == "..":
+			return fmt.Sprintf("path must not have %q segments", seg)
+		}
+
+		name, ok := wildcard(seg)
+		if !ok {
+			if strings.ContainsAny(seg, "{}") {
+				return fmt.Sprintf("segment %q must be a whole wildcard, like {name}", seg)
+			}
+			continue
+		}
+
+		switch {
+		case seg == "{$}":
+			if !last {
+				return "{$} must be the last segment"
+			}
+		case !token.IsIdentifier(name):
+			return fmt.Sprintf("wildcard %q must be named by a Go identifier", seg)
+		case strings.HasSuffix(seg, "...}") && !last:
+			return fmt.Sprintf("wildcard %q must be the last segment", seg)
+		case names[name]:
+			return fmt.Sprintf("wildcard name %q is used twice", name)
+		}
+		names[name] = true
+	}
+
+	return ""
+}
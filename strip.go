@@ -0,0 +1,87 @@
+package mux
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// KeepPrefix will register the handler of a Group, or of its routes, without
+// stripping the group's prefix from the path, for handlers that need to see
+// the full original path such as reverse proxies and legacy routers.
+func KeepPrefix() Middleware {
+	return option(func(rt *route) {
+		rt.keepPrefix = true
+	})
+}
+
+// OriginalPath will return the request path before a Group stripped its prefix
+// from it. If nothing was stripped, it's the request's path. It must not be
+// called once the route's handler has returned.
+func OriginalPath(r *http.Request) string {
+	if rc := routeContextOf(r); rc != nil && rc.hasOriginalPath {
+		return rc.originalPath
+	}
+
+	return r.URL.Path
+}
+
+// stripHandler strips the prefix of the Group a handler was registered on. It
+// sits innermost in the route's chain, and is only enabled once the route's
+// options are known.
+type stripHandler struct {
+	next   http.Handler
+	prefix string
+}
+
+func newStripHandler(next http.Handler) *stripHandler {
+	return &stripHandler{next: next}
+}
+
+// enable will strip the prefix from requests to the handler.
+func (s *stripHandler) enable(prefix string) {
+	s.prefix = prefix
+}
+
+func (s *stripHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r, owned, ok := s.strip(r, true)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.next.ServeHTTP(w, r)
+	owned.release()
+}
+
+// strip will return the request with the prefix removed from its path, like
+// http.StripPrefix, recording the original path in its route context. When
+// pooled, a route context it had to add is returned to be released once the
+// request has been served. It reports false if the path doesn't have the
+// prefix.
+func (s *stripHandler) strip(r *http.Request, pooled bool) (*http.Request, *routeContext, bool) {
+	if s.prefix == "" {
+		return r, nil, true
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, s.prefix)
+	rp := strings.TrimPrefix(r.URL.RawPath, s.prefix)
+	if len(p) == len(r.URL.Path) || (r.URL.RawPath != "" && len(rp) == len(r.URL.RawPath)) {
+		return r, nil, false
+	}
+
+	ctx, rc, owned := withRouteContext(r.Context(), pooled)
+	// Keep the path seen by the outermost group, when a Mux is mounted on
+	// another one.
+	if !rc.hasOriginalPath {
+		rc.originalPath, rc.hasOriginalPath = r.URL.Path, true
+	}
+
+	r2 := r.WithContext(ctx)
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = p
+	r2.URL.RawPath = rp
+
+	return r2, owned, true
+}
@@ -0,0 +1,82 @@
+package mux
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Subdomain will return a Group whose routes only match requests for hosts
+// matching the template, where labels in braces match any label and are
+// captured, such as "{tenant}.example.com". The captured labels are available
+// to handlers with HostParam. Routes of the Group share patterns with the
+// routes of other subdomains and of the Mux, the subdomains are tried in the
+// order they were registered before the route without one.
+func (m *Mux) Subdomain(template string, mw ...Middleware) *Group {
+	return m.Group("/", nil, chain([]Middleware{MatchHost(template)}, mw)...)
+}
+
+// MatchHost will constrain the route to requests for hosts matching the
+// template, like Subdomain.
+func MatchHost(template string) Middleware {
+	labels := strings.Split(strings.ToLower(template), ".")
+	for _, label := range labels {
+		if len(label) == 0 {
+			panic("host template must not have empty labels")
+		}
+	}
+
+	return option(func(rt *route) {
+		rt.matchers = append(rt.matchers, func(r *http.Request) (*http.Request, bool) {
+			return matchHost(labels, r)
+		})
+	})
+}
+
+// HostParam will return the host label captured by a Subdomain or MatchHost
+// template, or an empty string if there is none by that name. It must not be
+// called once the route's handler has returned.
+func HostParam(r *http.Request, name string) string {
+	if rc := routeContextOf(r); rc != nil {
+		return rc.hostParam(name)
+	}
+
+	return ""
+}
+
+func matchHost(labels []string, r *http.Request) (*http.Request, bool) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	hostLabels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(hostLabels) != len(labels) {
+		return nil, false
+	}
+
+	for i, label := range labels {
+		if strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") {
+			if len(hostLabels[i]) == 0 {
+				return nil, false
+			}
+			continue
+		}
+
+		if !strings.EqualFold(label, hostLabels[i]) {
+			return nil, false
+		}
+	}
+
+	// Only capture the labels once the host is known to match, and only when
+	// the request is being dispatched rather than looked up by Match.
+	if rc := routeContextOf(r); rc != nil {
+		for i, label := range labels {
+			if strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}") {
+				rc.setHostParam(label[1:len(label)-1], strings.ToLower(hostLabels[i]))
+			}
+		}
+	}
+
+	return r, true
+}
@@ -0,0 +1,151 @@
+package mux
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Summary describes a Mux, and the listeners of the Server serving it, to be
+// logged once at startup in structured form, such as with slog, which it's a
+// slog.LogValuer for:
+//
+//	logger.Info("serving", "summary", srv.Summary())
+type Summary struct {
+	// Routes is the number of routes registered, and Patterns the number of
+	// patterns registered on the http.ServeMux for them.
+	Routes   int `json:"routes"`
+	Patterns int `json:"patterns"`
+
+	// Groups are the full prefixes of the groups, sorted.
+	Groups []string `json:"groups,omitempty"`
+
+	// Chains are the distinct middleware chains wrapping the routes, by the
+	// number of routes they wrap and then their middleware.
+	Chains []ChainSummary `json:"chains,omitempty"`
+
+	// Listeners are the listeners of the Server, when the Summary is that of
+	// a Server.
+	Listeners []ListenerSummary `json:"listeners,omitempty"`
+}
+
+// ChainSummary is a middleware chain of a Summary.
+type ChainSummary struct {
+	// Middleware are the names of the middleware of the chain, in the order
+	// they're envoked, as reported by Routes.
+	Middleware []string `json:"middleware"`
+
+	// Routes is the number of routes the chain wraps.
+	Routes int `json:"routes"`
+}
+
+// ListenerSummary is a listener of a Summary.
+type ListenerSummary struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+	TLS     bool   `json:"tls,omitempty"`
+	FastCGI bool   `json:"fastCGI,omitempty"`
+	Admin   bool   `json:"admin,omitempty"`
+}
+
+// Summary will return the Summary of the Mux.
+func (m *Mux) Summary() Summary {
+	stats := m.Stats()
+	s := Summary{Routes: stats.Routes, Patterns: stats.Patterns}
+
+	m.regMu.Lock()
+	seen := make(map[string]bool, len(m.groups))
+	for _, prefix := range m.groups {
+		if !seen[prefix] {
+			seen[prefix] = true
+			s.Groups = append(s.Groups, prefix)
+		}
+	}
+	m.regMu.Unlock()
+	sort.Strings(s.Groups)
+
+	chains := map[string]int{}
+	for _, rt := range m.routes() {
+		key := strings.Join(rt.middleware, "\x00")
+		i, ok := chains[key]
+		if !ok {
+			i = len(s.Chains)
+			chains[key] = i
+			s.Chains = append(s.Chains, ChainSummary{Middleware: append([]string(nil), rt.middleware...)})
+		}
+		s.Chains[i].Routes++
+	}
+
+	sort.Slice(s.Chains, func(i, j int) bool {
+		if s.Chains[i].Routes != s.Chains[j].Routes {
+			return s.Chains[i].Routes > s.Chains[j].Routes
+		}
+
+		return strings.Join(s.Chains[i].Middleware, ",") < strings.Join(s.Chains[j].Middleware, ",")
+	})
+
+	return s
+}
+
+// Summary will return the Summary of the Mux of the Server, with its
+// listeners. The address of a listener is the one it was configured with, so
+// a port of 0 isn't resolved to the one bound.
+func (s *Server) Summary() Summary {
+	summary := s.mux.Summary()
+	for _, sl := range s.listeners {
+		ls := ListenerSummary{
+			Network: sl.network,
+			Address: sl.address,
+			TLS:     sl.tls,
+			FastCGI: sl.fcgi,
+			Admin:   sl.admin,
+		}
+		if sl.listener != nil {
+			ls.Network, ls.Address = sl.listener.Addr().Network(), sl.listener.Addr().String()
+		}
+
+		summary.Listeners = append(summary.Listeners, ls)
+	}
+
+	return summary
+}
+
+// LogValue satisfies the slog.LogValuer interface, logging the chains and
+// listeners as strings so the Summary stays on one line of a text handler.
+func (s Summary) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Int("routes", s.Routes),
+		slog.Int("patterns", s.Patterns),
+		slog.Any("groups", s.Groups),
+	}
+
+	chains := make([]string, 0, len(s.Chains))
+	for _, chain := range s.Chains {
+		middleware := strings.Join(chain.Middleware, ">")
+		if middleware == "" {
+			middleware = "none"
+		}
+		chains = append(chains, middleware+"="+strconv.Itoa(chain.Routes))
+	}
+	attrs = append(attrs, slog.Any("chains", chains))
+
+	if len(s.Listeners) > 0 {
+		listeners := make([]string, 0, len(s.Listeners))
+		for _, l := range s.Listeners {
+			listener := l.Network + ":" + l.Address
+			for _, flag := range []struct {
+				set  bool
+				name string
+			}{{l.TLS, "tls"}, {l.FastCGI, "fcgi"}, {l.Admin, "admin"}} {
+				if flag.set {
+					listener += "+" + flag.name
+				}
+			}
+			listeners = append(listeners, listener)
+		}
+		attrs = append(attrs, slog.Any("listeners", listeners))
+	}
+
+	return slog.GroupValue(attrs...)
+}
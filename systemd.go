@@ -0,0 +1,113 @@
+package mux
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor systemd passes sockets on.
+const listenFDsStart = 3
+
+// SystemdListener is a socket inherited through systemd socket activation.
+type SystemdListener struct {
+	net.Listener
+
+	// Name is the name systemd gave the socket with FileDescriptorName=, or
+	// its default name if none was configured.
+	Name string
+}
+
+// SystemdListeners will return the sockets systemd passed to the process with
+// socket activation, using the LISTEN_FDS, LISTEN_PID, and LISTEN_FDNAMES
+// variables. The variables are unset, so child processes don't inherit the
+// sockets too, which means only the first call returns them. If the process
+// wasn't socket activated, no listeners are returned.
+func SystemdListeners() ([]SystemdListener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]SystemdListener, 0, n)
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i := fd - listenFDsStart; i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		// The listener holds its own duplicate of the descriptor, which isn't
+		// inherited by child processes.
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("mux: systemd socket %q: %w", name, err)
+		}
+
+		listeners = append(listeners, SystemdListener{Listener: l, Name: name})
+	}
+
+	return listeners, nil
+}
+
+// WithSystemdListeners will additionally serve the Mux on the sockets systemd
+// passed to the process with socket activation, wrapped in the provided
+// middleware. If the process wasn't socket activated, it does nothing, so the
+// same binary can run outside of systemd. Failing to use an inherited socket
+// is returned from ListenAndServe.
+func WithSystemdListeners(mw ...Middleware) serverOption {
+	return func(s *Server) {
+		listeners, err := SystemdListeners()
+		if err != nil {
+			s.err = err
+			return
+		}
+
+		for _, l := range listeners {
+			WithNetListener(l.Listener, mw...)(s)
+		}
+	}
+}
+
+// SystemdNotify will send the state to systemd's notification socket, such as
+// "READY=1" or "STOPPING=1", see sd_notify(3). If the process wasn't started
+// by systemd with a notification socket, it does nothing. The Server sends
+// READY=1 once it's listening and STOPPING=1 when it begins shutting down, so
+// it suits services with Type=notify.
+func SystemdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("mux: systemd notify: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("mux: systemd notify: %w", err)
+	}
+
+	return nil
+}
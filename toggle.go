@@ -0,0 +1,157 @@
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrUnknownToggle is the error Toggles.Set returns for a toggle that isn't
+// registered on any route, or not on the route.
+var ErrUnknownToggle = errors.New("mux: unknown toggle")
+
+// Toggles holds middleware that can be enabled and disabled per route while
+// the service is running, such as request dumpers, verbose logging or
+// mirroring traffic, without redeploying. Register the middleware returned by
+// its Middleware method, and set their state with Set or the admin Handler.
+type Toggles struct {
+	mu      sync.Mutex
+	toggles map[string][]*toggleHandler
+}
+
+// ToggleState is the state of a toggleable middleware on a route.
+type ToggleState struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Enabled bool   `json:"enabled"`
+}
+
+// NewToggles will return Toggles without any middleware.
+func NewToggles() *Toggles {
+	return &Toggles{toggles: map[string][]*toggleHandler{}}
+}
+
+// Middleware will return the middleware toggleable by the name, enabled on the
+// routes it wraps to begin with if enabled is set. While it's disabled on a
+// route, requests skip it, costing an atomic load. It's reported by Routes
+// with the name.
+func (t *Toggles) Middleware(name string, mw Middleware, enabled bool) Middleware {
+	if len(name) == 0 {
+		panic("toggle name must not be empty")
+	}
+
+	if mw == nil {
+		panic("middleware must not be nil")
+	}
+
+	return Named(name, func(next http.Handler) http.Handler {
+		h := &toggleHandler{toggles: t, name: name, next: next, wrapped: mw(next)}
+		h.enabled.Store(enabled)
+		return h
+	})
+}
+
+// Set will enable or disable the toggle on the route of the pattern, as
+// reported by Routes, or on every route it wraps if the pattern is empty. It
+// returns ErrUnknownToggle if the toggle doesn't wrap the route.
+func (t *Toggles) Set(name, pattern string, enabled bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var found bool
+	for _, h := range t.toggles[name] {
+		if pattern == "" || h.rt.pattern == pattern {
+			h.enabled.Store(enabled)
+			found = true
+		}
+	}
+
+	if !found {
+		return ErrUnknownToggle
+	}
+
+	return nil
+}
+
+// States will return the state of every toggle on every route, sorted by name
+// and pattern.
+func (t *Toggles) States() []ToggleState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var states []ToggleState
+	for name, handlers := range t.toggles {
+		for _, h := range handlers {
+			states = append(states, ToggleState{Name: name, Pattern: h.rt.pattern, Enabled: h.enabled.Load()})
+		}
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Name != states[j].Name {
+			return states[i].Name < states[j].Name
+		}
+
+		return states[i].Pattern < states[j].Pattern
+	})
+
+	return states
+}
+
+// Handler will return the admin endpoint of the toggles: GET responds with
+// their States as JSON, and POST sets one from a ToggleState in the JSON body,
+// an empty pattern setting it on every route, responding with a 404 if it's
+// unknown. Register it behind middleware protecting it, such as Internal.
+func (t *Toggles) Handler() http.Handler {
+	return Methods(
+		WithGET(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(t.States())
+		})),
+		WithPOST(ErrHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			var state ToggleState
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&state); err != nil {
+				return Error(err, http.StatusBadRequest, http.StatusText(http.StatusBadRequest))
+			}
+
+			if err := t.Set(state.Name, state.Pattern, state.Enabled); err != nil {
+				return Error(err, http.StatusNotFound, "unknown toggle "+state.Name)
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		})),
+	)
+}
+
+type toggleHandler struct {
+	toggles *Toggles
+	name    string
+	rt      *route
+	next    http.Handler
+	wrapped http.Handler
+	enabled atomic.Bool
+}
+
+// configure will register the handler with its toggles once the route it
+// wraps is registered.
+func (h *toggleHandler) configure(rt *route) {
+	h.rt = rt
+	rt.registered = append(rt.registered, func() {
+		h.toggles.mu.Lock()
+		defer h.toggles.mu.Unlock()
+
+		h.toggles.toggles[h.name] = append(h.toggles.toggles[h.name], h)
+	})
+}
+
+func (h *toggleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.enabled.Load() {
+		h.wrapped.ServeHTTP(w, r)
+		return
+	}
+
+	h.next.ServeHTTP(w, r)
+}
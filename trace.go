@@ -0,0 +1,311 @@
+package mux
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxTraceHeaderSize limits the values of the headers kept by a Tracer.
+const maxTraceHeaderSize = 256
+
+// traceRedacted are the headers a Tracer drops unless it's told to keep them.
+var traceRedacted = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+type tracerOption func(*Tracer)
+
+// Trace describes a request served by a route wrapped in a Tracer.
+type Trace struct {
+	Time     time.Time     `json:"time"`
+	Method   string        `json:"method"`
+	Host     string        `json:"host"`
+	Path     string        `json:"path"`
+	Query    string        `json:"query,omitempty"`
+	Pattern  string        `json:"pattern"`
+	Status   int           `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Written  int64         `json:"written"`
+
+	// Header is the request's header, without the credentials and cookies
+	// unless the Tracer was given them by WithTraceHeaders, and with long
+	// values trimmed.
+	Header http.Header `json:"header,omitempty"`
+
+	// Body is the part of the request's body its handler read, up to the
+	// size given to WithTraceBodies, and BodyTruncated is set if it read
+	// more.
+	Body          []byte `json:"body,omitempty"`
+	BodyTruncated bool   `json:"bodyTruncated,omitempty"`
+}
+
+// Request will return the request of the trace, to replay it against a Mux
+// to reproduce a bug, such as with muxtest.Replay. It lacks the headers the
+// Tracer didn't keep, such as the credentials, so add those of a development
+// account, and its body is cut short if it was truncated.
+func (t Trace) Request() *http.Request {
+	u := &url.URL{Scheme: "http", Host: t.Host, Path: t.Path, RawQuery: t.Query}
+	r, err := http.NewRequest(t.Method, u.String(), bytes.NewReader(t.Body))
+	if err != nil {
+		// A trace that doesn't make a valid request, such as one decoded
+		// from a file edited by hand, is built from its parts instead.
+		r = &http.Request{Method: t.Method, URL: u, Proto: "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1, Body: http.NoBody}
+		if len(t.Body) > 0 {
+			r.Body = io.NopCloser(bytes.NewReader(t.Body))
+		}
+	}
+
+	r.Host = t.Host
+	r.RequestURI = u.RequestURI()
+	r.Header = t.Header.Clone()
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+
+	return r
+}
+
+// TraceFilter selects the traces returned by Tracer.Traces. Its zero value
+// selects every one.
+type TraceFilter struct {
+	// MinStatus selects the traces with at least the status, such as 500 for
+	// the server errors.
+	MinStatus int
+
+	// Pattern selects the traces of the route with the pattern, as reported
+	// by Routes.
+	Pattern string
+
+	// Limit is the most traces returned, if positive.
+	Limit int
+}
+
+// Tracer keeps the last requests served by the routes its Middleware wraps in
+// a ring buffer, for investigating incidents without tracing infrastructure.
+// Query them with Traces or the debug Handler, and replay one against a Mux
+// with Trace.Request to reproduce a bug.
+type Tracer struct {
+	headers map[string]bool
+	bodies  int
+
+	mu     sync.Mutex
+	traces []Trace
+	next   int
+	full   bool
+}
+
+// NewTracer will return a Tracer keeping the last n requests.
+func NewTracer(n int, options ...tracerOption) *Tracer {
+	if n <= 0 {
+		panic("n must be positive")
+	}
+
+	t := &Tracer{traces: make([]Trace, n)}
+	for _, opt := range options {
+		opt(t)
+	}
+
+	return t
+}
+
+// WithTraceHeaders will keep only the request headers of the names, instead of
+// all of them but the credentials and cookies, and none at all if no names
+// are given.
+func WithTraceHeaders(names ...string) tracerOption {
+	headers := make(map[string]bool, len(names))
+	for _, name := range names {
+		headers[http.CanonicalHeaderKey(name)] = true
+	}
+
+	return func(t *Tracer) {
+		t.headers = headers
+	}
+}
+
+// WithTraceBodies will keep the first size bytes of the request bodies, of
+// what their handlers read, so the requests can be replayed with
+// Trace.Request. Bodies aren't kept otherwise, since they may hold personal
+// data and cost the memory of the size for every trace.
+func WithTraceBodies(size int) tracerOption {
+	if size <= 0 {
+		panic("size must be positive")
+	}
+
+	return func(t *Tracer) {
+		t.bodies = size
+	}
+}
+
+// Middleware will record the requests of the routes it wraps. A request whose
+// handler panics is recorded with a 500.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	return &traceHandler{tracer: t, next: next}
+}
+
+type traceHandler struct {
+	tracer *Tracer
+	next   http.Handler
+	rt     *route
+}
+
+func (h *traceHandler) configure(rt *route) {
+	h.rt = rt
+}
+
+func (h *traceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	trace := Trace{
+		Time:   time.Now(),
+		Method: r.Method,
+		Host:   r.Host,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: h.tracer.header(r.Header),
+	}
+	if h.rt != nil {
+		trace.Pattern = h.rt.pattern
+	}
+
+	var body *traceBody
+	if h.tracer.bodies > 0 && r.Body != nil && r.Body != http.NoBody {
+		body = &traceBody{ReadCloser: r.Body, max: h.tracer.bodies}
+		r.Body = body
+	}
+
+	rw := WrapResponseWriter(w)
+	failed := true
+	defer func() {
+		trace.Status, trace.Written = rw.Status(), rw.Written()
+		ReleaseResponseWriter(rw)
+		switch {
+		case failed:
+			trace.Status = http.StatusInternalServerError
+		case trace.Status == 0:
+			trace.Status = http.StatusOK
+		}
+
+		if body != nil {
+			trace.Body, trace.BodyTruncated = body.buf.Bytes(), body.truncated
+		}
+
+		trace.Duration = time.Since(trace.Time)
+		h.tracer.record(trace)
+	}()
+
+	h.next.ServeHTTP(rw, r)
+	failed = false
+}
+
+// traceBody keeps the first bytes of a request body as its handler reads it.
+type traceBody struct {
+	io.ReadCloser
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	keep := min(n, b.max-b.buf.Len())
+	b.buf.Write(p[:keep])
+	if keep < n {
+		b.truncated = true
+	}
+
+	return n, err
+}
+
+// header will return the part of the header the Tracer keeps.
+func (t *Tracer) header(header http.Header) http.Header {
+	kept := make(http.Header, len(header))
+	for name, values := range header {
+		if t.headers != nil && !t.headers[name] || t.headers == nil && traceRedacted[name] {
+			continue
+		}
+
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			if len(v) > maxTraceHeaderSize {
+				v = v[:maxTraceHeaderSize] + "…"
+			}
+			trimmed[i] = v
+		}
+		kept[name] = trimmed
+	}
+
+	return kept
+}
+
+func (t *Tracer) record(trace Trace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.traces[t.next] = trace
+	t.next = (t.next + 1) % len(t.traces)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// Traces will return the traces selected by the filter, the most recent first.
+func (t *Tracer) Traces(filter TraceFilter) []Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.next
+	if t.full {
+		n = len(t.traces)
+	}
+
+	var traces []Trace
+	for i := 1; i <= n; i++ {
+		trace := t.traces[(t.next-i+len(t.traces))%len(t.traces)]
+		if trace.Status < filter.MinStatus || filter.Pattern != "" && trace.Pattern != filter.Pattern {
+			continue
+		}
+
+		traces = append(traces, trace)
+		if filter.Limit > 0 && len(traces) == filter.Limit {
+			break
+		}
+	}
+
+	return traces
+}
+
+// Handler will return the debug endpoint of the Tracer, responding to GET
+// with the JSON of its Traces, filtered by the "status", "pattern" and
+// "limit" query parameters of the TraceFilter, such as "?status=500". Register
+// it behind middleware protecting it, such as Internal, since the traces hold
+// the paths and headers of the requests.
+func (t *Tracer) Handler() http.Handler {
+	return Methods(WithGET(ErrHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		var filter TraceFilter
+		query := r.URL.Query()
+		for name, field := range map[string]*int{"status": &filter.MinStatus, "limit": &filter.Limit} {
+			if v := query.Get(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return Error(err, http.StatusBadRequest, "invalid "+name)
+				}
+				*field = n
+			}
+		}
+		filter.Pattern = query.Get("pattern")
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(t.Traces(filter))
+		return nil
+	})))
+}
@@ -0,0 +1,303 @@
+package mux
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TransformFunc returns the body of a response transformed for the request, or
+// an error to respond with instead.
+type TransformFunc func(r *http.Request, body []byte) ([]byte, error)
+
+type transformOption func(*transformer)
+
+type transformer struct {
+	contentType string
+	fn          TransformFunc
+	limit       int
+}
+
+// Transform will return middleware buffering the responses of the content type
+// to transform their bodies with fn, such as to minify HTML or filter the
+// fields of JSON. The content type is matched by its media type, without its
+// parameters, and a type ending in "/" matches every subtype, such as "text/".
+// Responses of other types, without a body, or already encoded are streamed
+// as is.
+//
+// A body larger than 1MB is streamed as is, without being transformed, unless
+// WithTransformLimit sets another limit. Flushing a response being buffered
+// does nothing, so the handler can't send a body that wasn't transformed. An
+// error of fn is responded to by the route's ErrorHandler.
+func Transform(contentType string, fn TransformFunc, options ...transformOption) Middleware {
+	if len(contentType) == 0 {
+		panic("content type must not be empty")
+	}
+
+	if fn == nil {
+		panic("transform must not be nil")
+	}
+
+	t := &transformer{contentType: strings.ToLower(contentType), fn: fn, limit: 1 << 20}
+	for _, opt := range options {
+		opt(t)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return &transformHandler{t: t, next: next}
+	}
+}
+
+// WithTransformLimit will only transform bodies of at most n bytes, instead of
+// 1MB. Larger ones are streamed as is once they exceed it. A limit of 0
+// transforms bodies of any size.
+func WithTransformLimit(n int) transformOption {
+	if n < 0 {
+		panic("limit must not be negative")
+	}
+
+	return func(t *transformer) {
+		t.limit = n
+	}
+}
+
+// FilterJSONFields will return a TransformFunc keeping only the fields of a
+// JSON object, or of the objects of a JSON array, listed by the query
+// parameter, such as "?fields=id,name". Nested fields are named with dots,
+// such as "owner.name". The body is left as is when the parameter is missing,
+// or when it isn't an object or array. It trims responses for clients, and
+// doesn't hide anything from them: bodies over the limit of Transform are sent
+// whole.
+func FilterJSONFields(param string) TransformFunc {
+	if len(param) == 0 {
+		panic("param must not be empty")
+	}
+
+	return func(r *http.Request, body []byte) ([]byte, error) {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			return body, nil
+		}
+
+		fields := fieldTree{}
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				fields.add(strings.Split(name, "."))
+			}
+		}
+
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, Error(err, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		}
+
+		switch v.(type) {
+		case map[string]any, []any:
+		default:
+			return body, nil
+		}
+
+		out, err := json.Marshal(fields.filter(v))
+		if err != nil {
+			return nil, Error(err, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		}
+
+		return append(out, '\n'), nil
+	}
+}
+
+// fieldTree holds the fields kept by FilterJSONFields, with those of nested
+// objects beneath them. A field without any beneath it is kept whole.
+type fieldTree map[string]fieldTree
+
+func (t fieldTree) add(path []string) {
+	sub, ok := t[path[0]]
+	if ok && sub == nil {
+		// The field is already kept whole.
+		return
+	}
+
+	if len(path) == 1 {
+		t[path[0]] = nil
+		return
+	}
+
+	if sub == nil {
+		sub = fieldTree{}
+		t[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+func (t fieldTree) filter(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		kept := make(map[string]any, len(t))
+		for name, sub := range t {
+			if value, ok := v[name]; ok {
+				if sub != nil {
+					value = sub.filter(value)
+				}
+				kept[name] = value
+			}
+		}
+		return kept
+
+	case []any:
+		for i, elem := range v {
+			v[i] = t.filter(elem)
+		}
+		return v
+
+	default:
+		return v
+	}
+}
+
+type transformHandler struct {
+	t    *transformer
+	next http.Handler
+	eh   *ErrorHandler
+}
+
+func (h *transformHandler) configure(rt *route) {
+	h.eh = rt.errorHandler()
+}
+
+func (h *transformHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	tw := &transformWriter{ResponseWriter: w, t: h.t}
+	h.next.ServeHTTP(tw, r)
+	if !tw.buffering {
+		if !tw.decided {
+			tw.decide()
+		}
+		return
+	}
+
+	body, err := h.t.fn(r, tw.buf.Bytes())
+	if err != nil {
+		w.Header().Del("Content-Length")
+		eh := h.eh
+		if eh == nil {
+			eh = DefaultErrorHandler
+		}
+		eh.respond(w, r, err)
+		return
+	}
+
+	hdr := w.Header()
+	hdr.Set("Content-Length", strconv.Itoa(len(body)))
+	if etag := hdr.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		hdr.Set("ETag", "W/"+etag)
+	}
+
+	w.WriteHeader(tw.status)
+	w.Write(body)
+}
+
+// transformWriter buffers a response of the transformer's content type, and
+// passes any other through.
+type transformWriter struct {
+	http.ResponseWriter
+	t *transformer
+
+	status    int
+	decided   bool
+	buffering bool
+	buf       bytes.Buffer
+}
+
+func (w *transformWriter) WriteHeader(code int) {
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if w.status != 0 {
+		return
+	}
+
+	w.status = code
+	w.decide()
+}
+
+func (w *transformWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.buffering {
+		return w.ResponseWriter.Write(b)
+	}
+
+	if w.t.limit > 0 && w.buf.Len()+len(b) > w.t.limit {
+		// The body is too large to transform, so what was buffered is
+		// sent as is and the rest streamed.
+		w.buffering = false
+		w.ResponseWriter.WriteHeader(w.status)
+		if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		w.buf = bytes.Buffer{}
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.buf.Write(b)
+}
+
+// Flush will send what was written so far, unless the response is being
+// buffered to be transformed.
+func (w *transformWriter) Flush() {
+	if w.buffering {
+		return
+	}
+
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *transformWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide will buffer the response if it's to be transformed, and otherwise
+// write its header.
+func (w *transformWriter) decide() {
+	w.decided = true
+	h := w.Header()
+	w.buffering = w.status != http.StatusNoContent && w.status != http.StatusNotModified &&
+		w.status != http.StatusPartialContent && h.Get("Content-Encoding") == "" &&
+		w.t.matches(h.Get("Content-Type"))
+
+	if !w.buffering && w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+// matches reports whether the content type is the one to transform.
+func (t *transformer) matches(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasSuffix(t.contentType, "/") {
+		return strings.HasPrefix(mediaType, t.contentType)
+	}
+
+	return mediaType == t.contentType
+}
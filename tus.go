@@ -0,0 +1,470 @@
+package mux
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tusVersion is the version of the tus protocol served by Tus.
+const tusVersion = "1.0.0"
+
+// ErrUploadNotFound is the error an UploadStore returns for an upload it
+// doesn't have.
+var ErrUploadNotFound = errors.New("mux: upload not found")
+
+// UploadInfo describes an upload of a Tus handler.
+type UploadInfo struct {
+	ID string `json:"id"`
+
+	// Size is the length of the upload in bytes, and Offset how many of them
+	// have been received so far.
+	Size   int64 `json:"size"`
+	Offset int64 `json:"offset"`
+
+	// Metadata are the pairs of the Upload-Metadata header the upload was
+	// created with, such as its filename.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Expires is when an unfinished upload is abandoned.
+	Expires time.Time `json:"expires"`
+}
+
+// Done reports whether the whole upload has been received.
+func (u UploadInfo) Done() bool {
+	return u.Offset == u.Size
+}
+
+// UploadStore keeps the uploads of a Tus handler. Implementations must be safe
+// for concurrent use. DiskUploadStore keeps them in a directory, and a store
+// of an object storage, such as S3 multipart uploads, serializing the appends
+// to an upload, lets the replicas of a service resume each other's uploads.
+type UploadStore interface {
+	// Create will start the upload, with nothing received yet.
+	Create(ctx context.Context, info UploadInfo) error
+
+	// Info will return the upload, with the offset of what it has kept, or
+	// ErrUploadNotFound.
+	Info(ctx context.Context, id string) (UploadInfo, error)
+
+	// Append will add what it reads from r to the upload, at the offset, and
+	// return how many bytes it kept. The bytes kept must still be there to
+	// resume from when reading r fails midway, such as when the client
+	// loses its connection.
+	Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+
+	// Delete will remove the upload, if it's present.
+	Delete(ctx context.Context, id string) error
+}
+
+type tusOption func(*Tus)
+
+// Tus serves resumable uploads by the tus protocol, version 1.0.0, with the
+// creation, expiration and termination extensions, for clients on unreliable
+// networks: an upload is created with a POST, and its content sent with PATCH
+// requests from the offset a HEAD request reports, so an upload cut off
+// resumes from what was received instead of starting over.
+//
+// Register its Handler on a Group at its prefix, so it's served the paths
+// relative to it:
+//
+//	uploads := mux.NewTus(mux.NewDiskUploadStore("/var/uploads"),
+//		mux.WithTusMaxSize(10<<30),
+//		mux.WithTusComplete(process),
+//	)
+//	m.Group("/files/", uploads.Handler())
+//
+// Each PATCH request is bounded by the server's ReadTimeout, so clients should
+// send large uploads in chunks. The PATCH requests of an upload are only
+// serialized within the instance, so replicas sharing their uploads need a
+// store that serializes the appends to one itself.
+type Tus struct {
+	store    UploadStore
+	maxSize  int64
+	expiry   time.Duration
+	complete func(ctx context.Context, info UploadInfo) error
+
+	mu     sync.Mutex
+	active map[string]struct{}
+}
+
+// NewTus will return a Tus keeping its uploads in the store, without a limit on
+// their size, and abandoning those not finished within a day.
+func NewTus(store UploadStore, options ...tusOption) *Tus {
+	if store == nil {
+		panic("store must not be nil")
+	}
+
+	t := &Tus{
+		store:  store,
+		expiry: 24 * time.Hour,
+		active: map[string]struct{}{},
+	}
+	for _, opt := range options {
+		opt(t)
+	}
+
+	return t
+}
+
+// WithTusMaxSize will refuse uploads longer than size bytes with a 413.
+func WithTusMaxSize(size int64) tusOption {
+	if size < 1 {
+		panic("max size must be positive")
+	}
+
+	return func(t *Tus) {
+		t.maxSize = size
+	}
+}
+
+// WithTusExpiration will abandon the uploads not finished within the ttl after
+// they're created, instead of a day. Expired uploads are removed from the
+// store once they're requested, and answered with a 410.
+func WithTusExpiration(ttl time.Duration) tusOption {
+	if ttl <= 0 {
+		panic("expiration must be positive")
+	}
+
+	return func(t *Tus) {
+		t.expiry = ttl
+	}
+}
+
+// WithTusComplete will call fn with each upload once it's received whole,
+// before the request completing it is answered, such as to move it out of the
+// store. An error fn returns is handled by the ErrorHandler, and the upload
+// is left in the store.
+func WithTusComplete(fn func(ctx context.Context, info UploadInfo) error) tusOption {
+	if fn == nil {
+		panic("complete func must not be nil")
+	}
+
+	return func(t *Tus) {
+		t.complete = fn
+	}
+}
+
+// Handler will return the handler of the uploads, creating them at its root
+// path and serving each one at its ID under it. The errors of the store are
+// handled by the ErrorHandler of the Group it's registered on.
+func (t *Tus) Handler() http.Handler {
+	return ErrHandlerFunc(t.serve)
+}
+
+func (t *Tus) serve(w http.ResponseWriter, r *http.Request) error {
+	h := w.Header()
+	h.Set("Tus-Resumable", tusVersion)
+
+	if r.Method == http.MethodOptions {
+		h.Set("Tus-Version", tusVersion)
+		h.Set("Tus-Extension", "creation,expiration,termination")
+		if t.maxSize > 0 {
+			h.Set("Tus-Max-Size", strconv.FormatInt(t.maxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		h.Set("Tus-Version", tusVersion)
+		return Error(fmt.Errorf("mux: tus version %q", r.Header.Get("Tus-Resumable")), http.StatusPreconditionFailed, "unsupported tus version")
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/")
+	if id == "" {
+		if r.Method != http.MethodPost {
+			h.Set("Allow", "OPTIONS, POST")
+			return Error(fmt.Errorf("mux: tus method %s", r.Method), http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+		}
+		return t.create(w, r)
+	}
+
+	if !validUploadID(id) {
+		return Error(ErrUploadNotFound, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		return t.head(w, r, id)
+	case http.MethodPatch:
+		return t.patch(w, r, id)
+	case http.MethodDelete:
+		return t.delete(w, r, id)
+	}
+
+	h.Set("Allow", "OPTIONS, HEAD, PATCH, DELETE")
+	return Error(fmt.Errorf("mux: tus method %s", r.Method), http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed))
+}
+
+// create will create an upload of the Upload-Length, responding with its URL.
+func (t *Tus) create(w http.ResponseWriter, r *http.Request) error {
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		return Error(fmt.Errorf("mux: tus upload length %q", r.Header.Get("Upload-Length")), http.StatusBadRequest, "invalid Upload-Length")
+	}
+
+	if t.maxSize > 0 && size > t.maxSize {
+		return Error(fmt.Errorf("mux: tus upload of %d bytes", size), http.StatusRequestEntityTooLarge, "upload too large")
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return Error(err, http.StatusBadRequest, "invalid Upload-Metadata")
+	}
+
+	id, err := newUploadID()
+	if err != nil {
+		return err
+	}
+
+	info := UploadInfo{
+		ID:       id,
+		Size:     size,
+		Metadata: metadata,
+		Expires:  time.Now().Add(t.expiry).UTC().Truncate(time.Second),
+	}
+	if err := t.store.Create(r.Context(), info); err != nil {
+		return err
+	}
+
+	if info.Done() && t.complete != nil {
+		if err := t.complete(r.Context(), info); err != nil {
+			return err
+		}
+	}
+
+	location := OriginalPath(r)
+	if !strings.HasSuffix(location, "/") {
+		location += "/"
+	}
+
+	h := w.Header()
+	h.Set("Location", location+id)
+	h.Set("Upload-Expires", info.Expires.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// head will respond with the offset of the upload.
+func (t *Tus) head(w http.ResponseWriter, r *http.Request, id string) error {
+	info, err := t.info(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	h.Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+	if len(info.Metadata) > 0 {
+		h.Set("Upload-Metadata", formatUploadMetadata(info.Metadata))
+	}
+	if !info.Done() {
+		h.Set("Upload-Expires", info.Expires.Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// patch will append the body to the upload at the Upload-Offset, responding
+// with the offset it reached.
+func (t *Tus) patch(w http.ResponseWriter, r *http.Request, id string) error {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		return Error(fmt.Errorf("mux: tus content type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return Error(fmt.Errorf("mux: tus upload offset %q", r.Header.Get("Upload-Offset")), http.StatusBadRequest, "invalid Upload-Offset")
+	}
+
+	if !t.lock(id) {
+		return Error(fmt.Errorf("mux: tus upload %s is locked", id), http.StatusLocked, "upload is being written by another request")
+	}
+	defer t.unlock(id)
+
+	info, err := t.info(r.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	if offset != info.Offset {
+		return Error(fmt.Errorf("mux: tus offset %d of upload at %d", offset, info.Offset), http.StatusConflict, "Upload-Offset doesn't match the upload")
+	}
+
+	remaining := info.Size - info.Offset
+	if r.ContentLength > remaining {
+		return Error(fmt.Errorf("mux: tus patch of %d bytes with %d remaining", r.ContentLength, remaining), http.StatusRequestEntityTooLarge, "body longer than the rest of the upload")
+	}
+
+	n, err := t.store.Append(r.Context(), id, offset, http.MaxBytesReader(w, r.Body, remaining))
+	info.Offset += n
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return Error(err, http.StatusRequestEntityTooLarge, "body longer than the rest of the upload")
+		}
+		return err
+	}
+
+	if info.Done() && t.complete != nil {
+		if err := t.complete(r.Context(), info); err != nil {
+			return err
+		}
+	}
+
+	h := w.Header()
+	h.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	if !info.Done() {
+		h.Set("Upload-Expires", info.Expires.Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// delete will remove the upload, for the termination extension.
+func (t *Tus) delete(w http.ResponseWriter, r *http.Request, id string) error {
+	if !t.lock(id) {
+		return Error(fmt.Errorf("mux: tus upload %s is locked", id), http.StatusLocked, "upload is being written by another request")
+	}
+	defer t.unlock(id)
+
+	if _, err := t.info(r.Context(), id); err != nil {
+		return err
+	}
+
+	if err := t.store.Delete(r.Context(), id); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// info will return the upload from the store, as an error for the
+// ErrorHandler if it's missing or, removing it, if it expired unfinished.
+func (t *Tus) info(ctx context.Context, id string) (UploadInfo, error) {
+	info, err := t.store.Info(ctx, id)
+	if errors.Is(err, ErrUploadNotFound) {
+		return UploadInfo{}, Error(err, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+	if err != nil {
+		return UploadInfo{}, err
+	}
+
+	if !info.Done() && !time.Now().Before(info.Expires) {
+		if err := t.store.Delete(ctx, id); err != nil {
+			return UploadInfo{}, err
+		}
+		return UploadInfo{}, Error(fmt.Errorf("mux: tus upload %s expired", id), http.StatusGone, "upload expired")
+	}
+
+	return info, nil
+}
+
+// lock will mark the upload as being written by a request, reporting false if
+// another one already is.
+func (t *Tus) lock(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.active[id]; ok {
+		return false
+	}
+	t.active[id] = struct{}{}
+
+	return true
+}
+
+func (t *Tus) unlock(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.active, id)
+}
+
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validUploadID reports whether the id could have been made by newUploadID, so
+// a store can use it as a file name.
+func validUploadID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseUploadMetadata will parse the Upload-Metadata header, comma-separated
+// keys each followed by a space and its value in base64, or by nothing for an
+// empty value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("mux: tus metadata with an empty key")
+		}
+
+		if _, ok := metadata[key]; ok {
+			return nil, fmt.Errorf("mux: tus metadata key %q given twice", key)
+		}
+
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("mux: tus metadata value of %q: %w", key, err)
+		}
+		metadata[key] = string(b)
+	}
+
+	return metadata, nil
+}
+
+// formatUploadMetadata will format the metadata as an Upload-Metadata header,
+// sorted by key.
+func formatUploadMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key
+		if v := metadata[key]; v != "" {
+			pairs[i] += " " + base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	}
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,190 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DiskUploadStore is an UploadStore keeping each upload in a file of a
+// directory, next to a file of its UploadInfo, for a single instance. Its
+// appends aren't serialized across processes, so the directory must not be
+// shared by replicas. Uploads are only ever appended to, so what was written
+// before a client lost its connection is kept. Expired uploads are removed as
+// they're requested by Tus, and by Prune.
+type DiskUploadStore struct {
+	dir string
+}
+
+// NewDiskUploadStore will return a DiskUploadStore keeping its uploads in the
+// directory, which is created on the first upload if it doesn't exist.
+func NewDiskUploadStore(dir string) *DiskUploadStore {
+	if dir == "" {
+		panic("dir must not be empty")
+	}
+
+	return &DiskUploadStore{dir: dir}
+}
+
+// Path will return the path of the file of the upload, such as for the
+// complete func of Tus to move it out of the store.
+func (s *DiskUploadStore) Path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// Create satisfies the UploadStore interface.
+func (s *DiskUploadStore) Create(_ context.Context, info UploadInfo) error {
+	if !validUploadID(info.ID) {
+		return fmt.Errorf("mux: invalid upload id %q", info.ID)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.Path(info.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	info.Offset = 0
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	// The info is written last, so an upload is only found once both of its
+	// files are there.
+	return os.WriteFile(s.infoPath(info.ID), b, 0o600)
+}
+
+// Info satisfies the UploadStore interface. The offset is the size of the
+// upload's file.
+func (s *DiskUploadStore) Info(_ context.Context, id string) (UploadInfo, error) {
+	if !validUploadID(id) {
+		return UploadInfo{}, ErrUploadNotFound
+	}
+
+	b, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return UploadInfo{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return UploadInfo{}, err
+	}
+
+	var info UploadInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return UploadInfo{}, fmt.Errorf("mux: upload info of %s: %w", id, err)
+	}
+
+	fi, err := os.Stat(s.Path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return UploadInfo{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return UploadInfo{}, err
+	}
+	info.Offset = fi.Size()
+
+	return info, nil
+}
+
+// Append satisfies the UploadStore interface. It returns an error if the
+// upload's file isn't of the offset's size.
+func (s *DiskUploadStore) Append(_ context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	if !validUploadID(id) {
+		return 0, ErrUploadNotFound
+	}
+
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrUploadNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if fi.Size() != offset {
+		return 0, fmt.Errorf("mux: append to upload %s at %d, its size is %d", id, offset, fi.Size())
+	}
+
+	n, err := io.Copy(f, r)
+	if err != nil {
+		return n, err
+	}
+
+	return n, f.Close()
+}
+
+// Delete satisfies the UploadStore interface.
+func (s *DiskUploadStore) Delete(_ context.Context, id string) error {
+	if !validUploadID(id) {
+		return nil
+	}
+
+	if err := os.Remove(s.infoPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Remove(s.Path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Prune will remove the unfinished uploads that expired, such as on a ticker.
+func (s *DiskUploadStore) Prune(ctx context.Context) error {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".info")
+		if !ok {
+			continue
+		}
+
+		info, err := s.Info(ctx, id)
+		if errors.Is(err, ErrUploadNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if !info.Done() && !now.Before(info.Expires) {
+			if err := s.Delete(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *DiskUploadStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
@@ -0,0 +1,127 @@
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RouteError describes a problem with a pattern registered on the Mux.
+type RouteError struct {
+	Pattern string
+	Problem string
+}
+
+// Error satisfies the error interface
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("pattern %q: %s", e.Pattern, e.Problem)
+}
+
+// ValidationError holds every problem found by Validate.
+type ValidationError struct {
+	Errors []*RouteError
+}
+
+// Error satisfies the error interface
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		problems = append(problems, err.Error())
+	}
+
+	return fmt.Sprintf("mux: %d invalid route(s):\n\t%s", len(e.Errors), strings.Join(problems, "\n\t"))
+}
+
+// Validate will report every problem found with the routes registered on the
+// Mux at once: nil handlers, conflicting registrations, patterns the
+// http.ServeMux rejects, Group prefixes missing their trailing slash, and
+// patterns no request can reach. Registrations that can't be served are
+// skipped rather than panicking, and the others are registered as well as
+// they can be, so call Validate before serving, such as in main() or a
+// -validate-routes mode. The returned error is a *ValidationError, or nil if
+// there are no problems.
+func (m *Mux) Validate() error {
+	m.problemsMu.Lock()
+	defer m.problemsMu.Unlock()
+
+	if len(m.problems) == 0 {
+		return nil
+	}
+
+	errs := make([]*RouteError, len(m.problems))
+	copy(errs, m.problems)
+
+	return &ValidationError{Errors: errs}
+}
+
+// problem will record a problem with a registered pattern for Validate.
+func (m *Mux) problem(pattern, format string, args ...any) {
+	m.problemsMu.Lock()
+	defer m.problemsMu.Unlock()
+
+	m.problems = append(m.problems, &RouteError{
+		Pattern: pattern,
+		Problem: fmt.Sprintf(format, args...),
+	})
+}
+
+// register will add the handler to the underlying http.ServeMux, recording
+// anything it rejects as a problem instead of panicking. It reports whether
+// the handler was registered.
+func (m *Mux) register(pattern string, handler http.Handler) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			// The registration locations the http.ServeMux reports are
+			// always this function, so they're dropped.
+			msg := strings.TrimPrefix(fmt.Sprint(r), "http: ")
+			msg = registeredAt.ReplaceAllString(msg, "")
+			m.problem(pattern, "%s", strings.ReplaceAll(msg, "\n", " "))
+			ok = false
+		}
+	}()
+
+	m.mux.Handle(pattern, handler)
+	if c := m.routeCache.Load(); c != nil {
+		c.clear()
+	}
+
+	return true
+}
+
+var registeredAt = regexp.MustCompile(` \(registered at [^)]*\)`)
+
+// isNilHandler reports whether the handler is nil, including a nil function
+// converted to an http.HandlerFunc.
+func isNilHandler(h http.Handler) bool {
+	if h == nil {
+		return true
+	}
+
+	f, ok := h.(http.HandlerFunc)
+	return ok && f == nil
+}
+
+// unreachable will return why no request can match the pattern, or an empty
+// string if the pattern can be matched.
+func unreachable(pattern string) string {
+	i := strings.Index(pattern, "/")
+	if i < 0 {
+		return "pattern has a host but no path, it must contain a slash"
+	}
+
+	p := pattern[i:]
+	// The http.ServeMux redirects requests for unclean paths to their clean
+	// form before matching, so a pattern that isn't clean is never matched.
+	clean := path.Clean(p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+
+	if clean != p {
+		return fmt.Sprintf("path is not clean, requests are redirected to %q", clean)
+	}
+
+	return ""
+}
@@ -0,0 +1,63 @@
+package mux
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// Vary will add the names of the request headers a response was selected by to
+// its Vary header, merged with those already listed into a single value. Names
+// are compared case-insensitively and listed once, and "*" replaces them all.
+// Middleware negotiating the response, such as by its encoding, language or
+// variant, use it so they don't clobber the names of each other or of the
+// handler, and handlers setting Vary should use it too.
+func Vary(h http.Header, names ...string) {
+	fields := varyFields(h.Values("Vary"))
+	for _, name := range names {
+		fields = appendVary(fields, name)
+	}
+
+	if len(fields) == 0 {
+		return
+	}
+
+	for _, field := range fields {
+		if field == "*" {
+			h["Vary"] = []string{"*"}
+			return
+		}
+	}
+
+	h["Vary"] = []string{strings.Join(fields, ", ")}
+}
+
+// varyFields will return the names listed by the values of a Vary header.
+func varyFields(values []string) []string {
+	var fields []string
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			fields = appendVary(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// appendVary will append the name to the fields in its canonical form, unless
+// it's empty or already listed.
+func appendVary(fields []string, name string) []string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fields
+	}
+
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	for _, field := range fields {
+		if field == name {
+			return fields
+		}
+	}
+
+	return append(fields, name)
+}
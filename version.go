@@ -0,0 +1,193 @@
+package mux
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type versionOption func(*versions)
+
+type versions struct {
+	handlers map[string]http.Handler
+	names    []string
+	fallback string
+	header   string
+}
+
+// Versioned will register each handler under a prefix of its version on the
+// router, so {"v1": v1, "v2": v2} serves /v1/ and /v2/, with the version
+// stripped from the path the handlers see. Requests for any other version are
+// answered with a 404 listing the supported versions. Versioned registers a
+// catch-all "/" route on the router for this, so use it on a Group when the
+// router has other routes at its root.
+func Versioned(r Router, handlers map[string]http.Handler, options ...versionOption) {
+	v := newVersions(handlers, options)
+	for _, name := range v.names {
+		r.Group("/"+name+"/", handlers[name])
+	}
+
+	r.Handle("/", http.HandlerFunc(v.serveUnversioned))
+}
+
+// MediaTypeVersioned will return a handler that selects the handler of the
+// version requested with a vendor media type in the Accept header, such as
+// "application/vnd.myapi.v2+json" for the vendor "myapi" and version "v2".
+// When several are accepted, the supported one with the highest quality wins.
+// Requests for unsupported versions are answered with a 406 listing the
+// supported versions. An empty vendor only selects by WithVersionHeader.
+func MediaTypeVersioned(vendor string, handlers map[string]http.Handler, options ...versionOption) http.Handler {
+	v := newVersions(handlers, options)
+	prefix := "application/vnd." + vendor + "."
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Vary(w.Header(), "Accept")
+		if v.header != "" {
+			Vary(w.Header(), v.header)
+		}
+
+		var requested []string
+		if v.header != "" {
+			if version := r.Header.Get(v.header); version != "" {
+				requested = append(requested, version)
+			}
+		}
+
+		if len(requested) == 0 && vendor != "" {
+			requested = acceptedVersions(r.Header.Values("Accept"), prefix)
+		}
+
+		if len(requested) == 0 && v.fallback != "" {
+			requested = append(requested, v.fallback)
+		}
+
+		for _, version := range requested {
+			if h, ok := v.handlers[version]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		msg := fmt.Sprintf("unsupported API version %q, supported versions: %s", strings.Join(requested, ", "), strings.Join(v.names, ", "))
+		http.Error(w, msg, http.StatusNotAcceptable)
+	})
+}
+
+// WithVersionHeader will select the version by the value of the header, such
+// as "X-API-Version: v2", for clients that can't set the Accept header. The
+// header takes precedence over the Accept header.
+func WithVersionHeader(name string) versionOption {
+	if len(name) == 0 {
+		panic("header must not be empty")
+	}
+
+	return func(v *versions) {
+		v.header = http.CanonicalHeaderKey(name)
+	}
+}
+
+// WithDefaultVersion will serve requests without a version using the handler
+// of the provided version. For Versioned, that's any path that doesn't begin
+// with a version. Paths beginning with something that looks like a version, a
+// "v" followed by a digit, are still treated as unknown versions.
+func WithDefaultVersion(version string) versionOption {
+	return func(v *versions) {
+		if _, ok := v.handlers[version]; !ok {
+			panic(fmt.Sprintf("default version %q is not registered", version))
+		}
+
+		v.fallback = version
+	}
+}
+
+func newVersions(handlers map[string]http.Handler, options []versionOption) *versions {
+	if len(handlers) == 0 {
+		panic("versions must not be empty")
+	}
+
+	v := &versions{handlers: handlers}
+	for name, h := range handlers {
+		if len(name) == 0 || strings.Contains(name, "/") {
+			panic(fmt.Sprintf("invalid version %q", name))
+		}
+
+		if h == nil {
+			panic(fmt.Sprintf("handler for version %q must not be nil", name))
+		}
+
+		v.names = append(v.names, name)
+	}
+	sort.Strings(v.names)
+
+	for _, opt := range options {
+		opt(v)
+	}
+
+	return v
+}
+
+// acceptedVersions will return the versions of the vendor media types in the
+// Accept header values, ordered by their quality.
+func acceptedVersions(accept []string, prefix string) []string {
+	type accepted struct {
+		version string
+		q       float64
+	}
+
+	var all []accepted
+	for _, value := range accept {
+		for _, mediaRange := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil || !strings.HasPrefix(mediaType, prefix) {
+				continue
+			}
+
+			version := strings.TrimPrefix(mediaType, prefix)
+			if i := strings.IndexByte(version, '+'); i >= 0 {
+				version = version[:i]
+			}
+
+			q := 1.0
+			if qv, ok := params["q"]; ok {
+				if parsed, err := strconv.ParseFloat(qv, 64); err == nil {
+					q = parsed
+				}
+			}
+
+			if q > 0 {
+				all = append(all, accepted{version: version, q: q})
+			}
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].q > all[j].q
+	})
+
+	versions := make([]string, len(all))
+	for i, a := range all {
+		versions[i] = a.version
+	}
+
+	return versions
+}
+
+// serveUnversioned will handle every request that didn't match a registered
+// version.
+func (v *versions) serveUnversioned(w http.ResponseWriter, r *http.Request) {
+	segment := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+	if v.fallback != "" && !looksLikeVersion(segment) {
+		v.handlers[v.fallback].ServeHTTP(w, r)
+		return
+	}
+
+	msg := fmt.Sprintf("unsupported API version %q, supported versions: %s", segment, strings.Join(v.names, ", "))
+	http.Error(w, msg, http.StatusNotFound)
+}
+
+func looksLikeVersion(segment string) bool {
+	return len(segment) > 1 && segment[0] == 'v' && segment[1] >= '0' && segment[1] <= '9'
+}
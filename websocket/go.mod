@@ -0,0 +1,10 @@
+module github.com/kevinfalting/mux/websocket
+
+go 1.22
+
+require (
+	github.com/coder/websocket v1.8.12
+	github.com/kevinfalting/mux v0.0.0
+)
+
+replace github.com/kevinfalting/mux => ../
@@ -0,0 +1,172 @@
+/*
+Package websocket serves WebSockets as the connections of a mux.Hub, which
+keeps their rooms and send queues. It lives in its own module so the mux
+package doesn't depend on a WebSocket implementation.
+
+	hub := mux.NewHub()
+	m.OnDrain(hub.Close)
+	m.Handle("GET /chat", websocket.New(hub, func(c *mux.HubConn, msg []byte) {
+		hub.Broadcast("lobby", msg)
+	}, websocket.WithOnConnect(func(c *mux.HubConn, r *http.Request) {
+		c.Join("lobby")
+	})))
+*/
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/coder/websocket"
+	"github.com/kevinfalting/mux"
+)
+
+type option func(*handler)
+
+type handler struct {
+	hub       *mux.Hub
+	onMessage func(c *mux.HubConn, msg []byte)
+	onConnect func(c *mux.HubConn, r *http.Request)
+	accept    websocket.AcceptOptions
+	readLimit int64
+	typ       websocket.MessageType
+}
+
+// New will return a handler upgrading the requests to WebSockets, registering
+// each on the hub and passing the messages it reads to onMessage, one at a
+// time, until it's closed. The messages of the hub are sent as text, unless
+// WithBinary is given.
+//
+// Only requests from the origin of the request's host are accepted, unless
+// WithOrigins allows others, as a defense against cross-site WebSocket
+// hijacking.
+func New(hub *mux.Hub, onMessage func(c *mux.HubConn, msg []byte), options ...option) http.Handler {
+	if hub == nil {
+		panic("hub must not be nil")
+	}
+
+	if onMessage == nil {
+		panic("onMessage must not be nil")
+	}
+
+	h := &handler{hub: hub, onMessage: onMessage, readLimit: 32 << 10, typ: websocket.MessageText}
+	for _, opt := range options {
+		opt(h)
+	}
+
+	return h
+}
+
+// WithOnConnect will call the function with every connection once it's
+// registered, before its messages are read, such as to join it to the rooms
+// of the request's Identity.
+func WithOnConnect(fn func(c *mux.HubConn, r *http.Request)) option {
+	if fn == nil {
+		panic("onConnect must not be nil")
+	}
+
+	return func(h *handler) {
+		h.onConnect = fn
+	}
+}
+
+// WithOrigins will also accept the requests from the origins matching the
+// patterns, as by path.Match, such as "*.example.com".
+func WithOrigins(patterns ...string) option {
+	return func(h *handler) {
+		h.accept.OriginPatterns = append(h.accept.OriginPatterns, patterns...)
+	}
+}
+
+// WithSubprotocols will negotiate the subprotocols, in the order of
+// preference.
+func WithSubprotocols(protocols ...string) option {
+	return func(h *handler) {
+		h.accept.Subprotocols = append(h.accept.Subprotocols, protocols...)
+	}
+}
+
+// WithReadLimit will close the connections reading a message larger than n
+// bytes, instead of 32KiB.
+func WithReadLimit(n int64) option {
+	if n < 1 {
+		panic("read limit must be positive")
+	}
+
+	return func(h *handler) {
+		h.readLimit = n
+	}
+}
+
+// WithBinary will send the messages of the hub as binary messages.
+func WithBinary() option {
+	return func(h *handler) {
+		h.typ = websocket.MessageBinary
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := websocket.Accept(w, r, &h.accept)
+	if err != nil {
+		// Accept has responded to the request.
+		return
+	}
+	ws.SetReadLimit(h.readLimit)
+
+	c := h.hub.Register(&sender{conn: ws, typ: h.typ})
+	if c == nil {
+		return
+	}
+	defer c.Close()
+
+	// The request's context ends when the handler returns, so the
+	// connection's reads end with the connection instead.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.Done()
+		cancel()
+	}()
+
+	if h.onConnect != nil {
+		h.onConnect(c, r)
+	}
+
+	for {
+		_, msg, err := ws.Read(ctx)
+		if err != nil {
+			return
+		}
+
+		h.onMessage(c, msg)
+	}
+}
+
+// sender adapts a WebSocket to the mux.HubSender interface.
+type sender struct {
+	conn *websocket.Conn
+	typ  websocket.MessageType
+}
+
+func (s *sender) Send(ctx context.Context, msg []byte) error {
+	return s.conn.Write(ctx, s.typ, msg)
+}
+
+// Close will start the closing handshake with the status of the reason. It
+// doesn't wait for the handshake, so the Hub closing many connections isn't
+// held up by them.
+func (s *sender) Close(reason error) error {
+	status, text := websocket.StatusNormalClosure, ""
+	switch {
+	case errors.Is(reason, mux.ErrSlowClient):
+		status, text = websocket.StatusTryAgainLater, "slow client"
+	case errors.Is(reason, mux.ErrHubClosed):
+		status, text = websocket.StatusGoingAway, "server going away"
+	case reason != nil:
+		status, text = websocket.StatusInternalError, ""
+	}
+
+	go s.conn.Close(status, text)
+	return nil
+}
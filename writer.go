@@ -0,0 +1,177 @@
+package mux
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ResponseWriter is an http.ResponseWriter that records the status code and
+// the number of bytes of the response, for middleware such as logging,
+// metrics, or recovery. It's returned by WrapResponseWriter.
+//
+// It's an http.Flusher and an io.ReaderFrom whatever it wraps, so streaming
+// and sendfile keep working, and flushing a writer that can't is a no-op. It's
+// an http.Hijacker or an http.Pusher only if the writer it wraps is one, so
+// handlers checking for them see what the connection supports.
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	io.ReaderFrom
+
+	// Status will return the status code of the response, or 0 if the header
+	// hasn't been written yet. Informational 1xx responses aren't recorded.
+	Status() int
+
+	// Written will return the number of bytes of the body written so far.
+	Written() int64
+
+	// Unwrap will return the wrapped ResponseWriter, for
+	// http.ResponseController.
+	Unwrap() http.ResponseWriter
+}
+
+var responseWriters = sync.Pool{
+	New: func() any {
+		return &responseWriter{}
+	},
+}
+
+// WrapResponseWriter will return a ResponseWriter wrapping w, taken from a
+// pool. Call ReleaseResponseWriter once the handler it was passed to has
+// returned, and never use it afterward.
+func WrapResponseWriter(w http.ResponseWriter) ResponseWriter {
+	rw := responseWriters.Get().(*responseWriter)
+	rw.ResponseWriter = w
+
+	// The views only hold the pointer, so they're stored in the interface
+	// without allocating.
+	_, hijacker := w.(http.Hijacker)
+	_, pusher := w.(http.Pusher)
+	switch {
+	case hijacker && pusher:
+		return hijackPushWriter{rw}
+	case hijacker:
+		return hijackWriter{rw}
+	case pusher:
+		return pushWriter{rw}
+	default:
+		return rw
+	}
+}
+
+// ReleaseResponseWriter will return the ResponseWriter to the pool. It does
+// nothing if the ResponseWriter wasn't returned by WrapResponseWriter.
+func ReleaseResponseWriter(w ResponseWriter) {
+	var rw *responseWriter
+	switch w := w.(type) {
+	case *responseWriter:
+		rw = w
+	case hijackWriter:
+		rw = w.responseWriter
+	case pushWriter:
+		rw = w.responseWriter
+	case hijackPushWriter:
+		rw = w.responseWriter
+	default:
+		return
+	}
+
+	*rw = responseWriter{}
+	responseWriters.Put(rw)
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// ReadFrom will use the wrapped writer's ReadFrom if it has one, so a file
+// served by http.ServeContent can still be sent with sendfile.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		// Hide ReadFrom, so io.Copy doesn't call it again.
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+
+	w.written += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Written() int64 {
+	return w.written
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (w *responseWriter) push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+type hijackWriter struct{ *responseWriter }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+type pushWriter struct{ *responseWriter }
+
+func (w pushWriter) Push(target string, opts *http.PushOptions) error {
+	return w.push(target, opts)
+}
+
+type hijackPushWriter struct{ *responseWriter }
+
+func (w hijackPushWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+func (w hijackPushWriter) Push(target string, opts *http.PushOptions) error {
+	return w.push(target, opts)
+}